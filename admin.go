@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/audio"
+)
+
+// requireAdmin only lets through requests bearing the ADMIN_TOKEN.
+// Admin endpoints are disabled entirely when no token is configured.
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			writeError(c, newAPIError(http.StatusForbidden, "Admin API is disabled"))
+			c.Abort()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeError(c, newAPIError(http.StatusUnauthorized, "Invalid admin token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleStatus reports the state of the queue and the backend circuit breaker
+func (s *Service) handleStatus(c *gin.Context) {
+	running, queued := s.Scheduler.Stats()
+	status := gin.H{
+		"model":   getModelName(),
+		"device":  s.Device.Device,
+		"breaker": s.Breaker.Status(),
+		"cache":   s.Cache.Stats(),
+		"queue": gin.H{
+			"running":    running,
+			"queued":     queued,
+			"capacity":   currentConfig().MaxConcurrentJobs,
+			"max_queued": currentConfig().MaxQueuedJobs,
+		},
+		"ffmpeg": gin.H{
+			"running":  audio.RunningProcesses(),
+			"capacity": currentConfig().MaxFFmpegJobs,
+		},
+	}
+	if bridgeWorkers != nil {
+		size, busy, idle := bridgeWorkers.Stats()
+		status["bridge_workers"] = gin.H{"size": size, "busy": busy, "idle": idle}
+	}
+	if s.Jobs != nil {
+		if waiting, err := s.asyncQueueDepth(c.Request.Context(), 0); err == nil {
+			status["async_jobs"] = gin.H{"waiting": waiting, "max_queued": currentConfig().MaxQueuedJobs}
+		}
+	}
+	if s.Queue != nil {
+		if pending, inFlight, err := s.Queue.Depth(c.Request.Context()); err == nil {
+			status["job_queue"] = gin.H{"pending": pending, "in_flight": inFlight}
+		} else {
+			status["job_queue"] = gin.H{"error": err.Error()}
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// startupSetting is a setting main binds once at startup, so a reload can't apply it
+type startupSetting struct {
+	name string
+	keep func(previous, next *Config) bool // restores the running value, reporting whether it differed
+}
+
+// boundAtStartup describes a startup-only setting by the config field holding it
+func boundAtStartup[T comparable](name string, field func(*Config) *T) startupSetting {
+	return startupSetting{name: name, keep: func(previous, next *Config) bool {
+		running, loaded := field(previous), field(next)
+		if *running == *loaded {
+			return false
+		}
+		*loaded = *running
+		return true
+	}}
+}
+
+// startupSettings are the settings a reload leaves alone: the engine and bridge workers,
+// the job database, queue and object storage connections, and the disk cache
+var startupSettings = []startupSetting{
+	boundAtStartup("engine", func(c *Config) *string { return &c.Engine }),
+	boundAtStartup("persistent_bridge", func(c *Config) *bool { return &c.PersistentBridge }),
+	boundAtStartup("require_gpu", func(c *Config) *bool { return &c.RequireGPU }),
+	boundAtStartup("database_url", func(c *Config) *string { return &c.DatabaseURL }),
+	boundAtStartup("redis_url", func(c *Config) *string { return &c.RedisURL }),
+	boundAtStartup("job_visibility_timeout_seconds", func(c *Config) *int { return &c.JobVisibilityTimeoutSeconds }),
+	boundAtStartup("job_max_attempts", func(c *Config) *int { return &c.JobMaxAttempts }),
+	boundAtStartup("s3_endpoint", func(c *Config) *string { return &c.S3Endpoint }),
+	boundAtStartup("cache_dir", func(c *Config) *string { return &c.CacheDir }),
+	boundAtStartup("broker_url", func(c *Config) *string { return &c.BrokerURL }),
+}
+
+// keepStartupSettings puts the running values of startup-only settings back into a reloaded
+// config, so it matches what the server actually uses, and returns the ones that differed
+func keepStartupSettings(previous, next *Config) []string {
+	restart := []string{}
+	for _, setting := range startupSettings {
+		if setting.keep(previous, next) {
+			restart = append(restart, setting.name)
+		}
+	}
+	return restart
+}
+
+// handleReload re-reads the configuration and applies it to the running server. Settings
+// bound at startup keep their running values and are reported under requires_restart.
+func (s *Service) handleReload(c *gin.Context) {
+	next, err := LoadConfig()
+	if err != nil {
+		log.Printf("Config reload failed: %v", err)
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	previous := currentConfig()
+	restart := keepStartupSettings(previous, next)
+	activeConfig.Store(next)
+	s.Scheduler.SetSlots(next.MaxConcurrentJobs)
+	audio.SetMaxProcesses(next.MaxFFmpegJobs)
+	if bridgeWorkers != nil {
+		bridgeWorkers.Resize(next.MaxConcurrentJobs)
+	}
+
+	changes := configChanges(previous, next)
+	if len(restart) > 0 {
+		log.Printf("Config reloaded, %d setting(s) changed; %s need a restart", len(changes), strings.Join(restart, ", "))
+	} else {
+		log.Printf("Config reloaded, %d setting(s) changed", len(changes))
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changes, "requires_restart": restart})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReloadKeepsStartupSettings(t *testing.T) {
+	previous := defaultConfig()
+	previous.DatabaseURL = "jobs.db"
+	next := defaultConfig()
+	next.DatabaseURL = "postgres://db/jobs"
+	next.CacheDir = "/var/cache/transcripts"
+	next.MaxConcurrentJobs = previous.MaxConcurrentJobs + 1
+
+	restart := keepStartupSettings(previous, next)
+	if fmt.Sprint(restart) != "[database_url cache_dir]" {
+		t.Fatalf("requires_restart = %v, want [database_url cache_dir]", restart)
+	}
+	if next.DatabaseURL != "jobs.db" || next.CacheDir != "" {
+		t.Fatalf("reloaded config has DatabaseURL %q and CacheDir %q, want the running values", next.DatabaseURL, next.CacheDir)
+	}
+
+	changes := configChanges(previous, next)
+	if _, ok := changes["max_concurrent_jobs"]; !ok || len(changes) != 1 {
+		t.Fatalf("changes = %v, want only max_concurrent_jobs", changes)
+	}
+}
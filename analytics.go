@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Histogram bucket bounds, in seconds for durations and gaps
+var (
+	durationBuckets   = []float64{1, 2, 5, 10, 20, 30}
+	gapBuckets        = []float64{0.1, 0.5, 1, 2, 5}
+	confidenceBuckets = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+)
+
+// HistogramBucket counts the values in [Min, Max); Max is omitted for the open last bucket
+type HistogramBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+// Distribution summarizes a set of values with percentiles and a histogram
+type Distribution struct {
+	Count     int               `json:"count"`
+	Min       float64           `json:"min"`
+	Max       float64           `json:"max"`
+	Mean      float64           `json:"mean"`
+	P50       float64           `json:"p50"`
+	P90       float64           `json:"p90"`
+	Histogram []HistogramBucket `json:"histogram"`
+}
+
+// newDistribution summarizes the values into the histogram with the given upper bounds
+func newDistribution(values []float64, bounds []float64) Distribution {
+	histogram := make([]HistogramBucket, len(bounds)+1)
+	lower := 0.0
+	for i, bound := range bounds {
+		histogram[i] = HistogramBucket{Min: lower, Max: &bounds[i]}
+		lower = bound
+	}
+	histogram[len(bounds)] = HistogramBucket{Min: lower}
+
+	dist := Distribution{Count: len(values), Histogram: histogram}
+	if len(values) == 0 {
+		return dist
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	total := 0.0
+	for _, value := range sorted {
+		total += value
+		bucket, _ := slices.BinarySearchFunc(bounds, value, func(bound, v float64) int {
+			if bound <= v {
+				return -1
+			}
+			return 1
+		})
+		dist.Histogram[bucket].Count++
+	}
+
+	dist.Min = sorted[0]
+	dist.Max = sorted[len(sorted)-1]
+	dist.Mean = roundTo(total/float64(len(sorted)), 3)
+	dist.P50 = percentile(sorted, 0.5)
+	dist.P90 = percentile(sorted, 0.9)
+	return dist
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// roundTo rounds to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(value*scale) / scale
+}
+
+// handleAnalytics aggregates segment durations, gaps and confidences over the recent
+// transcriptions in the history store
+func (s *Service) handleAnalytics(c *gin.Context) {
+	entries := s.History.Recent()
+
+	var durations, gaps, confidences []float64
+	overlaps, segments := 0, 0
+	languages := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Language != "" {
+			languages[entry.Language]++
+		}
+		for i, segment := range entry.Segments {
+			segments++
+			durations = append(durations, segment.EndTime-segment.StartTime)
+			if segment.Confidence != nil {
+				confidences = append(confidences, *segment.Confidence)
+			}
+			if i == 0 {
+				continue
+			}
+			gap := segment.StartTime - entry.Segments[i-1].EndTime
+			if gap < 0 {
+				overlaps++
+				continue
+			}
+			gaps = append(gaps, gap)
+		}
+	}
+
+	result := gin.H{
+		"transcriptions":    len(entries),
+		"history_size":      currentConfig().HistorySize,
+		"segments":          segments,
+		"segment_durations": newDistribution(durations, durationBuckets),
+		"gaps":              newDistribution(gaps, gapBuckets),
+		"overlaps":          overlaps,
+		"confidence":        newDistribution(confidences, confidenceBuckets),
+		"languages":         languages,
+	}
+	if len(entries) > 0 {
+		result["since"] = entries[0].CompletedAt
+		result["segments_per_transcription"] = roundTo(float64(segments)/float64(len(entries)), 2)
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"transription-service/internal/auth"
+)
+
+// newKeyValidator creates the API key validator selected by AUTH_BACKEND, or nil when the
+// API is open. These settings are read at startup only.
+func newKeyValidator() (auth.Validator, error) {
+	var keys []string
+	if list := os.Getenv("API_KEYS"); list != "" {
+		keys = strings.Split(list, ",")
+	}
+	cacheTTL := time.Duration(getEnvInt("AUTH_CACHE_SECONDS", 60)) * time.Second
+	return auth.New(os.Getenv("AUTH_BACKEND"), keys, os.Getenv("AUTH_INTROSPECTION_URL"), os.Getenv("AUTH_INTROSPECTION_SECRET"), cacheTTL)
+}
+
+// apiKey reads the key from the X-API-Key header or an Authorization bearer token, or from
+// the api_key query parameter on WebSocket handshakes
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return token
+	}
+	// Browsers can't set headers on WebSocket handshakes
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		return c.Query("api_key")
+	}
+	return ""
+}
+
+// requireAPIKey only lets through requests with a key the validator accepts, and
+// everything when no validator is configured
+func (s *Service) requireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Keys == nil {
+			c.Next()
+			return
+		}
+
+		key := apiKey(c)
+		if key == "" {
+			writeError(c, &APIError{Status: http.StatusUnauthorized, Code: "api_key_required", Message: "API key required"})
+			c.Abort()
+			return
+		}
+
+		valid, err := s.Keys.Validate(c.Request.Context(), key)
+		if err != nil {
+			log.Printf("API key validation failed: %v", err)
+			writeError(c, &APIError{Status: http.StatusServiceUnavailable, Code: "auth_unavailable", Message: "Could not validate API key"})
+			c.Abort()
+			return
+		}
+		if !valid {
+			writeError(c, &APIError{Status: http.StatusUnauthorized, Code: "invalid_api_key", Message: "Invalid API key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchFiles bounds how many files one batch may hold, zip entries included
+const maxBatchFiles = 200
+
+// isZipUpload reports whether an uploaded file is a zip archive to unpack into the batch
+func isZipUpload(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".zip")
+}
+
+// batchItem is one file of a batch: an uploaded part, or an entry unpacked from a zip upload
+type batchItem struct {
+	filename string
+	archive  string                // zip upload the entry came from
+	header   *multipart.FileHeader // uploaded part, nil for zip entries
+	path     string                // where a zip entry was unpacked
+	invalid  error                 // why a zip entry can't be transcribed
+}
+
+// validate checks that the item can be transcribed
+func (item batchItem) validate() error {
+	if item.header != nil {
+		return validateUpload(item.header)
+	}
+	return item.invalid
+}
+
+// saveTo puts the item's audio into dir and returns its path
+func (item batchItem) saveTo(c *gin.Context, dir string) (string, error) {
+	if item.header != nil {
+		return saveUpload(c, item.header, dir)
+	}
+	destination := filepath.Join(dir, filepath.Base(item.path))
+	return destination, os.Rename(item.path, destination)
+}
+
+// describe adds the item's name, and archive for zip entries, to a result entry
+func (item batchItem) describe(entry gin.H) gin.H {
+	entry["filename"] = item.filename
+	if item.archive != "" {
+		entry["archive"] = item.archive
+	}
+	return entry
+}
+
+// collectBatchItems lists the files of a batch, unpacking zip uploads into dir. Entries that
+// aren't supported audio, like folders and readme files, are skipped. All archives of the
+// batch share MAX_ARCHIVE_EXTRACT_MB, so many archives of files each under the upload
+// limit can't fill the disk.
+func collectBatchItems(c *gin.Context, files []*multipart.FileHeader, dir string) ([]batchItem, error) {
+	var items []batchItem
+	budget := int64(currentConfig().MaxArchiveExtractMB) * 1024 * 1024
+	for i, file := range files {
+		if !isZipUpload(file.Filename) {
+			items = append(items, batchItem{filename: file.Filename, header: file})
+		} else {
+			entries, err := unpackZipUpload(c, file, filepath.Join(dir, fmt.Sprintf("zip-%d", i)), &budget)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, entries...)
+		}
+		if len(items) > maxBatchFiles {
+			return nil, &APIError{Status: http.StatusBadRequest, Code: "batch_too_large", Message: fmt.Sprintf("Batch has more than %d files", maxBatchFiles)}
+		}
+	}
+	return items, nil
+}
+
+// unpackZipUpload extracts the audio files of a zip upload into dir. Entries are written under
+// their base name in a directory of their own, so paths in the archive can't escape dir, and
+// are cut off at the upload limit whatever size the archive claims. What is extracted comes
+// off budget, and the archive is rejected once that runs out.
+func unpackZipUpload(c *gin.Context, file *multipart.FileHeader, dir string, budget *int64) ([]batchItem, error) {
+	if file.Size > int64(currentConfig().MaxVideoUploadMB)*1024*1024 {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Archive %s too large (max %dMB)", file.Filename, currentConfig().MaxVideoUploadMB)}
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: "Failed to read archive " + file.Filename}
+	}
+	defer f.Close()
+	archive, err := zip.NewReader(f, file.Size)
+	if err != nil {
+		return nil, &APIError{Status: http.StatusBadRequest, Code: "invalid_archive", Message: fmt.Sprintf("%s is not a valid zip archive", file.Filename)}
+	}
+
+	var items []batchItem
+	for _, entry := range archive.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(entry.Name, "__MACOSX/") || !isSupportedAudioFile(name) {
+			continue
+		}
+		item := batchItem{filename: entry.Name, archive: file.Filename}
+		if len(items) >= maxBatchFiles {
+			return nil, &APIError{Status: http.StatusBadRequest, Code: "batch_too_large", Message: fmt.Sprintf("Batch has more than %d files", maxBatchFiles)}
+		}
+
+		limit := int64(uploadLimitMB(name)) * 1024 * 1024
+		if entry.UncompressedSize64 > uint64(limit) {
+			item.invalid = fmt.Errorf("file too large (max %dMB)", uploadLimitMB(name))
+			items = append(items, item)
+			continue
+		}
+
+		if entry.UncompressedSize64 > uint64(*budget) {
+			return nil, archiveTooLarge(file.Filename)
+		}
+
+		entryDir := filepath.Join(dir, fmt.Sprint(len(items)))
+		if err := os.MkdirAll(entryDir, 0o755); err != nil {
+			return nil, storageError(err)
+		}
+		item.path = filepath.Join(entryDir, name)
+		// The archive's claimed sizes can lie, so the budget also caps what is actually written
+		written, err := extractZipEntry(entry, item.path, min(limit, *budget))
+		*budget -= written
+		if err != nil {
+			if isStorageError(err) {
+				return nil, storageError(err)
+			}
+			if *budget < 0 {
+				return nil, archiveTooLarge(file.Filename)
+			}
+			log.Printf("Error extracting %s from %s: %v", entry.Name, file.Filename, err)
+			item.invalid = err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Archive %s contains no supported audio files", file.Filename)}
+	}
+	return items, nil
+}
+
+// archiveTooLarge is the error for an archive that would unpack past MAX_ARCHIVE_EXTRACT_MB
+func archiveTooLarge(filename string) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    "archive_too_large",
+		Message: fmt.Sprintf("Archive %s unpacks to more than the batch's %dMB", filename, currentConfig().MaxArchiveExtractMB),
+	}
+}
+
+// extractZipEntry writes one zip entry to destination, failing once it passes limit bytes.
+// It returns how many bytes it wrote, one past limit when the entry was too large.
+func extractZipEntry(entry *zip.File, destination string, limit int64) (int64, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return 0, fmt.Errorf("unreadable archive entry: %w", err)
+	}
+	defer r.Close()
+
+	w, err := os.Create(destination)
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+
+	written, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if err != nil {
+		return written, fmt.Errorf("unreadable archive entry: %w", err)
+	}
+	if written > limit {
+		return written, fmt.Errorf("file too large (max %dMB)", limit/(1024*1024))
+	}
+	return written, w.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+// zipOf builds a zip archive holding the given entries
+func zipOf(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBatchArchivesShareExtractBudget(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxUploadMB = 1
+	cfg.MaxArchiveExtractMB = 1
+	useConfig(t, cfg)
+
+	// Each entry fits the upload limit, but together the archives unpack past the budget
+	entry := make([]byte, 700*1024)
+	files := map[string][]byte{
+		"a.zip": zipOf(t, map[string][]byte{"one.mp3": entry}),
+		"b.zip": zipOf(t, map[string][]byte{"two.mp3": entry}),
+	}
+	status, body := postBatch(t, &Service{}, "", nil, files)
+	if status != http.StatusBadRequest || body["code"] != "archive_too_large" {
+		t.Fatalf("status = %d, body = %v, want 400 archive_too_large", status, body)
+	}
+
+	// One archive stays within it
+	items, err := collectBatchItems(nil, []*multipart.FileHeader{fileHeader(t, "a.zip", files["a.zip"])}, t.TempDir())
+	if err != nil {
+		t.Fatalf("collectBatchItems = %v, want the archive accepted", err)
+	}
+	if len(items) != 1 || items[0].validate() != nil {
+		t.Fatalf("items = %+v, want one.mp3 unpacked", items)
+	}
+}
+
+// fileHeader uploads data under filename and returns its part of the parsed form
+func fileHeader(t *testing.T, filename string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("audio", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	parsed, err := multipart.NewReader(&body, form.Boundary()).ReadForm(32 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { parsed.RemoveAll() })
+	return parsed.File["audio"][0]
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchMode controls what happens when one file in a batch cannot be transcribed
+type BatchMode string
+
+const (
+	// BatchModeLenient skips failing files and reports an error entry for them
+	BatchModeLenient BatchMode = "lenient"
+	// BatchModeStrict aborts the whole batch on the first invalid or failing file
+	BatchModeStrict BatchMode = "strict"
+)
+
+// supportedAudioExtensions lists the upload types the bridge can decode through ffmpeg
+var supportedAudioExtensions = []string{
+	".mp3", ".wav", ".m4a", ".flac", ".ogg", ".oga", ".opus", ".webm", ".aac", ".mp4", ".mpeg", ".mpga", ".wma",
+	".m4v", ".mkv", ".mov", ".avi",
+}
+
+// isSupportedAudioFile reports whether the filename has a supported audio extension
+func isSupportedAudioFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, supported := range supportedAudioExtensions {
+		if ext == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBatchMode converts a request value into a BatchMode, defaulting to lenient
+func parseBatchMode(value string) (BatchMode, error) {
+	switch BatchMode(value) {
+	case "", BatchModeLenient:
+		return BatchModeLenient, nil
+	case BatchModeStrict:
+		return BatchModeStrict, nil
+	default:
+		return "", fmt.Errorf("invalid batch_mode %q (expected strict or lenient)", value)
+	}
+}
+
+// validateUpload checks that an uploaded file can be transcribed
+func validateUpload(file *multipart.FileHeader) error {
+	if exceedsUploadLimit(file.Filename, file.Size) {
+		return fmt.Errorf("file too large (max %dMB)", uploadLimitMB(file.Filename))
+	}
+	if !isSupportedAudioFile(file.Filename) {
+		return fmt.Errorf("unsupported file type %q", filepath.Ext(file.Filename))
+	}
+	return nil
+}
+
+// handleBatchTranscribe transcribes every `audio` part of a multipart upload, unpacking zip
+// archives into their audio files. With async=true each file becomes a job and the job IDs
+// are returned right away.
+func (s *Service) handleBatchTranscribe(c *gin.Context) {
+	startTime := time.Now()
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["audio"]) == 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "No audio files provided"))
+		return
+	}
+	files := form.File["audio"]
+	async := formValue(c, "async") == "true"
+
+	mode, err := parseBatchMode(formValue(c, "batch_mode"))
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	concurrency, err := batchConcurrency(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	// Create temp directory for uploaded files
+	tmpDir, err := makeTempDir("audio-batch")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	items, err := collectBatchItems(c, files, tmpDir)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// In strict mode nothing is transcribed unless every file is valid
+	if mode == BatchModeStrict {
+		var invalid []gin.H
+		for _, item := range items {
+			if err := item.validate(); err != nil {
+				invalid = append(invalid, item.describe(gin.H{"error": err.Error()}))
+			}
+		}
+		if len(invalid) > 0 {
+			writeError(c, &APIError{Status: http.StatusBadRequest, Message: "Batch rejected: invalid files", Fields: gin.H{"files": invalid}})
+			return
+		}
+	}
+
+	if async {
+		s.queueBatchJobs(c, items, opts, concurrency)
+		return
+	}
+
+	// A strict batch stops handing out files once one fails
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	type batchOutcome struct {
+		result gin.H
+		err    error
+		done   bool
+	}
+	outcomes := make([]batchOutcome, len(items))
+	aborted := -1 // index of the file that aborted a strict batch
+	var mu sync.Mutex
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				result, err := s.transcribeBatchFile(ctx, c, items[i], filepath.Join(tmpDir, fmt.Sprint(i)), opts)
+
+				mu.Lock()
+				if err != nil && mode == BatchModeStrict {
+					if aborted >= 0 {
+						// Cut short by the abort, not a failure of its own
+						mu.Unlock()
+						continue
+					}
+					aborted = i
+					cancel()
+				}
+				outcomes[i] = batchOutcome{result: result, err: err, done: true}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range items {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if clientGone(c) {
+		return
+	}
+	if requestExpired(c) {
+		writeError(c, c.Request.Context().Err())
+		return
+	}
+
+	results := make([]gin.H, 0, len(items))
+	failed := 0
+	for i, outcome := range outcomes {
+		if !outcome.done {
+			continue
+		}
+		if outcome.err != nil {
+			failed++
+			_, body := errorBody(outcome.err)
+			results = append(results, items[i].describe(body))
+			continue
+		}
+		results = append(results, outcome.result)
+	}
+
+	if aborted >= 0 {
+		status, _ := errorBody(outcomes[aborted].err)
+		writeError(c, &APIError{
+			Status:  status,
+			Code:    "batch_aborted",
+			Message: fmt.Sprintf("Batch aborted: %s failed", items[aborted].filename),
+			Fields:  gin.H{"results": results},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":                 results,
+		"succeeded":               len(items) - failed,
+		"failed":                  failed,
+		"concurrency":             concurrency,
+		"processing_time_seconds": time.Since(startTime).Seconds(),
+	})
+}
+
+// batchConcurrency reads how many files of a batch are transcribed at once. It defaults to
+// one and is capped at MAX_CONCURRENT_JOBS, since more would only wait in the scheduler.
+func batchConcurrency(c *gin.Context) (int, error) {
+	concurrency, err := intFormValue(c, "concurrency", 1)
+	if err != nil || concurrency == 0 {
+		return 0, fmt.Errorf("invalid concurrency %q (expected a positive number)", formValue(c, "concurrency"))
+	}
+	return min(concurrency, currentConfig().MaxConcurrentJobs), nil
+}
+
+// transcribeBatchFile validates, saves and transcribes one file of a batch
+func (s *Service) transcribeBatchFile(ctx context.Context, c *gin.Context, item batchItem, dir string, opts TranscribeOptions) (gin.H, error) {
+	startTime := time.Now()
+
+	if err := item.validate(); err != nil {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, storageError(err)
+	}
+
+	audioPath, err := item.saveTo(c, dir)
+	if err != nil {
+		if isStorageError(err) {
+			return nil, storageError(err)
+		}
+		return nil, &APIError{Status: http.StatusInternalServerError, Message: "Failed to save uploaded file"}
+	}
+
+	opts.Filename = path.Base(item.filename)
+	response, err := s.transcribe(ctx, audioPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return item.describe(buildResult(response, time.Since(startTime), opts)), nil
+}
+
+// queueBatchJobs turns every valid file of a batch into a job and answers 202 with the job
+// IDs. Jobs start `concurrency` at a time so a large batch doesn't flood the scheduler's queue;
+// with a shared job queue they are all queued and replicas take them as they have room.
+func (s *Service) queueBatchJobs(c *gin.Context, items []batchItem, opts TranscribeOptions, concurrency int) {
+	type queuedJob struct {
+		job       *Job
+		audioPath string
+	}
+	valid := 0
+	for _, item := range items {
+		if item.validate() == nil {
+			valid++
+		}
+	}
+	if err := s.admitJobs(c.Request.Context(), valid); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	var queued []queuedJob
+	entries := make([]gin.H, 0, len(items))
+	failed := 0
+	for _, item := range items {
+		job, audioPath, err := s.createBatchJob(c, item, opts)
+		if err == nil && s.Queue != nil {
+			err = s.enqueueJob(c.Request.Context(), job, audioPath)
+		}
+		if err != nil {
+			failed++
+			_, body := errorBody(err)
+			entries = append(entries, item.describe(body))
+			continue
+		}
+		if s.Queue == nil {
+			queued = append(queued, queuedJob{job: job, audioPath: audioPath})
+		}
+		entries = append(entries, item.describe(job.statusBody()))
+	}
+
+	go func() {
+		next := make(chan queuedJob)
+		var wg sync.WaitGroup
+		for range concurrency {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for q := range next {
+					s.runJob(q.job, q.audioPath)
+				}
+			}()
+		}
+		for _, q := range queued {
+			next <- q
+		}
+		close(next)
+		wg.Wait()
+	}()
+
+	log.Printf("Batch of %d files queued as %d jobs", len(items), len(items)-failed)
+	c.JSON(http.StatusAccepted, gin.H{
+		"jobs":        entries,
+		"queued":      len(items) - failed,
+		"failed":      failed,
+		"concurrency": concurrency,
+	})
+}
+
+// createBatchJob moves one file of a batch into a directory of its own and registers a job for it
+func (s *Service) createBatchJob(c *gin.Context, item batchItem, opts TranscribeOptions) (*Job, string, error) {
+	if err := item.validate(); err != nil {
+		return nil, "", &APIError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	dir, err := makeTempDir("audio-job")
+	if err != nil {
+		return nil, "", err
+	}
+	audioPath, err := item.saveTo(c, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		if isStorageError(err) {
+			return nil, "", storageError(err)
+		}
+		return nil, "", &APIError{Status: http.StatusInternalServerError, Message: "Failed to save uploaded file"}
+	}
+
+	opts.Filename = path.Base(item.filename)
+	job, err := s.Jobs.Create(dir, item.filename, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		log.Printf("Error creating job: %v", err)
+		return nil, "", &APIError{Status: http.StatusInternalServerError, Message: "Failed to create job"}
+	}
+	return job, audioPath, nil
+}
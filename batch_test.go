@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postBatch runs handleBatchTranscribe on a multipart upload of the given files, with
+// fields sent in the form and query appended to the URL
+func postBatch(t *testing.T, s *Service, query string, fields map[string]string, files map[string][]byte) (int, map[string]any) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	for name, data := range files {
+		part, err := form.CreateFormFile("audio", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/transcribe/batch"+query, &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	s.handleBatchTranscribe(c)
+
+	var response map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, w.Body)
+	}
+	return w.Code, response
+}
+
+func TestBatchModes(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxUploadMB = 1
+	useConfig(t, cfg)
+
+	// Neither file gets as far as the backend: one is too large, the other isn't audio
+	files := map[string][]byte{
+		"long.mp3":  make([]byte, 2*1024*1024),
+		"notes.txt": []byte("not audio"),
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		fields map[string]string
+		status int
+		code   string
+	}{
+		{"default is lenient", "", nil, http.StatusOK, ""},
+		{"lenient in form", "", map[string]string{"batch_mode": "lenient"}, http.StatusOK, ""},
+		{"strict in form", "", map[string]string{"batch_mode": "strict"}, http.StatusBadRequest, "bad_request"},
+		{"strict in query", "?batch_mode=strict", nil, http.StatusBadRequest, "bad_request"},
+		{"lenient in query", "?batch_mode=lenient", nil, http.StatusOK, ""},
+		{"unknown mode", "?batch_mode=eager", nil, http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := postBatch(t, &Service{}, tt.query, tt.fields, files)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %v", status, tt.status, body)
+			}
+			if tt.code != "" && body["code"] != tt.code {
+				t.Fatalf("code = %v, want %s", body["code"], tt.code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			// A lenient batch reports each failing file instead of rejecting the batch
+			if body["failed"] != float64(2) || body["succeeded"] != float64(0) {
+				t.Fatalf("failed = %v, succeeded = %v, want 2 and 0", body["failed"], body["succeeded"])
+			}
+			if results, _ := body["results"].([]any); len(results) != 2 {
+				t.Fatalf("results = %v, want an entry per file", body["results"])
+			}
+		})
+	}
+}
+
+func TestBatchStrictListsInvalidFiles(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxUploadMB = 1
+	useConfig(t, cfg)
+
+	status, body := postBatch(t, &Service{}, "?batch_mode=strict", nil, map[string][]byte{"notes.txt": []byte("not audio")})
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %v", status, body)
+	}
+	invalid, _ := body["files"].([]any)
+	if len(invalid) != 1 {
+		t.Fatalf("files = %v, want the one invalid file", body["files"])
+	}
+	if entry, _ := invalid[0].(map[string]any); entry["filename"] != "notes.txt" {
+		t.Fatalf("invalid file = %v, want notes.txt", invalid[0])
+	}
+}
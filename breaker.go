@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// errCircuitOpen is returned while the breaker is short-circuiting requests
+var errCircuitOpen = &APIError{
+	Status:  http.StatusServiceUnavailable,
+	Code:    "backend_unavailable",
+	Message: "Transcription backend is failing, try again later",
+}
+
+// CircuitBreaker stops spawning doomed transcriptions after repeated backend failures.
+// After the threshold of consecutive failures it opens for the cooldown, then lets a
+// single probe through; the probe's outcome closes or re-opens the circuit.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{state: BreakerClosed}
+}
+
+// Allow reports whether a transcription may run. Every allowed call must be followed by Record,
+// or by Release when it never ran.
+func (b *CircuitBreaker) Allow() error {
+	cfg := currentConfig()
+	if cfg.BreakerThreshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cfg.BreakerCooldown() {
+			return errCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return errCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record feeds the result of an allowed transcription back into the breaker. Server-side
+// failures and backend timeouts count, including errors that aren't API errors such as a
+// bridge that couldn't be started; client errors and cancellations are neutral.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+
+	if err == nil {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	// A hung backend shows up as a timeout, not a server error
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError && apiErr.Code != "transcription_timeout" {
+		return
+	}
+
+	b.failures++
+	threshold := currentConfig().BreakerThreshold
+	if wasProbe || (threshold > 0 && b.failures >= threshold) {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release gives back an allowed call that never reached the backend, such as one turned
+// away by a full queue, without counting it. A probe released this way lets the next
+// request probe instead.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Status describes the breaker for the status endpoint
+func (b *CircuitBreaker) Status() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := map[string]any{
+		"state":                b.state,
+		"consecutive_failures": b.failures,
+	}
+	if b.state == BreakerOpen {
+		remaining := currentConfig().BreakerCooldown() - time.Since(b.openedAt)
+		status["retry_after_seconds"] = max(0, remaining.Seconds())
+	}
+	return status
+}
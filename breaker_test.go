@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// useConfig makes cfg the active configuration for the rest of the test
+func useConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	previous := activeConfig.Load()
+	activeConfig.Store(cfg)
+	t.Cleanup(func() { activeConfig.Store(previous) })
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BreakerThreshold = 3
+	cfg.BreakerCooldownSeconds = 60
+	useConfig(t, cfg)
+
+	b := NewCircuitBreaker()
+	failure := &APIError{Status: http.StatusInternalServerError, Message: "boom"}
+	for i := range 3 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow before failure %d: %v", i+1, err)
+		}
+		b.Record(failure)
+	}
+	if err := b.Allow(); err != errCircuitOpen {
+		t.Fatalf("Allow after 3 failures = %v, want errCircuitOpen", err)
+	}
+	if state := b.Status()["state"]; state != BreakerOpen {
+		t.Fatalf("state = %v, want %s", state, BreakerOpen)
+	}
+}
+
+func TestCircuitBreakerCountsOnlyBackendFailures(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BreakerThreshold = 2
+	cfg.BreakerCooldownSeconds = 60
+	useConfig(t, cfg)
+
+	tests := []struct {
+		name  string
+		err   error
+		count bool
+	}{
+		{"server error", &APIError{Status: http.StatusInternalServerError}, true},
+		{"plain error", errors.New("exec: python3: not found"), true},
+		{"client error", &APIError{Status: http.StatusBadRequest}, false},
+		{"backend timeout", &APIError{Status: http.StatusRequestTimeout, Code: "transcription_timeout"}, true},
+		{"request timeout", &APIError{Status: http.StatusRequestTimeout, Code: "request_timeout"}, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker()
+			b.Allow()
+			b.Record(tt.err)
+			want := 0
+			if tt.count {
+				want = 1
+			}
+			if got := b.Status()["consecutive_failures"]; got != want {
+				t.Fatalf("consecutive_failures = %v, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenRecovery(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BreakerThreshold = 1
+	cfg.BreakerCooldownSeconds = 60
+	useConfig(t, cfg)
+
+	b := NewCircuitBreaker()
+	b.Allow()
+	b.Record(errors.New("bridge crashed"))
+	if err := b.Allow(); err != errCircuitOpen {
+		t.Fatalf("Allow while open = %v, want errCircuitOpen", err)
+	}
+
+	// Once the cooldown passes a single probe goes through
+	b.openedAt = time.Now().Add(-time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow = %v, want nil", err)
+	}
+	if err := b.Allow(); err != errCircuitOpen {
+		t.Fatalf("Allow during probe = %v, want errCircuitOpen", err)
+	}
+
+	// A failed probe opens the circuit again
+	b.Record(&APIError{Status: http.StatusInternalServerError})
+	if state := b.Status()["state"]; state != BreakerOpen {
+		t.Fatalf("state after failed probe = %v, want %s", state, BreakerOpen)
+	}
+
+	// A successful probe closes it
+	b.openedAt = time.Now().Add(-time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("second probe Allow = %v, want nil", err)
+	}
+	b.Record(nil)
+	if state := b.Status()["state"]; state != BreakerClosed {
+		t.Fatalf("state after successful probe = %v, want %s", state, BreakerClosed)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow after recovery = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerReleaseFreesProbe(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BreakerThreshold = 1
+	cfg.BreakerCooldownSeconds = 60
+	useConfig(t, cfg)
+
+	b := NewCircuitBreaker()
+	b.Allow()
+	b.Record(errors.New("bridge crashed"))
+	b.openedAt = time.Now().Add(-time.Minute)
+
+	// A probe turned away before reaching the backend doesn't wedge the breaker
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe Allow = %v, want nil", err)
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow after a released probe = %v, want nil", err)
+	}
+	if state := b.Status()["state"]; state != BreakerHalfOpen {
+		t.Fatalf("state = %v, want %s", state, BreakerHalfOpen)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+
+	"transription-service/internal/pb"
+)
+
+// brokerPollInterval is how long a worker with every slot busy waits before checking again
+const brokerPollInterval = 200 * time.Millisecond
+
+// brokerJob is a transcription request consumed from WORKER_SUBJECT. The audio is either
+// fetched from URL, like /api/transcribe-url, or sent inline; options are the gRPC API's.
+type brokerJob struct {
+	ID             string   `json:"id"`       // echoed in the result so producers can match it
+	URL            string   `json:"url"`      // http(s):// or s3:// address or video page of the audio
+	Audio          []byte   `json:"audio"`    // the file itself, base64 encoded, when there's no URL
+	Filename       string   `json:"filename"` // name of inline audio, whose extension tells its format
+	Model          string   `json:"model"`
+	Languages      []string `json:"languages"`
+	Priority       string   `json:"priority"`
+	Punctuate      bool     `json:"punctuate"`
+	Diarize        bool     `json:"diarize"`
+	TimeoutSeconds float64  `json:"timeout_seconds"`
+}
+
+// brokerResult is published to WORKER_RESULTS_SUBJECT for every job consumed
+type brokerResult struct {
+	ID     string    `json:"id,omitempty"`
+	Status JobStatus `json:"status"`           // completed or failed
+	Result gin.H     `json:"result,omitempty"` // the /api/transcribe JSON body
+	Error  gin.H     `json:"error,omitempty"`  // the HTTP API's error body, with its status
+}
+
+// runBrokerWorker consumes jobs from WORKER_SUBJECT on the NATS server at BROKER_URL instead
+// of serving HTTP, up to MAX_CONCURRENT_JOBS at a time, and publishes each outcome. Workers
+// sharing WORKER_QUEUE_GROUP split the jobs between them. On SIGINT or SIGTERM it stops
+// taking jobs and finishes the running ones. It returns the process exit code.
+func (s *Service) runBrokerWorker() int {
+	cfg := currentConfig()
+	if cfg.BrokerURL == "" {
+		log.Printf("--mode=worker needs BROKER_URL")
+		return 2
+	}
+	conn, err := nats.Connect(cfg.BrokerURL, nats.Name("transcription-worker"), nats.MaxReconnects(-1))
+	if err != nil {
+		log.Printf("Failed to connect to the broker: %v", err)
+		return 1
+	}
+	defer conn.Close()
+	sub, err := conn.QueueSubscribeSync(cfg.WorkerSubject, cfg.WorkerQueueGroup)
+	if err != nil {
+		log.Printf("Failed to subscribe to %s: %v", cfg.WorkerSubject, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Worker consuming %s in group %s, publishing results to %s", cfg.WorkerSubject, cfg.WorkerQueueGroup, cfg.WorkerResultsSubject)
+	log.Println("Using Whisper model: " + getModelName())
+	log.Println("Using compute device: " + s.Device.Device)
+
+	var wg sync.WaitGroup
+	var active atomic.Int32
+	for ctx.Err() == nil {
+		if int(active.Load()) >= currentConfig().MaxConcurrentJobs {
+			select {
+			case <-time.After(brokerPollInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error receiving a job: %v", err)
+				return 1
+			}
+			break
+		}
+		active.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer active.Add(-1)
+			s.runBrokerJob(conn, msg)
+		}()
+	}
+
+	// Jobs already delivered to this worker but not started are lost with it
+	if pending, _, err := sub.Pending(); err == nil && pending > 0 {
+		log.Printf("Dropping %d jobs received but not started", pending)
+	}
+	sub.Unsubscribe()
+	log.Printf("Worker stopping after %d running jobs finish", active.Load())
+	wg.Wait()
+	if err := conn.Flush(); err != nil {
+		log.Printf("Error flushing results: %v", err)
+	}
+	return 0
+}
+
+// runBrokerJob transcribes one consumed job and publishes the outcome to the results subject,
+// and to the message's reply subject when it was sent as a request
+func (s *Service) runBrokerJob(conn *nats.Conn, msg *nats.Msg) {
+	startTime := time.Now()
+	var job brokerJob
+	result := brokerResult{Status: JobCompleted}
+
+	response, opts, err := s.transcribeBrokerJob(msg.Data, &job)
+	result.ID = job.ID
+	duration := time.Since(startTime)
+	if err != nil {
+		log.Printf("Worker job %s failed after %v: %v", job.ID, duration, err)
+		status, body := errorBody(err)
+		body["status"] = status
+		result.Status, result.Error = JobFailed, body
+	} else {
+		log.Printf("Worker job %s completed in %v with %d segments", job.ID, duration, len(response.Segments))
+		result.Result = buildResult(response, duration, opts)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error encoding the result of job %s: %v", job.ID, err)
+		return
+	}
+	if err := conn.Publish(currentConfig().WorkerResultsSubject, data); err != nil {
+		log.Printf("Error publishing the result of job %s: %v", job.ID, err)
+	}
+	if msg.Reply != "" {
+		if err := msg.Respond(data); err != nil {
+			log.Printf("Error answering job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// transcribeBrokerJob decodes a job into job, fetches or saves its audio and transcribes it
+func (s *Service) transcribeBrokerJob(data []byte, job *brokerJob) (TranscriptionResponse, TranscribeOptions, error) {
+	if err := json.Unmarshal(data, job); err != nil {
+		return TranscriptionResponse{}, TranscribeOptions{}, newAPIError(http.StatusBadRequest, "Invalid job: "+err.Error())
+	}
+	opts, err := grpcOptions(&pb.TranscribeRequest{
+		Model:          job.Model,
+		Languages:      job.Languages,
+		Priority:       job.Priority,
+		Punctuate:      job.Punctuate,
+		Diarize:        job.Diarize,
+		TimeoutSeconds: job.TimeoutSeconds,
+		Filename:       job.Filename,
+	})
+	if err != nil {
+		return TranscriptionResponse{}, opts, newAPIError(http.StatusBadRequest, err.Error())
+	}
+
+	// Like an HTTP request, the whole job gets REQUEST_TIMEOUT_SECONDS when it is set
+	ctx := context.Background()
+	if timeout := currentConfig().RequestTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tmpDir, err := makeTempDir("audio-worker")
+	if err != nil {
+		return TranscriptionResponse{}, opts, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var audioPath string
+	if job.URL != "" {
+		audioPath, err = s.downloadMedia(ctx, job.URL, tmpDir)
+	} else {
+		audioPath, err = saveBrokerAudio(job, tmpDir)
+	}
+	if err != nil {
+		return TranscriptionResponse{}, opts, err
+	}
+
+	opts.Filename = filepath.Base(audioPath)
+	response, err := s.transcribe(ctx, audioPath, opts)
+	return response, opts, err
+}
+
+// saveBrokerAudio validates a job's inline audio and writes it to dir
+func saveBrokerAudio(job *brokerJob, dir string) (string, error) {
+	name := filepath.Base(job.Filename)
+	switch {
+	case len(job.Audio) == 0:
+		return "", newAPIError(http.StatusBadRequest, "Job has neither url nor audio")
+	case !isSupportedAudioFile(name):
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("unsupported file type %q (set filename with an audio or video extension)", filepath.Ext(name)))
+	case exceedsUploadLimit(name, int64(len(job.Audio))):
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", uploadLimitMB(name)))
+	}
+
+	audioPath := filepath.Join(dir, name)
+	if err := os.WriteFile(audioPath, job.Audio, 0o600); err != nil {
+		if isStorageError(err) {
+			return "", storageError(err)
+		}
+		return "", newAPIError(http.StatusInternalServerError, "Failed to save audio")
+	}
+	return audioPath, nil
+}
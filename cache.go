@@ -0,0 +1,300 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ResultCache keeps recent transcriptions keyed by audio content and options, so repeated
+// uploads of the same file skip the backend. It holds up to CACHE_SIZE entries for
+// CACHE_TTL_SECONDS each, evicting the least recently used first. With CACHE_DIR set,
+// entries are also written to disk and survive restarts.
+type ResultCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+	hits    int64
+	misses  int64
+
+	keyLocksMu sync.Mutex
+	keyLocks   map[string]*keyLock // serializes disk access per key
+}
+
+// keyLock is a per-key mutex, dropped once nobody holds or waits for it
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// diskEntry is the on-disk form of a cached transcription, including the fields
+// TranscriptionResponse leaves out of its JSON
+type diskEntry struct {
+	StoredAt    time.Time             `json:"stored_at"`
+	Response    TranscriptionResponse `json:"response"`
+	Translation *Translation          `json:"translation,omitempty"`
+	Waveform    *Waveform             `json:"waveform,omitempty"`
+}
+
+// cacheEntry is a cached transcription
+type cacheEntry struct {
+	key      string
+	response TranscriptionResponse
+	storedAt time.Time
+}
+
+// CacheStats reports how well the cache is doing
+type CacheStats struct {
+	Enabled bool    `json:"enabled"`
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// NewResultCache creates an empty cache
+func NewResultCache() *ResultCache {
+	return &ResultCache{entries: make(map[string]*list.Element), order: list.New(), keyLocks: make(map[string]*keyLock)}
+}
+
+// Get returns the cached transcription for a key, counting the lookup as a hit or miss
+func (rc *ResultCache) Get(key string) (TranscriptionResponse, bool) {
+	cfg := currentConfig()
+	if cfg.CacheSize <= 0 {
+		return TranscriptionResponse{}, false
+	}
+
+	rc.mu.Lock()
+	element, ok := rc.entries[key]
+	if ok && time.Since(element.Value.(*cacheEntry).storedAt) > cfg.CacheTTL() {
+		rc.remove(element)
+		ok = false
+	}
+	if ok {
+		rc.hits++
+		rc.order.MoveToFront(element)
+		rc.mu.Unlock()
+		return element.Value.(*cacheEntry).response, true
+	}
+	rc.mu.Unlock()
+
+	// Entries written by an earlier run, or evicted from memory, may still be on disk
+	if cfg.CacheDir != "" {
+		if entry, ok := rc.readDisk(cfg.CacheDir, key, cfg.CacheTTL()); ok {
+			rc.mu.Lock()
+			rc.hits++
+			rc.insert(key, entry.response, entry.storedAt, cfg.CacheSize)
+			rc.mu.Unlock()
+			return entry.response, true
+		}
+	}
+
+	rc.mu.Lock()
+	rc.misses++
+	rc.mu.Unlock()
+	return TranscriptionResponse{}, false
+}
+
+// Put stores a transcription, evicting the oldest entries beyond CACHE_SIZE
+func (rc *ResultCache) Put(key string, response TranscriptionResponse) {
+	cfg := currentConfig()
+	if cfg.CacheSize <= 0 {
+		return
+	}
+
+	storedAt := time.Now()
+	rc.mu.Lock()
+	rc.insert(key, response, storedAt, cfg.CacheSize)
+	rc.mu.Unlock()
+
+	if cfg.CacheDir != "" {
+		if err := rc.writeDisk(cfg.CacheDir, key, diskEntry{StoredAt: storedAt, Response: response, Translation: response.Translation, Waveform: response.Waveform}); err != nil {
+			log.Printf("Could not write cache entry to disk: %v", err)
+		}
+	}
+}
+
+// insert adds or replaces an entry in memory and evicts beyond size; the caller holds the lock
+func (rc *ResultCache) insert(key string, response TranscriptionResponse, storedAt time.Time, size int) {
+	if element, ok := rc.entries[key]; ok {
+		rc.remove(element)
+	}
+	rc.entries[key] = rc.order.PushFront(&cacheEntry{key: key, response: response, storedAt: storedAt})
+	for rc.order.Len() > size {
+		rc.remove(rc.order.Back())
+	}
+}
+
+// remove drops an entry; the caller holds the lock
+func (rc *ResultCache) remove(element *list.Element) {
+	rc.order.Remove(element)
+	delete(rc.entries, element.Value.(*cacheEntry).key)
+}
+
+// lockKey takes the per-key lock and returns the function that releases it
+func (rc *ResultCache) lockKey(key string) func() {
+	rc.keyLocksMu.Lock()
+	lock, ok := rc.keyLocks[key]
+	if !ok {
+		lock = &keyLock{}
+		rc.keyLocks[key] = lock
+	}
+	lock.refs++
+	rc.keyLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		rc.keyLocksMu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(rc.keyLocks, key)
+		}
+		rc.keyLocksMu.Unlock()
+	}
+}
+
+// cacheFilePath returns where the entry for a key lives in the cache directory. Keys are
+// hex digests, so they are safe as file names.
+func cacheFilePath(dir, key string) string {
+	return filepath.Join(dir, key+".json")
+}
+
+// writeDisk stores an entry by writing a temp file in the cache directory and renaming it
+// over the old one, so readers only ever see a complete file
+func (rc *ResultCache) writeDisk(dir, key string, entry diskEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	unlock := rc.lockKey(key)
+	defer unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cacheFilePath(dir, key))
+}
+
+// readDisk loads an entry from the cache directory. Missing, expired and unreadable
+// entries are misses; expired and unreadable files are removed.
+func (rc *ResultCache) readDisk(dir, key string, ttl time.Duration) (cacheEntry, bool) {
+	unlock := rc.lockKey(key)
+	defer unlock()
+
+	path := cacheFilePath(dir, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Could not read cache entry from disk: %v", err)
+		}
+		return cacheEntry{}, false
+	}
+
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("Dropping unreadable cache entry %s: %v", path, err)
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+	if time.Since(entry.StoredAt) > ttl {
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+
+	entry.Response.Translation = entry.Translation
+	entry.Response.Waveform = entry.Waveform
+	return cacheEntry{key: key, response: entry.Response, storedAt: entry.StoredAt}, true
+}
+
+// Stats returns the cache size and hit counters
+func (rc *ResultCache) Stats() CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stats := CacheStats{
+		Enabled: currentConfig().CacheSize > 0,
+		Entries: rc.order.Len(),
+		Hits:    rc.hits,
+		Misses:  rc.misses,
+	}
+	if lookups := rc.hits + rc.misses; lookups > 0 {
+		stats.HitRate = float64(rc.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's contents
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cacheKey combines the audio checksum with the options that change what the backend
+// produces; output-only options like format or search don't split the cache
+func cacheKey(audioSHA256 string, opts TranscribeOptions) string {
+	options, _ := json.Marshal(struct {
+		Model              string
+		Punctuate          bool
+		Diarize            bool
+		SegmentLanguage    bool
+		Languages          []string
+		EstimateSpeakers   bool
+		PadStart           float64
+		Tail               float64
+		PreviewSeconds     float64
+		TrimSilence        bool
+		VAD                bool
+		FallbackLanguage   string
+		FallbackConfidence float64
+		TranslateTo        string
+		Channel            string
+		AudioStream        int
+		NBest              int
+		WaveformResolution int
+		Script             string
+		Task               string
+		Engine             string
+	}{
+		opts.Model, opts.Punctuate, opts.Diarize || opts.SplitSpeakers, opts.SegmentLanguage, opts.Languages,
+		opts.EstimateSpeakers, opts.PadStart, opts.Tail, opts.PreviewSeconds, opts.TrimSilence, opts.VAD, opts.FallbackLanguage, opts.FallbackConfidence, opts.TranslateTo, opts.Channel, opts.AudioStream,
+		opts.NBest, opts.WaveformResolution, opts.Script, opts.Task, opts.Engine,
+	})
+	hash := sha256.Sum256(append([]byte(audioSHA256+"|"), options...))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cachedResponse builds a response whose parts all name n, so a mix of two writes shows
+func cachedResponse(n int) TranscriptionResponse {
+	response := TranscriptionResponse{Language: fmt.Sprintf("w%d", n)}
+	for i := range n {
+		response.Segments = append(response.Segments, TranscriptionSegment{
+			StartTime: float64(i),
+			EndTime:   float64(i + 1),
+			Text:      strings.Repeat(response.Language+" ", 50),
+		})
+	}
+	return response
+}
+
+// checkCachedResponse fails the test unless response is one whole cachedResponse
+func checkCachedResponse(t *testing.T, response TranscriptionResponse) {
+	t.Helper()
+	var n int
+	if _, err := fmt.Sscanf(response.Language, "w%d", &n); err != nil {
+		t.Errorf("unexpected language %q", response.Language)
+		return
+	}
+	if len(response.Segments) != n {
+		t.Errorf("%s has %d segments, want %d", response.Language, len(response.Segments), n)
+		return
+	}
+	for _, segment := range response.Segments {
+		if segment.Text != strings.Repeat(response.Language+" ", 50) {
+			t.Errorf("%s has a segment from another write: %.20q", response.Language, segment.Text)
+			return
+		}
+	}
+}
+
+func TestResultCacheDiskConcurrentPutGet(t *testing.T) {
+	dir := t.TempDir()
+	cfg := defaultConfig()
+	cfg.CacheSize = 10
+	cfg.CacheTTLSeconds = 3600
+	cfg.CacheDir = dir
+	useConfig(t, cfg)
+
+	const key = "0123456789abcdef"
+	rc := NewResultCache()
+	rc.Put(key, cachedResponse(1))
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				rc.Put(key, cachedResponse(2+w*25+i))
+			}
+		}()
+	}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				// A fresh cache has nothing in memory, so it reads the file another Put may be replacing
+				response, ok := NewResultCache().Get(key)
+				if !ok {
+					t.Error("disk Get missed while the entry was being rewritten")
+					return
+				}
+				checkCachedResponse(t, response)
+
+				if response, ok = rc.Get(key); !ok {
+					t.Error("shared Get missed")
+					return
+				}
+				checkCachedResponse(t, response)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != key+".json" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Fatalf("cache directory holds %v, want only %s.json", names, key)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("final cache file is not valid JSON: %v", err)
+	}
+	checkCachedResponse(t, entry.Response)
+}
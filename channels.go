@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"transription-service/internal/audio"
+)
+
+// parseChannel validates the channel option: all, left, right or a 0-based channel number
+func parseChannel(value string) (string, error) {
+	switch value {
+	case "", "all", "left", "right":
+		return value, nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return "", fmt.Errorf("invalid channel %q (expected all, left, right or a channel number)", value)
+	}
+	return value, nil
+}
+
+// selectChannels resolves the channel option against the number of channels in the audio
+func selectChannels(channel string, count int) ([]int, error) {
+	var selected []int
+	switch channel {
+	case "all":
+		for i := range count {
+			selected = append(selected, i)
+		}
+	case "left":
+		selected = []int{0}
+	case "right":
+		selected = []int{1}
+	default:
+		n, _ := strconv.Atoi(channel)
+		selected = []int{n}
+	}
+
+	for _, n := range selected {
+		if n >= count {
+			return nil, fmt.Errorf("channel %s not available, the audio has %d channel(s)", channel, count)
+		}
+	}
+	return selected, nil
+}
+
+// transcribeChannels splits out the requested channels with ffmpeg, transcribes each one on
+// its own and merges the segments in time order, labelled with their channel
+func (s *Service) transcribeChannels(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	count, err := audio.Channels(ctx, audioPath)
+	if err != nil {
+		log.Printf("Error probing audio channels: %v", err)
+		return TranscriptionResponse{}, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to read audio channels", Details: err.Error()}
+	}
+	channels, err := selectChannels(opts.Channel, count)
+	if err != nil {
+		return TranscriptionResponse{}, &APIError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	var merged TranscriptionResponse
+	for _, channel := range channels {
+		channelPath := filepath.Join(filepath.Dir(audioPath), fmt.Sprintf("%s.channel%d.wav", filepath.Base(audioPath), channel))
+		if err := audio.ExtractChannel(ctx, audioPath, channelPath, channel); err != nil {
+			log.Printf("Error extracting channel %d: %v", channel, err)
+			return TranscriptionResponse{}, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to extract audio channel", Details: err.Error()}
+		}
+
+		response, err := s.runPipeline(ctx, channelPath, opts)
+		if err != nil {
+			return TranscriptionResponse{}, err
+		}
+		mergeChannel(&merged, response, channel)
+	}
+
+	sortByStart(merged.Segments)
+	if merged.Translation != nil {
+		sortByStart(merged.Translation.Segments)
+	}
+	return merged, nil
+}
+
+// mergeChannel adds one channel's transcription to the combined response
+func mergeChannel(merged *TranscriptionResponse, response TranscriptionResponse, channel int) {
+	label := func(segments []TranscriptionSegment) []TranscriptionSegment {
+		labelled := make([]TranscriptionSegment, len(segments))
+		for i, segment := range segments {
+			segment.Channel = &channel
+			labelled[i] = segment
+		}
+		return labelled
+	}
+
+	merged.Segments = append(merged.Segments, label(response.Segments)...)
+	for _, warning := range response.Warnings {
+		merged.Warnings = append(merged.Warnings, fmt.Sprintf("channel %d: %s", channel, warning))
+	}
+	if merged.Language == "" {
+		merged.Language = response.Language
+	}
+	if response.EstimatedSpeakers != nil {
+		speakers := *response.EstimatedSpeakers
+		if merged.EstimatedSpeakers != nil {
+			speakers += *merged.EstimatedSpeakers
+		}
+		merged.EstimatedSpeakers = &speakers
+	}
+	if response.Translation != nil {
+		if merged.Translation == nil {
+			merged.Translation = &Translation{Language: response.Translation.Language}
+		}
+		merged.Translation.Segments = append(merged.Translation.Segments, label(response.Translation.Segments)...)
+	}
+}
+
+// sortByStart orders segments by start time, keeping the order of simultaneous ones
+func sortByStart(segments []TranscriptionSegment) {
+	slices.SortStableFunc(segments, func(a, b TranscriptionSegment) int {
+		switch {
+		case a.StartTime < b.StartTime:
+			return -1
+		case a.StartTime > b.StartTime:
+			return 1
+		}
+		return 0
+	})
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"transription-service/internal/audio"
+)
+
+// audioChunk is one piece of long audio. Each chunk runs into the next by the overlap so
+// words at the cut are heard whole by one of them; segments are taken from the chunk whose
+// window holds their start, with windows meeting in the middle of each overlap.
+type audioChunk struct {
+	start, length    float64 // where the chunk is cut from the whole audio, in seconds
+	keepFrom, keepTo float64 // window of the whole timeline the chunk's segments are kept from
+}
+
+// planChunks cuts audio of the given duration into chunks of about chunkSeconds
+func planChunks(duration, chunkSeconds, overlap float64) []audioChunk {
+	// The last chunk takes the remainder rather than leaving a sliver shorter than the overlap
+	count := max(1, int(math.Ceil((duration-overlap)/chunkSeconds)))
+	chunks := make([]audioChunk, count)
+	for i := range chunks {
+		start := float64(i) * chunkSeconds
+		chunks[i] = audioChunk{
+			start:    start,
+			length:   chunkSeconds + overlap,
+			keepFrom: start + overlap/2,
+			keepTo:   start + chunkSeconds + overlap/2,
+		}
+	}
+	chunks[0].keepFrom = math.Inf(-1)
+	chunks[count-1].length = duration - chunks[count-1].start
+	chunks[count-1].keepTo = math.Inf(1)
+	return chunks
+}
+
+// runEngineChunked transcribes audio longer than LONG_AUDIO_THRESHOLD_SECONDS in overlapping
+// chunks, as many at a time as there are transcription slots, and merges the results onto
+// the whole timeline. Each chunk gets its own transcription timeout. Shorter audio, and audio
+// whose length can't be probed, runs whole.
+func (s *Service) runEngineChunked(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	cfg := currentConfig()
+	if cfg.LongAudioChunkSeconds <= 0 {
+		return s.runEngine(ctx, audioPath, opts)
+	}
+	duration, err := audio.Duration(ctx, audioPath)
+	if err != nil || duration <= float64(cfg.LongAudioThresholdSeconds) {
+		return s.runEngine(ctx, audioPath, opts)
+	}
+	chunks := planChunks(duration, float64(cfg.LongAudioChunkSeconds), float64(cfg.LongAudioOverlapSeconds))
+	log.Printf("Transcribing %.0f seconds of audio in %d chunks", duration, len(chunks))
+
+	// The first failure stops the other chunks; the rest of the file is no use without it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := newChunkTracker(ctx, len(chunks), duration)
+	responses := make([]TranscriptionResponse, len(chunks))
+	var failure error
+	var failOnce sync.Once
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(cfg.MaxConcurrentJobs, len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var err error
+				responses[i], err = s.runChunk(tracker.context(ctx, i, chunks[i].start), audioPath, i, chunks[i], opts)
+				if err != nil {
+					// Chunks cut short by the cancellation fail too; only the first failure counts
+					failOnce.Do(func() {
+						failure = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range chunks {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if failure != nil {
+		return TranscriptionResponse{}, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return TranscriptionResponse{}, err
+	}
+	return mergeChunks(chunks, responses), nil
+}
+
+// runChunk cuts one chunk out of the audio and transcribes it
+func (s *Service) runChunk(ctx context.Context, audioPath string, index int, chunk audioChunk, opts TranscribeOptions) (TranscriptionResponse, error) {
+	chunkPath := fmt.Sprintf("%s.chunk%03d.wav", audioPath, index)
+	defer os.Remove(chunkPath)
+	if err := audio.Clip(ctx, audioPath, chunkPath, chunk.start, chunk.length); err != nil {
+		if ctx.Err() != nil {
+			return TranscriptionResponse{}, ctx.Err()
+		}
+		log.Printf("Error cutting chunk %d of long audio: %v", index, err)
+		return TranscriptionResponse{}, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to split long audio", Details: err.Error()}
+	}
+	return s.runEngine(ctx, chunkPath, opts)
+}
+
+// mergeChunks puts the chunks' segments on the whole timeline, keeping each from the chunk
+// whose window holds its start and dropping the ones a neighbouring chunk already covered
+func mergeChunks(chunks []audioChunk, responses []TranscriptionResponse) TranscriptionResponse {
+	merged := TranscriptionResponse{Segments: []TranscriptionSegment{}}
+	var texts []string
+	speakers := 0
+	for i, response := range responses {
+		chunk := chunks[i]
+		if merged.Language == "" {
+			merged.Language = response.Language
+		}
+		if merged.Engine == "" {
+			merged.Engine = response.Engine
+		}
+		merged.Partial = merged.Partial || response.Partial
+		if response.EstimatedSpeakers != nil {
+			// Each chunk's speakers are counted apart, like the channels of a recording
+			total := *response.EstimatedSpeakers
+			if merged.EstimatedSpeakers != nil {
+				total += *merged.EstimatedSpeakers
+			}
+			merged.EstimatedSpeakers = &total
+		}
+		for _, warning := range response.Warnings {
+			if !slices.Contains(merged.Warnings, warning) {
+				merged.Warnings = append(merged.Warnings, warning)
+			}
+		}
+
+		// A chunk without timestamps can only be joined as text
+		if response.TimestampsUnavailable {
+			merged.TimestampsUnavailable = true
+			texts = append(texts, strings.TrimSpace(response.Text))
+			continue
+		}
+
+		shiftSegments(response.Segments, chunk.start)
+		speakers += relabelSpeakers(response.Segments, i, speakers, response.EstimatedSpeakers)
+		var chunkText []string
+		boundary := len(merged.Segments)
+		for _, segment := range response.Segments {
+			if segment.StartTime < chunk.keepFrom || segment.StartTime >= chunk.keepTo {
+				continue
+			}
+			if boundary > 0 && len(merged.Segments) == boundary {
+				// The previous chunk's last segment may run past the window into this one
+				last := &merged.Segments[boundary-1]
+				if (segment.StartTime+segment.EndTime)/2 < last.EndTime {
+					continue
+				}
+				last.EndTime = min(last.EndTime, segment.StartTime)
+			}
+			merged.Segments = append(merged.Segments, segment)
+			chunkText = append(chunkText, strings.TrimSpace(segment.Text))
+		}
+		texts = append(texts, strings.Join(chunkText, " "))
+	}
+
+	if merged.TimestampsUnavailable {
+		merged.Text = strings.Join(texts, " ")
+		merged.Segments = []TranscriptionSegment{}
+	}
+	if merged.EstimatedSpeakers != nil || slices.ContainsFunc(merged.Segments, func(segment TranscriptionSegment) bool { return segment.Speaker != "" }) {
+		merged.Warnings = append(merged.Warnings, "Long audio was transcribed in chunks; speakers are numbered separately in each chunk, so one person may have a different label in each")
+	}
+	return merged
+}
+
+// chunkSpeakerLabel matches the SPEAKER_00 style labels the diarizer assigns
+var chunkSpeakerLabel = regexp.MustCompile(`^SPEAKER_(\d+)$`)
+
+// relabelSpeakers numbers a chunk's speakers after the offset speakers of the chunks before
+// it, since every chunk is diarized on its own and its SPEAKER_00 is nobody in particular
+// elsewhere. Labels in another style get the chunk number instead. It returns how many
+// speakers the chunk takes up.
+func relabelSpeakers(segments []TranscriptionSegment, index, offset int, estimated *int) int {
+	count := 0
+	if estimated != nil {
+		count = *estimated
+	}
+	for i, segment := range segments {
+		if segment.Speaker == "" {
+			continue
+		}
+		match := chunkSpeakerLabel.FindStringSubmatch(segment.Speaker)
+		if match == nil {
+			segments[i].Speaker = fmt.Sprintf("%s (chunk %d)", segment.Speaker, index+1)
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		count = max(count, n+1)
+		segments[i].Speaker = fmt.Sprintf("SPEAKER_%02d", offset+n)
+	}
+	return count
+}
+
+// chunkTracker combines the progress of chunks transcribed in parallel into one report
+type chunkTracker struct {
+	mu       sync.Mutex
+	report   func(ProgressEvent)
+	duration float64
+	decoded  []float64 // seconds of each chunk decoded so far
+	stages   map[string]bool
+}
+
+// newChunkTracker reports to the progress listener on ctx, if there is one
+func newChunkTracker(ctx context.Context, chunks int, duration float64) *chunkTracker {
+	return &chunkTracker{
+		report:   progressListener(ctx),
+		duration: duration,
+		decoded:  make([]float64, chunks),
+		stages:   map[string]bool{},
+	}
+}
+
+// context returns the context a chunk starting at start is transcribed with. Its segments
+// are moved onto the whole timeline and its stages are only passed on the first time any
+// chunk reaches them.
+func (t *chunkTracker) context(ctx context.Context, index int, start float64) context.Context {
+	if t.report == nil {
+		return ctx
+	}
+	return withProgress(ctx, func(event ProgressEvent) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if event.Stage != "" && !t.stages[event.Stage] {
+			t.stages[event.Stage] = true
+			t.report(ProgressEvent{Stage: event.Stage})
+		}
+		if event.Segment == nil {
+			return
+		}
+		segment := *event.Segment
+		t.decoded[index] = segment.EndTime
+		segment.StartTime += start
+		segment.EndTime += start
+		var decoded float64
+		for _, seconds := range t.decoded {
+			decoded += seconds
+		}
+		t.report(ProgressEvent{Segment: &segment, Fraction: min(1, decoded/t.duration)})
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPlanChunks(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name     string
+		duration float64
+		want     []audioChunk
+	}{
+		{"shorter than a chunk", 100, []audioChunk{
+			{start: 0, length: 100, keepFrom: -inf, keepTo: inf},
+		}},
+		{"remainder goes to the last chunk", 1500, []audioChunk{
+			{start: 0, length: 605, keepFrom: -inf, keepTo: 602.5},
+			{start: 600, length: 605, keepFrom: 602.5, keepTo: 1202.5},
+			{start: 1200, length: 300, keepFrom: 1202.5, keepTo: inf},
+		}},
+		{"no sliver shorter than the overlap", 1203, []audioChunk{
+			{start: 0, length: 605, keepFrom: -inf, keepTo: 602.5},
+			{start: 600, length: 603, keepFrom: 602.5, keepTo: inf},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planChunks(tt.duration, 600, 5); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("planChunks(%v) = %+v, want %+v", tt.duration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeChunks(t *testing.T) {
+	chunks := planChunks(1203, 600, 5)
+	speakers := func(n int) *int { return &n }
+	tests := []struct {
+		name      string
+		responses []TranscriptionResponse
+		want      []TranscriptionSegment
+		speakers  *int
+	}{
+		{
+			name: "second chunk shifted onto the whole timeline",
+			responses: []TranscriptionResponse{
+				{Segments: []TranscriptionSegment{{Text: "a", StartTime: 0, EndTime: 300}, {Text: "b", StartTime: 300, EndTime: 600}}},
+				{Segments: []TranscriptionSegment{{Text: "c", StartTime: 10, EndTime: 20}}},
+			},
+			want: []TranscriptionSegment{
+				{Text: "a", StartTime: 0, EndTime: 300},
+				{Text: "b", StartTime: 300, EndTime: 600},
+				{Text: "c", StartTime: 610, EndTime: 620},
+			},
+		},
+		{
+			name: "segments heard twice in the overlap kept once",
+			responses: []TranscriptionResponse{
+				{Segments: []TranscriptionSegment{{Text: "a", StartTime: 0, EndTime: 300}, {Text: "b", StartTime: 300, EndTime: 604.5}}},
+				{Segments: []TranscriptionSegment{
+					{Text: "a", StartTime: 0, EndTime: 2},      // before the window
+					{Text: "b", StartTime: 3.25, EndTime: 4.5}, // in the window, but mostly inside the previous chunk's last segment
+					{Text: "c", StartTime: 4.5, EndTime: 100},
+				}},
+			},
+			want: []TranscriptionSegment{
+				{Text: "a", StartTime: 0, EndTime: 300},
+				{Text: "b", StartTime: 300, EndTime: 604.5},
+				{Text: "c", StartTime: 604.5, EndTime: 700},
+			},
+		},
+		{
+			name: "previous segment cut where the next one starts",
+			responses: []TranscriptionResponse{
+				{Segments: []TranscriptionSegment{{Text: "a", StartTime: 590, EndTime: 604}}},
+				{Segments: []TranscriptionSegment{{Text: "b", StartTime: 3, EndTime: 20}}},
+			},
+			want: []TranscriptionSegment{
+				{Text: "a", StartTime: 590, EndTime: 603},
+				{Text: "b", StartTime: 603, EndTime: 620},
+			},
+		},
+		{
+			name: "speakers numbered after the previous chunks'",
+			responses: []TranscriptionResponse{
+				{EstimatedSpeakers: speakers(2), Segments: []TranscriptionSegment{
+					{Text: "a", StartTime: 0, EndTime: 300, Speaker: "SPEAKER_00"},
+					{Text: "b", StartTime: 300, EndTime: 600, Speaker: "SPEAKER_01"},
+				}},
+				{EstimatedSpeakers: speakers(2), Segments: []TranscriptionSegment{
+					{Text: "c", StartTime: 10, EndTime: 20, Speaker: "SPEAKER_00"},
+					{Text: "d", StartTime: 20, EndTime: 30, Speaker: "guest"},
+				}},
+			},
+			want: []TranscriptionSegment{
+				{Text: "a", StartTime: 0, EndTime: 300, Speaker: "SPEAKER_00"},
+				{Text: "b", StartTime: 300, EndTime: 600, Speaker: "SPEAKER_01"},
+				{Text: "c", StartTime: 610, EndTime: 620, Speaker: "SPEAKER_02"},
+				{Text: "d", StartTime: 620, EndTime: 630, Speaker: "guest (chunk 2)"},
+			},
+			speakers: speakers(4),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeChunks(chunks, tt.responses)
+			if !reflect.DeepEqual(merged.Segments, tt.want) {
+				t.Fatalf("segments = %+v, want %+v", merged.Segments, tt.want)
+			}
+			if !reflect.DeepEqual(merged.EstimatedSpeakers, tt.speakers) {
+				t.Fatalf("estimated speakers = %v, want %v", merged.EstimatedSpeakers, tt.speakers)
+			}
+			if warned := len(merged.Warnings) > 0; warned != (tt.speakers != nil) {
+				t.Fatalf("warnings = %q", merged.Warnings)
+			}
+		})
+	}
+}
+
+func TestMergeChunksWithoutTimestamps(t *testing.T) {
+	merged := mergeChunks(planChunks(1203, 600, 5), []TranscriptionResponse{
+		{Segments: []TranscriptionSegment{{Text: " first half ", StartTime: 0, EndTime: 300}}},
+		{Text: " second half ", TimestampsUnavailable: true},
+	})
+	if !merged.TimestampsUnavailable || merged.Text != "first half second half" || len(merged.Segments) != 0 {
+		t.Fatalf("merged = %+v, want the chunks joined as text", merged)
+	}
+	if len(merged.Warnings) != 0 {
+		t.Fatalf("unexpected warnings %q", merged.Warnings)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"transription-service/internal/formats"
+)
+
+// runTranscribeCommand implements the offline `transcribe` subcommand: it transcribes a file,
+// or every supported audio file in a directory, and writes the results next to them (or to
+// --out) without starting the HTTP server. It returns the process exit code.
+func runTranscribeCommand(args []string) int {
+	flags := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	input := flags.String("input", "", "audio file or directory of audio files to transcribe")
+	out := flags.String("out", "", "output file for a single input, or output directory for a directory (default: next to the input)")
+	format := flags.String("format", "json", "output format: json, sentences, srt, vtt, html, markdown or dialogue")
+	model := flags.String("model", currentConfig().Model, "Whisper model to use")
+	languages := flags.String("languages", "", "comma-separated candidate languages")
+	maxLineChars := flags.Int("max-line-chars", 42, "subtitle line length limit (0 disables)")
+	maxLines := flags.Int("max-lines", 2, "subtitle lines per cue (0 disables)")
+	verbose := flags.Bool("verbose", false, "print pipeline logs")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// The pipeline logs like a server; keep the CLI output to one line per file
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, "transcribe: --input is required")
+		flags.Usage()
+		return 2
+	}
+	if !isSupportedFormat(*format) || *format == "" {
+		fmt.Fprintf(os.Stderr, "transcribe: unsupported format %q\n", *format)
+		return 2
+	}
+	candidates, err := parseLanguageList(*languages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transcribe: %v\n", err)
+		return 2
+	}
+
+	opts := TranscribeOptions{
+		Model:     *model,
+		Priority:  PriorityNormal,
+		Languages: candidates,
+		Precision: -1,
+		Format:    *format,
+		Wrap:      formats.WrapOptions{MaxLineChars: *maxLineChars, MaxLines: *maxLines},
+	}
+	opts.DialogueTimestamps = true
+
+	jobs, err := cliJobs(*input, *out, outputExtension(*format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "transcribe: %v\n", err)
+		return 1
+	}
+
+	service := &Service{Scheduler: NewScheduler(1), Breaker: NewCircuitBreaker(), Models: NewModelCatalog(), Cache: NewResultCache(), History: NewHistoryStore()}
+	failed := 0
+	for _, job := range jobs {
+		if err := service.transcribeToFile(context.Background(), job.input, job.output, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", job.input, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s\n", job.input, job.output)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "transcribe: %d of %d files failed\n", failed, len(jobs))
+		return 1
+	}
+	return 0
+}
+
+// cliJob is one input file and where its transcript goes
+type cliJob struct {
+	input  string
+	output string
+}
+
+// cliJobs expands the --input and --out flags into the files to transcribe
+func cliJobs(input, out, extension string) ([]cliJob, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+
+	outputName := func(name string) string {
+		return strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) + extension
+	}
+
+	if !info.IsDir() {
+		output := out
+		if output == "" {
+			output = filepath.Join(filepath.Dir(input), outputName(input))
+		}
+		return []cliJob{{input: input, output: output}}, nil
+	}
+
+	if out == "" {
+		out = input
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(input)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []cliJob
+	for _, entry := range entries {
+		if entry.IsDir() || !isSupportedAudioFile(entry.Name()) {
+			continue
+		}
+		jobs = append(jobs, cliJob{
+			input:  filepath.Join(input, entry.Name()),
+			output: filepath.Join(out, outputName(entry.Name())),
+		})
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no supported audio files in %s", input)
+	}
+	return jobs, nil
+}
+
+// outputExtension returns the file extension for an output format
+func outputExtension(format string) string {
+	switch format {
+	case "srt", "vtt", "html":
+		return "." + format
+	case "markdown":
+		return ".md"
+	case "dialogue":
+		return ".txt"
+	}
+	return ".json"
+}
+
+// transcribeToFile transcribes one file through the same pipeline as the server and writes
+// the rendered result to output
+func (s *Service) transcribeToFile(ctx context.Context, input, output string, opts TranscribeOptions) error {
+	startTime := time.Now()
+
+	// Work on a link in a temp directory so intermediate files don't land next to the input
+	tmpDir, err := makeTempDir("audio-cli")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	source, err := filepath.Abs(input)
+	if err != nil {
+		return err
+	}
+	audioPath := filepath.Join(tmpDir, filepath.Base(input))
+	if err := os.Symlink(source, audioPath); err != nil {
+		return err
+	}
+
+	opts.Filename = filepath.Base(input)
+	response, err := s.transcribe(ctx, audioPath, opts)
+	if err != nil {
+		// Spell out what the server would have put in the error body
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			message := apiErr.Message
+			for _, extra := range []string{apiErr.Details, strings.TrimSpace(apiErr.Output)} {
+				if extra != "" {
+					message += ": " + extra
+				}
+			}
+			return errors.New(message)
+		}
+		return err
+	}
+
+	data, err := renderOutput(response, time.Since(startTime), opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, data, 0o644)
+}
+
+// renderOutput renders a transcription as a subtitle or HTML file, or as indented JSON
+func renderOutput(response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) ([]byte, error) {
+	if _, data, ok := fileOutput(response, opts); ok {
+		return data, nil
+	}
+	data, err := json.MarshalIndent(buildResult(response, duration, opts), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/textdiff"
+)
+
+// compareRun is one model's side of a comparison
+type compareRun struct {
+	Model                 string   `json:"model"`
+	Text                  string   `json:"text"`
+	Language              string   `json:"language,omitempty"`
+	SegmentCount          int      `json:"segment_count"`
+	WordCount             int      `json:"word_count"`
+	ProcessingTimeSeconds float64  `json:"processing_time_seconds"`
+	AverageConfidence     *float64 `json:"average_confidence,omitempty"`
+	Cached                bool     `json:"cached"`
+}
+
+// diffEntry is one stretch of the word diff as returned to clients
+type diffEntry struct {
+	Op   textdiff.Kind `json:"op"`
+	Text string        `json:"text,omitempty"` // equal, removed and added stretches
+	From string        `json:"from,omitempty"` // changed stretches, as model_a heard them
+	To   string        `json:"to,omitempty"`   // changed stretches, as model_b heard them
+}
+
+// handleCompare transcribes one upload with two models and returns a word-level diff of the
+// results with their timing and confidence side by side, for judging model changes
+func (s *Service) handleCompare(c *gin.Context) {
+	file, err := c.FormFile("audio")
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "No audio file provided"))
+		return
+	}
+	if exceedsUploadLimit(file.Filename, file.Size) {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", uploadLimitMB(file.Filename))))
+		return
+	}
+
+	modelA, modelB := strings.TrimSpace(formValue(c, "model_a")), strings.TrimSpace(formValue(c, "model_b"))
+	if modelA == "" || modelB == "" {
+		writeError(c, newAPIError(http.StatusBadRequest, "model_a and model_b are required"))
+		return
+	}
+	if modelA == modelB {
+		writeError(c, newAPIError(http.StatusBadRequest, "model_a and model_b must differ"))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	opts.Filename = file.Filename
+
+	tmpDir, err := makeTempDir("audio-compare")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	audioPath, err := saveUpload(c, file, tmpDir)
+	if err != nil {
+		if isStorageError(err) {
+			writeError(c, storageError(err))
+			return
+		}
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to save uploaded file"))
+		return
+	}
+
+	// The models run one after the other so a comparison never takes two slots at once
+	runs := make([]compareRun, 0, 2)
+	for _, model := range []string{modelA, modelB} {
+		runOpts := opts
+		runOpts.Model = model
+		startTime := time.Now()
+		response, err := s.transcribe(c.Request.Context(), audioPath, runOpts)
+		if err != nil {
+			if clientGone(c) {
+				return
+			}
+			log.Printf("Comparison run with model %s failed: %v", model, err)
+			writeError(c, requestError(c, err))
+			return
+		}
+		redacted, _ := processResponse(response, opts)
+		runs = append(runs, newCompareRun(model, redacted, time.Since(startTime)))
+	}
+	log.Printf("Compared %s and %s on %s", modelA, modelB, file.Filename)
+
+	ops := textdiff.Words(runs[0].Text, runs[1].Text)
+	result := gin.H{
+		"model_a": runs[0],
+		"model_b": runs[1],
+		"diff":    diffEntries(ops),
+		"summary": diffSummary(ops, runs[0].WordCount),
+	}
+	if runs[0].AverageConfidence != nil && runs[1].AverageConfidence != nil {
+		result["confidence_delta"] = *runs[1].AverageConfidence - *runs[0].AverageConfidence
+	}
+	result["processing_time_delta_seconds"] = runs[1].ProcessingTimeSeconds - runs[0].ProcessingTimeSeconds
+	c.JSON(http.StatusOK, result)
+}
+
+// newCompareRun summarizes one model's transcription
+func newCompareRun(model string, response TranscriptionResponse, duration time.Duration) compareRun {
+	text := responseText(response)
+	run := compareRun{
+		Model:                 model,
+		Text:                  text,
+		Language:              response.Language,
+		SegmentCount:          len(response.Segments),
+		WordCount:             len(strings.Fields(text)),
+		ProcessingTimeSeconds: duration.Seconds(),
+		Cached:                response.Cached,
+	}
+
+	var total float64
+	var scored int
+	for _, segment := range response.Segments {
+		if segment.Confidence != nil {
+			total += *segment.Confidence
+			scored++
+		}
+	}
+	if scored > 0 {
+		average := total / float64(scored)
+		run.AverageConfidence = &average
+	}
+	return run
+}
+
+// diffEntries converts diff operations into their JSON form
+func diffEntries(ops []textdiff.Op) []diffEntry {
+	entries := make([]diffEntry, 0, len(ops))
+	for _, op := range ops {
+		entry := diffEntry{Op: op.Kind}
+		switch op.Kind {
+		case textdiff.Equal, textdiff.Removed:
+			entry.Text = strings.Join(op.A, " ")
+		case textdiff.Added:
+			entry.Text = strings.Join(op.B, " ")
+		case textdiff.Changed:
+			entry.From = strings.Join(op.A, " ")
+			entry.To = strings.Join(op.B, " ")
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// diffSummary counts the differing words, with a word error rate that treats model_a as the reference
+func diffSummary(ops []textdiff.Op, referenceWords int) gin.H {
+	var equal, added, removed, changed int
+	for _, op := range ops {
+		switch op.Kind {
+		case textdiff.Equal:
+			equal += len(op.A)
+		case textdiff.Added:
+			added += len(op.B)
+		case textdiff.Removed:
+			removed += len(op.A)
+		case textdiff.Changed:
+			// Paired words are substitutions, the rest of the longer side insertions or deletions
+			pairs := min(len(op.A), len(op.B))
+			changed += pairs
+			added += len(op.B) - pairs
+			removed += len(op.A) - pairs
+		}
+	}
+
+	summary := gin.H{
+		"equal_words":   equal,
+		"added_words":   added,
+		"removed_words": removed,
+		"changed_words": changed,
+	}
+	if referenceWords > 0 {
+		summary["word_error_rate"] = float64(added+removed+changed) / float64(referenceWords)
+	}
+	return summary
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"transription-service/internal/fetch"
+	"transription-service/internal/store"
+	"transription-service/internal/transcriber"
+	"transription-service/internal/translate"
+)
+
+// Config holds the settings that can be reloaded without restarting the server
+type Config struct {
+	Model                       string  `json:"model"`
+	Engine                      string  `json:"engine"`
+	PersistentBridge            bool    `json:"persistent_bridge"`
+	WhisperCPPPath              string  `json:"whisper_cpp_path"`
+	WhisperCPPModelDir          string  `json:"whisper_cpp_model_dir"`
+	OverflowEngine              string  `json:"overflow_engine"`
+	OpenAIBaseURL               string  `json:"openai_base_url"`
+	OpenAIModel                 string  `json:"openai_model"`
+	OpenAIAPIKey                string  `json:"-"` // env only, like the translator key
+	TranscriptionTimeoutSeconds int     `json:"transcription_timeout_seconds"`
+	RequestTimeoutSeconds       int     `json:"request_timeout_seconds"`
+	MinTimeoutSeconds           int     `json:"min_timeout_seconds"`
+	MaxTimeoutSeconds           int     `json:"max_timeout_seconds"`
+	MaxUploadMB                 int     `json:"max_upload_mb"`
+	MaxVideoUploadMB            int     `json:"max_video_upload_mb"`
+	MaxArchiveExtractMB         int     `json:"max_archive_extract_mb"`
+	MaxConcurrentJobs           int     `json:"max_concurrent_jobs"`
+	MaxFFmpegJobs               int     `json:"max_ffmpeg_jobs"`
+	MaxQueuedJobs               int     `json:"max_queued_jobs"`
+	DownloadTimeoutSeconds      int     `json:"download_timeout_seconds"`
+	DownloadRetries             int     `json:"download_retries"`
+	DownloadMaxRedirects        int     `json:"download_max_redirects"`
+	AllowPrivateURLs            bool    `json:"allow_private_urls"`
+	YTDLPPath                   string  `json:"ytdlp_path"`
+	MaxMediaDurationSeconds     int     `json:"max_media_duration_seconds"`
+	S3Endpoint                  string  `json:"s3_endpoint"`
+	WebhookTimeoutSeconds       int     `json:"webhook_timeout_seconds"`
+	WebhookRetries              int     `json:"webhook_retries"`
+	WebhookSecret               string  `json:"-"` // env only, like the translator key
+	DatabaseURL                 string  `json:"-"` // env only, since the URL may hold a password
+	RedisURL                    string  `json:"-"` // env only, like DATABASE_URL
+	JobVisibilityTimeoutSeconds int     `json:"job_visibility_timeout_seconds"`
+	JobMaxAttempts              int     `json:"job_max_attempts"`
+	BrokerURL                   string  `json:"-"` // env only, since the URL may hold a password
+	WorkerSubject               string  `json:"worker_subject"`
+	WorkerResultsSubject        string  `json:"worker_results_subject"`
+	WorkerQueueGroup            string  `json:"worker_queue_group"`
+	ErrorOutputLimit            int     `json:"error_output_limit"`
+	Debug                       bool    `json:"debug"`
+	RequireGPU                  bool    `json:"require_gpu"`
+	StreamChunkSeconds          int     `json:"stream_chunk_seconds"`
+	ReadingWPM                  int     `json:"reading_wpm"`
+	DuplicatePolicy             string  `json:"duplicate_policy"`
+	DuplicateWindowSeconds      int     `json:"duplicate_window_seconds"`
+	PadStartSeconds             float64 `json:"pad_start_seconds"`
+	KeepAliveSeconds            int     `json:"keepalive_seconds"`
+	WarmupIntervalSeconds       int     `json:"warmup_interval_seconds"`
+	BreakerThreshold            int     `json:"breaker_threshold"`
+	BreakerCooldownSeconds      int     `json:"breaker_cooldown_seconds"`
+	Translator                  string  `json:"translator"`
+	TranslatorURL               string  `json:"translator_url"`
+	TranslatorCommand           string  `json:"translator_command"`
+	TranslatorAPIKey            string  `json:"-"` // env only, so it never shows up in reload diffs
+	FilenameMetadataPattern     string  `json:"filename_metadata_pattern"`
+	FallbackLanguage            string  `json:"fallback_language"`
+	FallbackConfidence          float64 `json:"fallback_confidence"`
+	CacheSize                   int     `json:"cache_size"`
+	CacheTTLSeconds             int     `json:"cache_ttl_seconds"`
+	RecoverPartialOutput        bool    `json:"recover_partial_output"`
+	TextOnlyFallback            bool    `json:"text_only_fallback"`
+	HistorySize                 int     `json:"history_size"`
+	JobTTLSeconds               int     `json:"job_ttl_seconds"`
+	ResultRetentionSeconds      int     `json:"result_retention_seconds"`
+	DeleteAfterDownload         bool    `json:"delete_after_download"`
+	CacheControl                string  `json:"cache_control"`
+	TrimSilenceThresholdDB      float64 `json:"trim_silence_threshold_db"`
+	TrimSilenceKeepSeconds      float64 `json:"trim_silence_keep_seconds"`
+	MarkdownLinkTemplate        string  `json:"markdown_link_template"`
+	PreviewSeconds              float64 `json:"preview_seconds"`
+	WaveformResolution          int     `json:"waveform_resolution"`
+	CacheDir                    string  `json:"cache_dir"`
+	LongAudioThresholdSeconds   int     `json:"long_audio_threshold_seconds"`
+	LongAudioChunkSeconds       int     `json:"long_audio_chunk_seconds"`
+	LongAudioOverlapSeconds     int     `json:"long_audio_overlap_seconds"`
+	VADAggressiveness           int     `json:"vad_aggressiveness"`
+	VADMinSilenceSeconds        float64 `json:"vad_min_silence_seconds"`
+	VADPaddingSeconds           float64 `json:"vad_padding_seconds"`
+
+	// Rules for redact=true, in the order they are applied
+	RedactionRules []RedactionRule `json:"redaction_rules"`
+
+	// Models clients may request besides Model; others are refused
+	AllowedModels []string `json:"allowed_models"`
+
+	// Credits per minute of audio by model; models without an entry are not priced
+	Pricing map[string]float64 `json:"pricing"`
+
+	filenameMetadata *regexp.Regexp // compiled FilenameMetadataPattern
+	redactionRules   []compiledRule // compiled RedactionRules
+}
+
+// maxPadStartSeconds bounds the silence that can be prepended to an upload
+const maxPadStartSeconds = 5.0
+
+// activeConfig is the configuration currently in use
+var activeConfig atomic.Pointer[Config]
+
+// currentConfig returns the active configuration, loading it on first use
+func currentConfig() *Config {
+	if cfg := activeConfig.Load(); cfg != nil {
+		return cfg
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		cfg = defaultConfig()
+	}
+	activeConfig.CompareAndSwap(nil, cfg)
+	return activeConfig.Load()
+}
+
+// defaultConfig returns the built-in defaults
+func defaultConfig() *Config {
+	return &Config{
+		Model:                       "tiny", // Default to tiny model for speed and memory efficiency
+		Engine:                      transcriber.EnginePythonWhisper,
+		PersistentBridge:            true,
+		WhisperCPPPath:              "whisper-cli",
+		WhisperCPPModelDir:          "models",
+		TranscriptionTimeoutSeconds: 180,
+		MinTimeoutSeconds:           10,
+		MaxTimeoutSeconds:           1800,
+		MaxUploadMB:                 25,
+		MaxVideoUploadMB:            500,
+		MaxArchiveExtractMB:         1024,
+		MaxConcurrentJobs:           2,
+		ErrorOutputLimit:            4096,
+		StreamChunkSeconds:          30,
+		ReadingWPM:                  200,
+		DuplicatePolicy:             DuplicatePolicyOff,
+		DuplicateWindowSeconds:      10,
+		KeepAliveSeconds:            15,
+		BreakerThreshold:            5,
+		BreakerCooldownSeconds:      30,
+		FallbackConfidence:          0.5,
+		CacheSize:                   100,
+		CacheTTLSeconds:             3600,
+		RecoverPartialOutput:        true,
+		TextOnlyFallback:            true,
+		HistorySize:                 200,
+		JobTTLSeconds:               3600,
+		DownloadTimeoutSeconds:      120,
+		DownloadRetries:             2,
+		DownloadMaxRedirects:        5,
+		YTDLPPath:                   "yt-dlp",
+		MaxMediaDurationSeconds:     4 * 3600,
+		WebhookTimeoutSeconds:       10,
+		WebhookRetries:              5,
+		DatabaseURL:                 "jobs.db",
+		JobVisibilityTimeoutSeconds: 60,
+		JobMaxAttempts:              3,
+		WorkerSubject:               "transcription.jobs",
+		WorkerResultsSubject:        "transcription.results",
+		WorkerQueueGroup:            "transcription-workers",
+		TrimSilenceThresholdDB:      -50,
+		TrimSilenceKeepSeconds:      0.2,
+		MarkdownLinkTemplate:        "#t={seconds}",
+		PreviewSeconds:              30,
+		WaveformResolution:          10,
+		LongAudioThresholdSeconds:   1800,
+		LongAudioChunkSeconds:       600,
+		LongAudioOverlapSeconds:     5,
+		VADAggressiveness:           2,
+		VADMinSilenceSeconds:        2,
+		VADPaddingSeconds:           0.3,
+		RedactionRules:              defaultRedactionRules,
+	}
+}
+
+// LoadConfig reads the configuration from the environment, then overlays CONFIG_FILE
+// (a JSON object using the same keys as Config) when it is set
+func LoadConfig() (*Config, error) {
+	cfg := defaultConfig()
+
+	if model := os.Getenv("WHISPER_MODEL"); model != "" {
+		cfg.Model = model
+	}
+	if engine := os.Getenv("TRANSCRIPTION_ENGINE"); engine != "" {
+		cfg.Engine = engine
+	}
+	if path := os.Getenv("WHISPER_CPP_PATH"); path != "" {
+		cfg.WhisperCPPPath = path
+	}
+	if dir := os.Getenv("WHISPER_CPP_MODEL_DIR"); dir != "" {
+		cfg.WhisperCPPModelDir = dir
+	}
+	cfg.OverflowEngine = os.Getenv("OVERFLOW_ENGINE")
+	cfg.OpenAIAPIKey = os.Getenv("OPENAI_API_KEY")
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		cfg.OpenAIBaseURL = baseURL
+	}
+	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+		cfg.OpenAIModel = model
+	}
+	if models := os.Getenv("ALLOWED_MODELS"); models != "" {
+		cfg.AllowedModels = nil
+		for _, model := range strings.Split(models, ",") {
+			if model = strings.TrimSpace(model); model != "" {
+				cfg.AllowedModels = append(cfg.AllowedModels, model)
+			}
+		}
+	}
+	cfg.TranscriptionTimeoutSeconds = getEnvInt("TRANSCRIPTION_TIMEOUT_SECONDS", cfg.TranscriptionTimeoutSeconds)
+	cfg.RequestTimeoutSeconds = getEnvInt("REQUEST_TIMEOUT_SECONDS", cfg.RequestTimeoutSeconds)
+	cfg.MinTimeoutSeconds = getEnvInt("MIN_TIMEOUT_SECONDS", cfg.MinTimeoutSeconds)
+	cfg.MaxTimeoutSeconds = getEnvInt("MAX_TIMEOUT_SECONDS", cfg.MaxTimeoutSeconds)
+	cfg.MaxUploadMB = getEnvInt("MAX_UPLOAD_MB", cfg.MaxUploadMB)
+	cfg.MaxVideoUploadMB = getEnvInt("MAX_VIDEO_UPLOAD_MB", cfg.MaxVideoUploadMB)
+	cfg.MaxArchiveExtractMB = getEnvInt("MAX_ARCHIVE_EXTRACT_MB", cfg.MaxArchiveExtractMB)
+	cfg.MaxConcurrentJobs = getEnvInt("MAX_CONCURRENT_JOBS", cfg.MaxConcurrentJobs)
+	cfg.MaxFFmpegJobs = getEnvInt("MAX_FFMPEG_JOBS", cfg.MaxFFmpegJobs)
+	cfg.MaxQueuedJobs = getEnvInt("MAX_QUEUED_JOBS", cfg.MaxQueuedJobs)
+	cfg.DownloadTimeoutSeconds = getEnvInt("DOWNLOAD_TIMEOUT_SECONDS", cfg.DownloadTimeoutSeconds)
+	cfg.DownloadRetries = getEnvInt("DOWNLOAD_RETRIES", cfg.DownloadRetries)
+	cfg.DownloadMaxRedirects = getEnvInt("DOWNLOAD_MAX_REDIRECTS", cfg.DownloadMaxRedirects)
+	if path := os.Getenv("YTDLP_PATH"); path != "" {
+		cfg.YTDLPPath = path
+	}
+	cfg.MaxMediaDurationSeconds = getEnvInt("MAX_MEDIA_DURATION_SECONDS", cfg.MaxMediaDurationSeconds)
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		cfg.S3Endpoint = endpoint
+	}
+	cfg.WebhookTimeoutSeconds = getEnvInt("WEBHOOK_TIMEOUT_SECONDS", cfg.WebhookTimeoutSeconds)
+	cfg.WebhookRetries = getEnvInt("WEBHOOK_RETRIES", cfg.WebhookRetries)
+	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+		cfg.WebhookSecret = secret
+	}
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		cfg.DatabaseURL = databaseURL
+	}
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		cfg.RedisURL = redisURL
+	}
+	cfg.JobVisibilityTimeoutSeconds = getEnvInt("JOB_VISIBILITY_TIMEOUT_SECONDS", cfg.JobVisibilityTimeoutSeconds)
+	cfg.JobMaxAttempts = getEnvInt("JOB_MAX_ATTEMPTS", cfg.JobMaxAttempts)
+	if brokerURL := os.Getenv("BROKER_URL"); brokerURL != "" {
+		cfg.BrokerURL = brokerURL
+	}
+	if subject := os.Getenv("WORKER_SUBJECT"); subject != "" {
+		cfg.WorkerSubject = subject
+	}
+	if subject := os.Getenv("WORKER_RESULTS_SUBJECT"); subject != "" {
+		cfg.WorkerResultsSubject = subject
+	}
+	if group := os.Getenv("WORKER_QUEUE_GROUP"); group != "" {
+		cfg.WorkerQueueGroup = group
+	}
+	cfg.ErrorOutputLimit = getEnvInt("ERROR_OUTPUT_LIMIT", cfg.ErrorOutputLimit)
+	cfg.StreamChunkSeconds = getEnvInt("STREAM_CHUNK_SECONDS", cfg.StreamChunkSeconds)
+	cfg.ReadingWPM = getEnvInt("READING_WPM", cfg.ReadingWPM)
+	if policy := os.Getenv("DUPLICATE_POLICY"); policy != "" {
+		cfg.DuplicatePolicy = policy
+	}
+	cfg.DuplicateWindowSeconds = getEnvInt("DUPLICATE_WINDOW_SECONDS", cfg.DuplicateWindowSeconds)
+	cfg.KeepAliveSeconds = getEnvInt("KEEPALIVE_SECONDS", cfg.KeepAliveSeconds)
+	cfg.WarmupIntervalSeconds = getEnvInt("WARMUP_INTERVAL_SECONDS", cfg.WarmupIntervalSeconds)
+	cfg.BreakerThreshold = getEnvInt("BREAKER_THRESHOLD", cfg.BreakerThreshold)
+	cfg.BreakerCooldownSeconds = getEnvInt("BREAKER_COOLDOWN_SECONDS", cfg.BreakerCooldownSeconds)
+	cfg.CacheSize = getEnvInt("CACHE_SIZE", cfg.CacheSize)
+	cfg.CacheTTLSeconds = getEnvInt("CACHE_TTL_SECONDS", cfg.CacheTTLSeconds)
+	cfg.HistorySize = getEnvInt("HISTORY_SIZE", cfg.HistorySize)
+	cfg.JobTTLSeconds = getEnvInt("JOB_TTL_SECONDS", cfg.JobTTLSeconds)
+	cfg.ResultRetentionSeconds = getEnvInt("RESULT_RETENTION_SECONDS", cfg.ResultRetentionSeconds)
+	cfg.WaveformResolution = getEnvInt("WAVEFORM_RESOLUTION", cfg.WaveformResolution)
+	cfg.LongAudioThresholdSeconds = getEnvInt("LONG_AUDIO_THRESHOLD_SECONDS", cfg.LongAudioThresholdSeconds)
+	cfg.LongAudioChunkSeconds = getEnvInt("LONG_AUDIO_CHUNK_SECONDS", cfg.LongAudioChunkSeconds)
+	cfg.LongAudioOverlapSeconds = getEnvInt("LONG_AUDIO_OVERLAP_SECONDS", cfg.LongAudioOverlapSeconds)
+	cfg.VADAggressiveness = getEnvInt("VAD_AGGRESSIVENESS", cfg.VADAggressiveness)
+	if pad, err := strconv.ParseFloat(os.Getenv("PAD_START_SECONDS"), 64); err == nil {
+		cfg.PadStartSeconds = pad
+	}
+	if threshold, err := strconv.ParseFloat(os.Getenv("TRIM_SILENCE_THRESHOLD_DB"), 64); err == nil {
+		cfg.TrimSilenceThresholdDB = threshold
+	}
+	if keep, err := strconv.ParseFloat(os.Getenv("TRIM_SILENCE_KEEP_SECONDS"), 64); err == nil {
+		cfg.TrimSilenceKeepSeconds = keep
+	}
+	if silence, err := strconv.ParseFloat(os.Getenv("VAD_MIN_SILENCE_SECONDS"), 64); err == nil {
+		cfg.VADMinSilenceSeconds = silence
+	}
+	if padding, err := strconv.ParseFloat(os.Getenv("VAD_PADDING_SECONDS"), 64); err == nil {
+		cfg.VADPaddingSeconds = padding
+	}
+	if preview, err := strconv.ParseFloat(os.Getenv("PREVIEW_SECONDS"), 64); err == nil {
+		cfg.PreviewSeconds = preview
+	}
+	if debug, err := strconv.ParseBool(os.Getenv("DEBUG")); err == nil {
+		cfg.Debug = debug
+	}
+	if requireGPU, err := strconv.ParseBool(os.Getenv("REQUIRE_GPU")); err == nil {
+		cfg.RequireGPU = requireGPU
+	}
+	if allow, err := strconv.ParseBool(os.Getenv("ALLOW_PRIVATE_URLS")); err == nil {
+		cfg.AllowPrivateURLs = allow
+	}
+	if deleteAfterDownload, err := strconv.ParseBool(os.Getenv("DELETE_AFTER_DOWNLOAD")); err == nil {
+		cfg.DeleteAfterDownload = deleteAfterDownload
+	}
+	if recoverPartial, err := strconv.ParseBool(os.Getenv("RECOVER_PARTIAL_OUTPUT")); err == nil {
+		cfg.RecoverPartialOutput = recoverPartial
+	}
+	if textOnly, err := strconv.ParseBool(os.Getenv("TEXT_ONLY_FALLBACK")); err == nil {
+		cfg.TextOnlyFallback = textOnly
+	}
+	if persistent, err := strconv.ParseBool(os.Getenv("PERSISTENT_BRIDGE")); err == nil {
+		cfg.PersistentBridge = persistent
+	}
+	cfg.Translator = os.Getenv("TRANSLATOR")
+	cfg.TranslatorURL = os.Getenv("TRANSLATOR_URL")
+	cfg.TranslatorCommand = os.Getenv("TRANSLATOR_COMMAND")
+	cfg.TranslatorAPIKey = os.Getenv("TRANSLATOR_API_KEY")
+	cfg.FilenameMetadataPattern = os.Getenv("FILENAME_METADATA_PATTERN")
+	cfg.CacheControl = os.Getenv("CACHE_CONTROL")
+	cfg.CacheDir = os.Getenv("CACHE_DIR")
+	if template := os.Getenv("MARKDOWN_LINK_TEMPLATE"); template != "" {
+		cfg.MarkdownLinkTemplate = template
+	}
+	cfg.FallbackLanguage = os.Getenv("FALLBACK_LANGUAGE")
+	if confidence, err := strconv.ParseFloat(os.Getenv("FALLBACK_CONFIDENCE"), 64); err == nil {
+		cfg.FallbackConfidence = confidence
+	}
+
+	if rules := os.Getenv("REDACTION_RULES"); rules != "" {
+		cfg.RedactionRules = nil
+		if err := json.Unmarshal([]byte(rules), &cfg.RedactionRules); err != nil {
+			return nil, fmt.Errorf("failed to parse redaction rules: %w", err)
+		}
+	}
+
+	if pricing := os.Getenv("PRICING"); pricing != "" {
+		if err := json.Unmarshal([]byte(pricing), &cfg.Pricing); err != nil {
+			return nil, fmt.Errorf("failed to parse pricing: %w", err)
+		}
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	if cfg.TranscriptionTimeoutSeconds <= 0 || cfg.MaxUploadMB <= 0 || cfg.MaxVideoUploadMB <= 0 || cfg.MaxArchiveExtractMB <= 0 || cfg.MaxConcurrentJobs <= 0 || cfg.StreamChunkSeconds <= 0 {
+		return nil, fmt.Errorf("timeouts, limits and chunk sizes must be positive")
+	}
+	if cfg.JobTTLSeconds <= 0 {
+		return nil, fmt.Errorf("job TTL must be positive")
+	}
+	if cfg.ResultRetentionSeconds < 0 {
+		return nil, fmt.Errorf("result retention must not be negative")
+	}
+	if cfg.MinTimeoutSeconds <= 0 || cfg.MaxTimeoutSeconds < cfg.MinTimeoutSeconds {
+		return nil, fmt.Errorf("min timeout must be positive and no greater than max timeout")
+	}
+	if cfg.WaveformResolution < 1 || cfg.WaveformResolution > maxWaveformResolution {
+		return nil, fmt.Errorf("waveform resolution must be between 1 and %d peaks per second", maxWaveformResolution)
+	}
+	if cfg.PreviewSeconds <= 0 {
+		return nil, fmt.Errorf("preview seconds must be positive")
+	}
+	if cfg.PadStartSeconds < 0 || cfg.PadStartSeconds > maxPadStartSeconds {
+		return nil, fmt.Errorf("pad start must be between 0 and %g seconds", maxPadStartSeconds)
+	}
+	for model, rate := range cfg.Pricing {
+		if rate < 0 {
+			return nil, fmt.Errorf("price for model %q must not be negative", model)
+		}
+	}
+	if cfg.DownloadTimeoutSeconds <= 0 || cfg.DownloadRetries < 0 || cfg.DownloadMaxRedirects < 0 {
+		return nil, fmt.Errorf("download timeout must be positive and retries and redirects not negative")
+	}
+	if cfg.MaxMediaDurationSeconds < 0 {
+		return nil, fmt.Errorf("max media duration must not be negative")
+	}
+	if cfg.WebhookTimeoutSeconds <= 0 || cfg.WebhookRetries < 0 {
+		return nil, fmt.Errorf("webhook timeout must be positive and retries not negative")
+	}
+	if cfg.MaxFFmpegJobs < 0 || cfg.MaxQueuedJobs < 0 {
+		return nil, fmt.Errorf("max ffmpeg and queued jobs must not be negative")
+	}
+	if cfg.RedisURL != "" && !store.IsPostgresURL(cfg.DatabaseURL) {
+		// Jobs are picked up by whichever replica is free, so every replica has to see them
+		return nil, fmt.Errorf("REDIS_URL needs a shared postgres:// DATABASE_URL")
+	}
+	if cfg.JobVisibilityTimeoutSeconds <= 0 || cfg.JobMaxAttempts <= 0 {
+		return nil, fmt.Errorf("job visibility timeout and max attempts must be positive")
+	}
+	if cfg.LongAudioChunkSeconds < 0 || cfg.LongAudioOverlapSeconds < 0 {
+		return nil, fmt.Errorf("long audio chunk and overlap seconds must not be negative")
+	}
+	if cfg.LongAudioChunkSeconds > 0 && cfg.LongAudioOverlapSeconds >= cfg.LongAudioChunkSeconds {
+		return nil, fmt.Errorf("long audio overlap must be shorter than the chunks")
+	}
+	if cfg.VADAggressiveness < 0 || cfg.VADAggressiveness > 3 {
+		return nil, fmt.Errorf("VAD aggressiveness must be between 0 and 3")
+	}
+	if cfg.VADMinSilenceSeconds <= 0 || cfg.VADPaddingSeconds < 0 {
+		return nil, fmt.Errorf("VAD minimum silence must be positive and the padding at least 0 seconds")
+	}
+	if cfg.TrimSilenceThresholdDB >= 0 || cfg.TrimSilenceKeepSeconds < 0 {
+		return nil, fmt.Errorf("trim silence threshold must be below 0dB and the kept silence at least 0 seconds")
+	}
+	switch cfg.DuplicatePolicy {
+	case DuplicatePolicyOff, DuplicatePolicyReject, DuplicatePolicyAttach:
+	default:
+		return nil, fmt.Errorf("invalid duplicate policy %q (expected off, reject or attach)", cfg.DuplicatePolicy)
+	}
+	if cfg.FallbackLanguage != "" && !isSupportedLanguage(cfg.FallbackLanguage) {
+		return nil, fmt.Errorf("unsupported fallback language %q", cfg.FallbackLanguage)
+	}
+	if cfg.FallbackConfidence < 0 || cfg.FallbackConfidence > 1 {
+		return nil, fmt.Errorf("fallback confidence must be between 0 and 1")
+	}
+	if _, err := cfg.NewTranslator(); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.NewEngine(""); err != nil {
+		return nil, err
+	}
+	if cfg.OverflowEngine != "" && !cfg.IsRemoteEngine(cfg.OverflowEngine) {
+		if _, err := cfg.NewEngine(cfg.OverflowEngine); err != nil {
+			return nil, fmt.Errorf("invalid overflow engine: %w", err)
+		}
+		return nil, fmt.Errorf("overflow engine %q must be a remote engine such as openai", cfg.OverflowEngine)
+	}
+	rules, err := compileRedactionRules(cfg.RedactionRules)
+	if err != nil {
+		return nil, err
+	}
+	cfg.redactionRules = rules
+	if cfg.FilenameMetadataPattern != "" {
+		pattern, err := regexp.Compile(cfg.FilenameMetadataPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filename metadata pattern: %w", err)
+		}
+		if !slices.ContainsFunc(pattern.SubexpNames(), func(name string) bool { return name != "" }) {
+			return nil, fmt.Errorf("filename metadata pattern needs at least one named group, e.g. (?P<speaker>[a-z]+)")
+		}
+		cfg.filenameMetadata = pattern
+	}
+	return cfg, nil
+}
+
+// ModelAllowed reports whether clients may request a model: the default one always, others
+// when they are listed in ALLOWED_MODELS
+func (c *Config) ModelAllowed(name string) bool {
+	return name == c.Model || slices.Contains(c.AllowedModels, name)
+}
+
+// ModelChoices lists the models clients may request, the default first
+func (c *Config) ModelChoices() []string {
+	choices := []string{c.Model}
+	for _, model := range c.AllowedModels {
+		if !slices.Contains(choices, model) {
+			choices = append(choices, model)
+		}
+	}
+	return choices
+}
+
+// TranscriptionTimeout returns the time limit for a single transcription
+func (c *Config) TranscriptionTimeout() time.Duration {
+	return time.Duration(c.TranscriptionTimeoutSeconds) * time.Second
+}
+
+// ClampTimeout bounds a client-requested transcription timeout by MIN_TIMEOUT_SECONDS and
+// MAX_TIMEOUT_SECONDS
+func (c *Config) ClampTimeout(timeout time.Duration) time.Duration {
+	return min(max(timeout, time.Duration(c.MinTimeoutSeconds)*time.Second), time.Duration(c.MaxTimeoutSeconds)*time.Second)
+}
+
+// JobTTL returns how long finished asynchronous jobs are kept
+func (c *Config) JobTTL() time.Duration {
+	return time.Duration(c.JobTTLSeconds) * time.Second
+}
+
+// ResultRetention returns how long finished jobs and the results written for them are kept
+// before they are deleted, zero to keep them
+func (c *Config) ResultRetention() time.Duration {
+	return time.Duration(c.ResultRetentionSeconds) * time.Second
+}
+
+// JobVisibilityTimeout returns how long a queued job's claim lasts without a heartbeat
+func (c *Config) JobVisibilityTimeout() time.Duration {
+	return time.Duration(c.JobVisibilityTimeoutSeconds) * time.Second
+}
+
+// RequestTimeout returns the overall time limit for a transcription request, zero when unlimited
+func (c *Config) RequestTimeout() time.Duration {
+	return time.Duration(c.RequestTimeoutSeconds) * time.Second
+}
+
+// DuplicateWindow returns how long an in-flight request counts as a duplicate target
+func (c *Config) DuplicateWindow() time.Duration {
+	return time.Duration(c.DuplicateWindowSeconds) * time.Second
+}
+
+// KeepAliveInterval returns how often keep-alive newlines are written
+func (c *Config) KeepAliveInterval() time.Duration {
+	return time.Duration(c.KeepAliveSeconds) * time.Second
+}
+
+// WarmupInterval returns how often the model is re-warmed in the background
+func (c *Config) WarmupInterval() time.Duration {
+	return time.Duration(c.WarmupIntervalSeconds) * time.Second
+}
+
+// BreakerCooldown returns how long the circuit stays open before probing the backend
+func (c *Config) BreakerCooldown() time.Duration {
+	return time.Duration(c.BreakerCooldownSeconds) * time.Second
+}
+
+// NewTranslator creates the configured translation backend, or nil when translation is off
+func (c *Config) NewTranslator() (translate.Translator, error) {
+	return translate.New(c.Translator, c.TranslatorURL, c.TranslatorAPIKey, c.TranslatorCommand)
+}
+
+// NewEngine creates the named transcription engine, or the configured one for an empty name
+func (c *Config) NewEngine(name string) (transcriber.Engine, error) {
+	if name == "" {
+		name = c.Engine
+	}
+	script, err := bridgeScriptPath()
+	if err != nil {
+		return nil, err
+	}
+	return transcriber.New(name, transcriber.Settings{
+		BridgeScript:     script,
+		WhisperCPPPath:   c.WhisperCPPPath,
+		WhisperCPPModels: c.WhisperCPPModelDir,
+		OpenAIAPIKey:     c.OpenAIAPIKey,
+		OpenAIBaseURL:    c.OpenAIBaseURL,
+		OpenAIModel:      c.OpenAIModel,
+		BridgeWorkers:    bridgeWorkers,
+	})
+}
+
+// IsRemoteEngine reports whether the named engine, or the configured one for an empty name,
+// is configured and runs off this machine
+func (c *Config) IsRemoteEngine(name string) bool {
+	engine, err := c.NewEngine(name)
+	return err == nil && transcriber.IsRemote(engine)
+}
+
+// CacheTTL returns how long a cached transcription stays valid
+func (c *Config) CacheTTL() time.Duration {
+	return time.Duration(c.CacheTTLSeconds) * time.Second
+}
+
+// NewDownloader creates a downloader for remote media with the configured timeout,
+// retries, redirect limit and address policy
+func (c *Config) NewDownloader(maxBytes int64) *fetch.Downloader {
+	return &fetch.Downloader{
+		Timeout:      time.Duration(c.DownloadTimeoutSeconds) * time.Second,
+		Retries:      c.DownloadRetries,
+		MaxRedirects: c.DownloadMaxRedirects,
+		MaxBytes:     maxBytes,
+		AllowPrivate: c.AllowPrivateURLs,
+	}
+}
+
+// NewNotifier creates a webhook notifier with the configured timeout, retries and secret
+func (c *Config) NewNotifier() *fetch.Notifier {
+	return &fetch.Notifier{
+		Timeout:      time.Duration(c.WebhookTimeoutSeconds) * time.Second,
+		Retries:      c.WebhookRetries,
+		Backoff:      time.Second,
+		Secret:       c.WebhookSecret,
+		AllowPrivate: c.AllowPrivateURLs,
+	}
+}
+
+// NewYTDLP creates a yt-dlp downloader for video site links with the configured binary,
+// download timeout and duration limit
+func (c *Config) NewYTDLP(maxBytes int64) *fetch.YTDLP {
+	return &fetch.YTDLP{
+		Binary:      c.YTDLPPath,
+		Timeout:     time.Duration(c.DownloadTimeoutSeconds) * time.Second,
+		MaxDuration: time.Duration(c.MaxMediaDurationSeconds) * time.Second,
+		MaxBytes:    maxBytes,
+	}
+}
+
+// MaxUploadBytes returns the largest accepted upload in bytes
+func (c *Config) MaxUploadBytes() int64 {
+	return int64(c.MaxUploadMB) * 1024 * 1024
+}
+
+// configChanges lists the settings that differ between two configurations
+func configChanges(previous, next *Config) map[string]map[string]any {
+	var before, after map[string]any
+	previousJSON, _ := json.Marshal(previous)
+	nextJSON, _ := json.Marshal(next)
+	_ = json.Unmarshal(previousJSON, &before)
+	_ = json.Unmarshal(nextJSON, &after)
+
+	changes := make(map[string]map[string]any)
+	for key, value := range after {
+		if fmt.Sprint(before[key]) != fmt.Sprint(value) {
+			changes[key] = map[string]any{"old": before[key], "new": value}
+		}
+	}
+	return changes
+}
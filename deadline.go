@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestDeadline bounds the whole request (upload, preprocessing and transcription) by
+// REQUEST_TIMEOUT_SECONDS, answering 408 when the handler runs out of time
+func requestDeadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := currentConfig().RequestTimeout()
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// Body reads don't watch the context, so a slow upload needs a read deadline as well
+		_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(timeout))
+
+		c.Next()
+
+		if !c.Writer.Written() && requestExpired(c) {
+			writeError(c, c.Request.Context().Err())
+		}
+	}
+}
+
+// requestExpired reports whether the request ran past its overall deadline
+func requestExpired(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)
+}
+
+// clientGone reports whether the client disconnected, in which case there is nobody to answer
+func clientGone(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.Canceled)
+}
+
+// requestError replaces whatever failed once the request deadline passed with a 408,
+// since the underlying error is only a symptom of running out of time
+func requestError(c *gin.Context, err error) error {
+	if !requestExpired(c) {
+		return err
+	}
+	return &APIError{
+		Status:  http.StatusRequestTimeout,
+		Code:    "request_timeout",
+		Message: fmt.Sprintf("Request took longer than the %v limit", currentConfig().RequestTimeout()),
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/audio"
+)
+
+// languageIDSeconds is how much audio Whisper's language identification looks at
+const languageIDSeconds = 30
+
+// LanguageCandidate is a language Whisper considers likely
+type LanguageCandidate struct {
+	Language    string  `json:"language"`
+	Probability float64 `json:"probability"`
+}
+
+// LanguageDetection is the bridge's language identification result
+type LanguageDetection struct {
+	Language    string              `json:"language"`
+	Probability float64             `json:"probability"`
+	Candidates  []LanguageCandidate `json:"candidates"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// runLanguageDetection runs only the bridge's language identification pass on a clip
+func runLanguageDetection(ctx context.Context, clipPath, model string) (LanguageDetection, error) {
+	outputPath := clipPath + ".json"
+
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().TranscriptionTimeout())
+	defer cancel()
+
+	output, err := runBridge(ctx, "--input", clipPath, "--output", outputPath, "--model", model, "--detect-language")
+	if ctx.Err() == context.DeadlineExceeded {
+		return LanguageDetection{}, &APIError{Status: http.StatusRequestTimeout, Code: "transcription_timeout", Message: "Language detection timed out"}
+	}
+	if err != nil {
+		log.Printf("Language detection failed: %v\n%s", err, output)
+	}
+
+	data, readErr := os.ReadFile(outputPath)
+	if readErr != nil {
+		return LanguageDetection{}, &APIError{Status: http.StatusInternalServerError, Message: "Language detection failed", Output: string(output)}
+	}
+	var detection LanguageDetection
+	if err := json.Unmarshal(data, &detection); err != nil {
+		return LanguageDetection{}, &APIError{Status: http.StatusInternalServerError, Message: "Failed to parse language detection output", Details: err.Error()}
+	}
+	if detection.Error != "" || detection.Language == "" {
+		return LanguageDetection{}, &APIError{Status: http.StatusInternalServerError, Message: "Language detection failed", Details: detection.Error}
+	}
+	return detection, nil
+}
+
+// handleDetectLanguage identifies the spoken language from the first 30 seconds of an upload
+// without transcribing it, for routing files to per-language pipelines
+func (s *Service) handleDetectLanguage(c *gin.Context) {
+	startTime := time.Now()
+
+	file, err := c.FormFile("audio")
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "No audio file provided"))
+		return
+	}
+	if err := validateUpload(file); err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	priority, err := ParsePriority(formValue(c, "priority"))
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	model, err := requestedModel(formValue(c, "model"))
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	tmpDir, err := makeTempDir("audio-detect")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	audioPath, err := saveUpload(c, file, tmpDir)
+	if err != nil {
+		if isStorageError(err) {
+			writeError(c, storageError(err))
+			return
+		}
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to save uploaded file"))
+		return
+	}
+
+	// Only the opening seconds are decoded, so long files cost the same as short ones
+	ctx := c.Request.Context()
+	clipPath := filepath.Join(tmpDir, "clip.wav")
+	if err := audio.Clip(ctx, audioPath, clipPath, 0, languageIDSeconds); err != nil {
+		log.Printf("Error clipping audio for language detection: %v", err)
+		writeError(c, &APIError{Status: http.StatusUnprocessableEntity, Code: "unreadable_audio", Message: "Could not read audio", Details: err.Error()})
+		return
+	}
+
+	if err := s.Models.Require(ctx, model); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := s.Breaker.Allow(); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := s.Scheduler.Acquire(ctx, priority); err != nil {
+		s.Breaker.Release()
+		if clientGone(c) {
+			return
+		}
+		writeError(c, requestError(c, err))
+		return
+	}
+	detection, err := runLanguageDetection(ctx, clipPath, model)
+	s.Scheduler.Release()
+	s.Breaker.Record(err)
+	if err != nil {
+		if clientGone(c) {
+			return
+		}
+		writeError(c, requestError(c, err))
+		return
+	}
+
+	log.Printf("Detected language %s (%.2f) in %s", detection.Language, detection.Probability, file.Filename)
+	c.JSON(http.StatusOK, gin.H{
+		"language":                detection.Language,
+		"probability":             detection.Probability,
+		"candidates":              detection.Candidates,
+		"model":                   model,
+		"processing_time_seconds": time.Since(startTime).Seconds(),
+	})
+}
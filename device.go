@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// version is the build version, set with -ldflags "-X main.version=..."
+var version = "dev"
+
+// DeviceInfo describes the compute device the Python bridge runs on
+type DeviceInfo struct {
+	Device        string `json:"device"`
+	CUDAAvailable bool   `json:"cuda_available"`
+	TorchVersion  string `json:"torch_version,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// gpuDevices are the torch device types that run on a GPU; ROCm builds report theirs as cuda
+var gpuDevices = []string{"cuda", "mps", "xpu"}
+
+// IsGPU reports whether the device is a GPU, with or without an index like cuda:1
+func (d DeviceInfo) IsGPU() bool {
+	kind, _, _ := strings.Cut(d.Device, ":")
+	return slices.Contains(gpuDevices, kind)
+}
+
+// detectDevice asks the bridge which device it would transcribe on
+func detectDevice(ctx context.Context) (DeviceInfo, error) {
+	scriptPath, err := bridgeScriptPath()
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "python3", scriptPath, "--check-device").Output()
+	if err != nil {
+		return DeviceInfo{}, fmt.Errorf("device check failed: %w", err)
+	}
+
+	// The bridge prints its report as the last line of stdout
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var info DeviceInfo
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &info); err != nil {
+		return DeviceInfo{}, fmt.Errorf("failed to parse device report: %w", err)
+	}
+	return info, nil
+}
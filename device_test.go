@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestDeviceInfoIsGPU(t *testing.T) {
+	tests := []struct {
+		device string
+		gpu    bool
+	}{
+		{"cuda", true},
+		{"cuda:1", true},
+		{"mps", true},
+		{"xpu", true},
+		{"cpu", false},
+		{"unknown", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := (DeviceInfo{Device: tt.device}).IsGPU(); got != tt.gpu {
+			t.Errorf("DeviceInfo{Device: %q}.IsGPU() = %v, want %v", tt.device, got, tt.gpu)
+		}
+	}
+}
+
+func TestLoadConfigRequireGPU(t *testing.T) {
+	for value, want := range map[string]bool{"true": true, "1": true, "TRUE": true, "false": false, "0": false, "": false} {
+		t.Setenv("REQUIRE_GPU", value)
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.RequireGPU != want {
+			t.Errorf("REQUIRE_GPU=%q gives RequireGPU %v, want %v", value, cfg.RequireGPU, want)
+		}
+	}
+}
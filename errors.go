@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is a failed request along with the HTTP status and error code to report
+type APIError struct {
+	Status  int
+	Code    string // machine-readable code, derived from the status when empty
+	Message string
+	Output  string
+	Details string
+	Fields  gin.H // extra fields for JSON clients
+
+	RetryAfter time.Duration // sent as a Retry-After header when set
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError creates an error with the given status and message
+func newAPIError(status int, message string) *APIError {
+	return &APIError{Status: status, Message: message}
+}
+
+// errorCode returns the machine-readable code for an error
+func (e *APIError) errorCode() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(e.Status)), " ", "_")
+}
+
+// asAPIError converts any error into an APIError, treating unknown errors as internal
+func asAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return &APIError{Status: http.StatusInternalServerError, Message: err.Error()}
+}
+
+// errorBody converts an error into the JSON body returned to clients
+func errorBody(err error) (int, gin.H) {
+	apiErr := asAPIError(err)
+
+	body := gin.H{}
+	for key, value := range apiErr.Fields {
+		body[key] = value
+	}
+	body["error"] = apiErr.Message
+	body["code"] = apiErr.errorCode()
+	if apiErr.Output != "" {
+		body["output"] = truncateOutput(apiErr.Output)
+	}
+	if apiErr.Details != "" {
+		body["details"] = apiErr.Details
+	}
+	if servedBy != "" {
+		body["served_by"] = servedBy
+	}
+	return apiErr.Status, body
+}
+
+// writeError renders an error for the client: a plain message for clients that ask for
+// text/plain, the structured JSON body for everyone else
+func writeError(c *gin.Context, err error) {
+	err = requestError(c, err)
+	if retryAfter := asAPIError(err).RetryAfter; retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		apiErr := asAPIError(err)
+		c.String(apiErr.Status, apiErr.Message)
+		return
+	}
+	c.JSON(errorBody(err))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// meanConfidence returns the duration-weighted confidence of a transcription, reporting
+// false when the backend gave no confidences
+func meanConfidence(segments []TranscriptionSegment) (float64, bool) {
+	var total, weight float64
+	for _, segment := range segments {
+		if segment.Confidence == nil {
+			continue
+		}
+		duration := max(segment.EndTime-segment.StartTime, 0.001)
+		total += *segment.Confidence * duration
+		weight += duration
+	}
+	if weight == 0 {
+		return 0, false
+	}
+	return total / weight, true
+}
+
+// retryWithFallbackLanguage re-runs an auto-detected transcription with the fallback language
+// forced when its confidence is below the threshold, keeping whichever run scored higher
+func retryWithFallbackLanguage(ctx context.Context, audioPath string, opts TranscribeOptions, response TranscriptionResponse) TranscriptionResponse {
+	if opts.FallbackLanguage == "" || len(opts.Languages) > 0 || response.Language == opts.FallbackLanguage {
+		return response
+	}
+	confidence, ok := meanConfidence(response.Segments)
+	if !ok || confidence >= opts.FallbackConfidence {
+		return response
+	}
+
+	log.Printf("Confidence %.2f for detected language %q is below %.2f, retrying with %q",
+		confidence, response.Language, opts.FallbackConfidence, opts.FallbackLanguage)
+
+	retryOpts := opts
+	retryOpts.Languages = []string{opts.FallbackLanguage}
+	retry, err := runTranscription(ctx, audioPath, retryOpts)
+	if err != nil {
+		log.Printf("Fallback language retry failed: %v", err)
+		response.Warnings = append(response.Warnings, fmt.Sprintf("Retry with fallback language %s failed", opts.FallbackLanguage))
+		return response
+	}
+
+	retryConfidence, _ := meanConfidence(retry.Segments)
+	if retryConfidence <= confidence {
+		response.Warnings = append(response.Warnings, fmt.Sprintf(
+			"Retried with fallback language %s (confidence %.2f) but kept detected %s (confidence %.2f)",
+			opts.FallbackLanguage, retryConfidence, response.Language, confidence))
+		return response
+	}
+
+	retry.Warnings = append(retry.Warnings, fmt.Sprintf(
+		"Detected %s had low confidence (%.2f); used fallback language %s (confidence %.2f)",
+		response.Language, confidence, opts.FallbackLanguage, retryConfidence))
+	return retry
+}
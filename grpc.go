@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"transription-service/internal/pb"
+)
+
+// grpcServer serves the Transcription gRPC service with the same pipeline as the HTTP API
+type grpcServer struct {
+	pb.UnimplementedTranscriptionServer
+	service *Service
+}
+
+// startGRPCServer listens on GRPC_PORT next to the HTTP server; an empty port disables it
+func (s *Service) startGRPCServer() error {
+	port := os.Getenv("GRPC_PORT")
+	if port == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	// Whole files arrive in one message, so the limit follows the upload limits
+	maxMessageBytes := (max(currentConfig().MaxUploadMB, currentConfig().MaxVideoUploadMB) + 1) * 1024 * 1024
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxMessageBytes),
+		grpc.UnaryInterceptor(s.grpcUnaryAuth),
+		grpc.StreamInterceptor(s.grpcStreamAuth),
+	)
+	pb.RegisterTranscriptionServer(server, &grpcServer{service: s})
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+	log.Println("Starting gRPC server on port " + port + "...")
+	return nil
+}
+
+// grpcAPIKey reads the key from the x-api-key or authorization bearer metadata
+func grpcAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("x-api-key"); len(keys) > 0 && keys[0] != "" {
+		return keys[0]
+	}
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// grpcAuthorize checks the caller's API key like requireAPIKey does for HTTP
+func (s *Service) grpcAuthorize(ctx context.Context) error {
+	if s.Keys == nil {
+		return nil
+	}
+	key := grpcAPIKey(ctx)
+	if key == "" {
+		return status.Error(codes.Unauthenticated, "API key required")
+	}
+	valid, err := s.Keys.Validate(ctx, key)
+	if err != nil {
+		log.Printf("API key validation failed: %v", err)
+		return status.Error(codes.Unavailable, "Could not validate API key")
+	}
+	if !valid {
+		return status.Error(codes.Unauthenticated, "Invalid API key")
+	}
+	return nil
+}
+
+func (s *Service) grpcUnaryAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.grpcAuthorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Service) grpcStreamAuth(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.grpcAuthorize(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// grpcStatusCodes maps the HTTP status of API errors to gRPC codes
+var grpcStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusRequestTimeout:        codes.DeadlineExceeded,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcError turns a pipeline error into a gRPC status with the same message as the HTTP API
+func grpcError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, "request canceled")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "request deadline exceeded")
+	}
+	apiErr := asAPIError(err)
+	code, ok := grpcStatusCodes[apiErr.Status]
+	if !ok {
+		code = codes.Internal
+	}
+	message := apiErr.Message
+	if apiErr.Details != "" {
+		message += ": " + apiErr.Details
+	}
+	return status.Error(code, message)
+}
+
+// grpcOptions builds the transcription options of a gRPC request, with the same defaults
+// as the HTTP API for everything the request can't set
+func grpcOptions(req *pb.TranscribeRequest) (TranscribeOptions, error) {
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+	model, err := requestedModel(req.Model)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+	for _, language := range req.Languages {
+		if !isSupportedLanguage(language) {
+			return TranscribeOptions{}, fmt.Errorf("unsupported language %q", language)
+		}
+	}
+	var timeout time.Duration
+	if req.TimeoutSeconds < 0 {
+		return TranscribeOptions{}, fmt.Errorf("invalid timeout_seconds %g", req.TimeoutSeconds)
+	}
+	if req.TimeoutSeconds > 0 {
+		timeout = currentConfig().ClampTimeout(time.Duration(req.TimeoutSeconds * float64(time.Second)))
+	}
+
+	cfg := currentConfig()
+	return TranscribeOptions{
+		Model:              model,
+		Priority:           priority,
+		Punctuate:          req.Punctuate,
+		Diarize:            req.Diarize,
+		Languages:          req.Languages,
+		FallbackLanguage:   cfg.FallbackLanguage,
+		FallbackConfidence: cfg.FallbackConfidence,
+		AudioStream:        -1,
+		PadStart:           cfg.PadStartSeconds,
+		Timeout:            timeout,
+		NBest:              1,
+		Precision:          -1,
+		Filename:           req.Filename,
+	}, nil
+}
+
+// saveGRPCAudio validates the request's file and writes it to dir
+func saveGRPCAudio(req *pb.TranscribeRequest, dir string) (string, error) {
+	name := filepath.Base(req.Filename)
+	if len(req.Audio) == 0 {
+		return "", status.Error(codes.InvalidArgument, "No audio provided")
+	}
+	if !isSupportedAudioFile(name) {
+		return "", status.Errorf(codes.InvalidArgument, "unsupported file type %q (set filename with an audio or video extension)", filepath.Ext(name))
+	}
+	if exceedsUploadLimit(name, int64(len(req.Audio))) {
+		return "", status.Errorf(codes.InvalidArgument, "File too large (max %dMB)", uploadLimitMB(name))
+	}
+
+	audioPath := filepath.Join(dir, name)
+	if err := os.WriteFile(audioPath, req.Audio, 0o600); err != nil {
+		if isStorageError(err) {
+			return "", grpcError(storageError(err))
+		}
+		return "", status.Error(codes.Internal, "Failed to save audio")
+	}
+	return audioPath, nil
+}
+
+// run saves and transcribes a request, reporting progress on ctx
+func (g *grpcServer) run(ctx context.Context, req *pb.TranscribeRequest) (TranscriptionResponse, TranscribeOptions, time.Duration, error) {
+	startTime := time.Now()
+
+	opts, err := grpcOptions(req)
+	if err != nil {
+		return TranscriptionResponse{}, TranscribeOptions{}, 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+	tmpDir, err := makeTempDir("audio-grpc")
+	if err != nil {
+		return TranscriptionResponse{}, TranscribeOptions{}, 0, grpcError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	audioPath, err := saveGRPCAudio(req, tmpDir)
+	if err != nil {
+		return TranscriptionResponse{}, TranscribeOptions{}, 0, err
+	}
+	reportStage(ctx, "upload_saved")
+
+	response, err := g.service.transcribe(ctx, audioPath, opts)
+	if err != nil {
+		return TranscriptionResponse{}, TranscribeOptions{}, 0, grpcError(err)
+	}
+	duration := time.Since(startTime)
+	log.Printf("gRPC transcription of %s completed in %v with %d segments", req.Filename, duration, len(response.Segments))
+	return response, opts, duration, nil
+}
+
+// Transcribe transcribes a file and returns the whole result
+func (g *grpcServer) Transcribe(ctx context.Context, req *pb.TranscribeRequest) (*pb.TranscriptionResponse, error) {
+	response, opts, duration, err := g.run(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoResponse(response, duration, opts), nil
+}
+
+// TranscribeStream transcribes a file, streaming each stage and segment as the pipeline
+// reaches it and the whole result last
+func (g *grpcServer) TranscribeStream(req *pb.TranscribeRequest, stream pb.Transcription_TranscribeStreamServer) error {
+	// Progress arrives from the bridge's output reader and the pipeline, so sends are serialized
+	var mu sync.Mutex
+	var sendErr error
+	fraction := 0.0
+	send := func(chunk *pb.SegmentChunk) {
+		if sendErr == nil {
+			sendErr = stream.Send(chunk)
+		}
+	}
+
+	ctx := withProgress(stream.Context(), func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		chunk := &pb.SegmentChunk{Stage: event.Stage}
+		if event.Stage != "" {
+			fraction = max(fraction, stageProgress[event.Stage])
+		}
+		if event.Segment != nil {
+			segment := event.Segment
+			chunk.Segment = &pb.TranscriptionSegment{
+				Text:      segment.Text,
+				StartTime: segment.StartTime,
+				EndTime:   segment.EndTime,
+				Speaker:   segment.Speaker,
+				Language:  segment.Language,
+			}
+			decoding, finishing := stageProgress["decoding"], stageProgress["finishing"]
+			fraction = max(fraction, decoding+(finishing-decoding)*event.Fraction)
+		}
+		chunk.Progress = fraction
+		send(chunk)
+	})
+
+	response, opts, duration, err := g.run(ctx, req)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	send(&pb.SegmentChunk{Progress: 1, Result: toProtoResponse(response, duration, opts)})
+	return sendErr
+}
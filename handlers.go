@@ -0,0 +1,622 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/audio"
+	"transription-service/internal/auth"
+	"transription-service/internal/fetch"
+	"transription-service/internal/formats"
+	"transription-service/internal/queue"
+	"transription-service/internal/storage"
+	"transription-service/internal/transcriber"
+)
+
+// Service holds the shared state behind the HTTP handlers
+type Service struct {
+	Scheduler *Scheduler
+	Warmer    *Warmer
+	Inflight  *InflightTracker
+	Breaker   *CircuitBreaker
+	Uploads   *UploadStore
+	Models    *ModelCatalog
+	Cache     *ResultCache
+	History   *HistoryStore
+	Device    DeviceInfo
+	Keys      auth.Validator // nil when API keys are not required
+	Jobs      *JobStore
+	Storage   storage.Store // nil when object storage could not be set up
+	Queue     *queue.Redis  // shared job queue, nil to run jobs on the replica that accepted them
+}
+
+// formValue reads a request option from the form body, falling back to the query string
+func formValue(c *gin.Context, key string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	return c.Query(key)
+}
+
+// parseTranscribeOptions reads the per-request transcription options from the form
+func parseTranscribeOptions(c *gin.Context) (TranscribeOptions, error) {
+	priority, err := ParsePriority(formValue(c, "priority"))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	format := formValue(c, "format")
+	if format == "" {
+		format = acceptedFormat(c)
+	}
+	if !isSupportedFormat(format) {
+		return TranscribeOptions{}, fmt.Errorf("unsupported format %q", format)
+	}
+
+	vttSettings := formats.VTTSettings{
+		Align:    formValue(c, "vtt_align"),
+		Line:     formValue(c, "vtt_line"),
+		Position: formValue(c, "vtt_position"),
+		Size:     formValue(c, "vtt_size"),
+	}
+	if err := vttSettings.Validate(); err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	maxLineChars, err := intFormValue(c, "max_line_chars", 42)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+	maxLines, err := intFormValue(c, "max_lines", 2)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	padStart, err := floatFormValue(c, "pad_start", currentConfig().PadStartSeconds, maxPadStartSeconds)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	tail, err := floatFormValue(c, "tail", 0, math.Inf(1))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	// A preview only transcribes the start of the recording for a quick check
+	var previewSeconds float64
+	if formValue(c, "preview") == "true" || formValue(c, "preview_seconds") != "" {
+		previewSeconds, err = floatFormValue(c, "preview_seconds", currentConfig().PreviewSeconds, math.Inf(1))
+		if err != nil || previewSeconds == 0 {
+			return TranscribeOptions{}, fmt.Errorf("invalid preview_seconds %q (expected a positive number)", formValue(c, "preview_seconds"))
+		}
+		if tail > 0 {
+			return TranscribeOptions{}, fmt.Errorf("preview and tail cannot be combined")
+		}
+	}
+
+	var waveformResolution int
+	if formValue(c, "include_waveform") == "true" || formValue(c, "waveform_resolution") != "" {
+		waveformResolution, err = intFormValue(c, "waveform_resolution", currentConfig().WaveformResolution)
+		if err != nil || waveformResolution < 1 || waveformResolution > maxWaveformResolution {
+			return TranscribeOptions{}, fmt.Errorf("invalid waveform_resolution %q (expected 1 to %d)", formValue(c, "waveform_resolution"), maxWaveformResolution)
+		}
+	}
+
+	// Clients may shorten or extend the time limit within the server's bounds
+	var timeout time.Duration
+	if value := formValue(c, "timeout"); value != "" {
+		timeout, err = parseTimeout(value)
+		if err != nil {
+			return TranscribeOptions{}, err
+		}
+		timeout = currentConfig().ClampTimeout(timeout)
+	}
+
+	languages, err := parseLanguageList(formValue(c, "languages"))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	model, err := requestedModel(formValue(c, "model"))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	// Besides the configured engine, clients may pick a configured remote one such as openai
+	engine := formValue(c, "engine")
+	if engine == currentConfig().Engine {
+		engine = ""
+	}
+	if engine != "" && !currentConfig().IsRemoteEngine(engine) {
+		return TranscribeOptions{}, fmt.Errorf("engine %q is not available on this server", engine)
+	}
+
+	// A language hint skips detection: the bridge takes a single candidate as given
+	if language := strings.ToLower(strings.TrimSpace(formValue(c, "language"))); language != "" && language != "auto" {
+		if len(languages) > 0 {
+			return TranscribeOptions{}, fmt.Errorf("language and languages can't be combined")
+		}
+		if !isSupportedLanguage(language) {
+			return TranscribeOptions{}, fmt.Errorf("unsupported language %q", language)
+		}
+		languages = []string{language}
+	}
+
+	translateTo := formValue(c, "translate_to")
+	if translateTo != "" {
+		if !isSupportedLanguage(translateTo) {
+			return TranscribeOptions{}, fmt.Errorf("unsupported translate_to language %q", translateTo)
+		}
+		if currentConfig().Translator == "" {
+			return TranscribeOptions{}, fmt.Errorf("translation is not enabled on this server")
+		}
+	}
+
+	precision, err := intFormValue(c, "timestamp_precision", -1)
+	if err != nil || precision > maxTimestampPrecision {
+		return TranscribeOptions{}, fmt.Errorf("invalid timestamp_precision %q (expected 0 to %d)", formValue(c, "timestamp_precision"), maxTimestampPrecision)
+	}
+
+	fallbackLanguage := formValue(c, "fallback_language")
+	if fallbackLanguage == "" {
+		fallbackLanguage = currentConfig().FallbackLanguage
+	} else if !isSupportedLanguage(fallbackLanguage) {
+		return TranscribeOptions{}, fmt.Errorf("unsupported fallback_language %q", fallbackLanguage)
+	}
+	fallbackConfidence, err := floatFormValue(c, "fallback_confidence", currentConfig().FallbackConfidence, 1)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	maxSegmentDuration, err := floatFormValue(c, "max_segment_duration", 0, math.Inf(1))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	script := formValue(c, "script")
+	switch script {
+	case "", "simplified", "traditional":
+	default:
+		return TranscribeOptions{}, fmt.Errorf("invalid script %q (expected simplified or traditional)", script)
+	}
+
+	// Transcribing is the default, so it is left empty to share cache entries with requests without task
+	task := formValue(c, "task")
+	switch task {
+	case "", "translate":
+	case "transcribe":
+		task = ""
+	default:
+		return TranscribeOptions{}, fmt.Errorf("invalid task %q (expected transcribe or translate)", task)
+	}
+
+	nBest, err := intFormValue(c, "n_best", 1)
+	if err != nil || nBest < 1 || nBest > maxNBest {
+		return TranscribeOptions{}, fmt.Errorf("invalid n_best %q (expected 1 to %d)", formValue(c, "n_best"), maxNBest)
+	}
+
+	audioStream, err := intFormValue(c, "audio_stream", -1)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	channel, err := parseChannel(formValue(c, "channel"))
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	var search *transcriber.SegmentFilter
+	if query := formValue(c, "search"); query != "" {
+		var useRegex bool
+		switch mode := formValue(c, "search_mode"); mode {
+		case "", "substring":
+		case "regex":
+			useRegex = true
+		default:
+			return TranscribeOptions{}, fmt.Errorf("invalid search_mode %q (expected substring or regex)", mode)
+		}
+		if search, err = transcriber.NewSegmentFilter(query, useRegex); err != nil {
+			return TranscribeOptions{}, err
+		}
+	}
+
+	// Sub-second buckets would mostly be empty padding on long recordings
+	bucketSeconds, err := floatFormValue(c, "bucket_seconds", 0, math.Inf(1))
+	if err != nil || (bucketSeconds > 0 && bucketSeconds < 1) {
+		return TranscribeOptions{}, fmt.Errorf("invalid bucket_seconds %q (expected at least 1)", formValue(c, "bucket_seconds"))
+	}
+
+	postProcess, err := parsePostProcess(formValue(c, "postprocess"), maxSegmentDuration, search)
+	if err != nil {
+		return TranscribeOptions{}, err
+	}
+
+	var output *storage.Location
+	if value := formValue(c, "output_bucket"); value != "" {
+		prefix, err := storage.ParsePrefix(value)
+		if err != nil {
+			return TranscribeOptions{}, err
+		}
+		output = &prefix
+	}
+
+	callbackURL := formValue(c, "callback_url")
+	if callbackURL != "" && !fetch.ValidCallbackURL(callbackURL) {
+		return TranscribeOptions{}, fmt.Errorf("invalid callback_url %q (expected an http or https URL)", callbackURL)
+	}
+
+	sampleRate := 0
+	if formValue(c, "sample_offsets") == "true" {
+		if sampleRate, err = intFormValue(c, "sample_rate", audio.SampleRate); err != nil || sampleRate == 0 {
+			return TranscribeOptions{}, fmt.Errorf("invalid sample_rate %q", formValue(c, "sample_rate"))
+		}
+	}
+
+	return TranscribeOptions{
+		Model:              model,
+		Engine:             engine,
+		Priority:           priority,
+		Punctuate:          formValue(c, "punctuate") == "true",
+		Diarize:            formValue(c, "diarize") == "true",
+		SplitSpeakers:      formValue(c, "split_speakers") == "true",
+		SpeakerSRT:         formValue(c, "speaker_srt") == "true",
+		SegmentLanguage:    formValue(c, "segment_language") == "true",
+		Languages:          languages,
+		EstimateSpeakers:   formValue(c, "estimate_speakers") == "true",
+		PadStart:           padStart,
+		Tail:               tail,
+		PreviewSeconds:     previewSeconds,
+		TrimSilence:        formValue(c, "trim_silence") == "true",
+		VAD:                formValue(c, "vad") == "true",
+		SampleRate:         sampleRate,
+		KeepAlive:          formValue(c, "keepalive") == "true",
+		FormattedTimes:     formValue(c, "formatted_times") == "true",
+		Timeout:            timeout,
+		DialogueTimestamps: formValue(c, "dialogue_timestamps") != "false",
+		Task:               task,
+		TranslateTo:        translateTo,
+		Search:             search,
+		MaxSegmentDuration: maxSegmentDuration,
+		PostProcess:        postProcess,
+		BucketSeconds:      bucketSeconds,
+		NBest:              nBest,
+		Script:             script,
+		WaveformResolution: waveformResolution,
+		Channel:            channel,
+		AudioStream:        audioStream,
+		Redact:             formValue(c, "redact") == "true",
+		FallbackLanguage:   fallbackLanguage,
+		FallbackConfidence: fallbackConfidence,
+		Precision:          precision,
+		Format:             format,
+		Wrap:               formats.WrapOptions{MaxLineChars: maxLineChars, MaxLines: maxLines},
+		VTTSettings:        vttSettings,
+		Output:             output,
+		CallbackURL:        callbackURL,
+	}, nil
+}
+
+// parseTimeout reads a duration such as 90s or 2m, or a plain number of seconds
+func parseTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, fmt.Errorf("invalid timeout %q (expected a duration like 90s or a number of seconds)", value)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q (expected a positive duration)", value)
+	}
+	return timeout, nil
+}
+
+// floatFormValue reads a number of seconds between zero and the limit, using the fallback when it is absent
+func floatFormValue(c *gin.Context, key string, fallback, limit float64) (float64, error) {
+	value := formValue(c, key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 || n > limit || math.IsInf(n, 0) {
+		if math.IsInf(limit, 1) {
+			return 0, fmt.Errorf("invalid %s %q (expected a non-negative number)", key, value)
+		}
+		return 0, fmt.Errorf("invalid %s %q (expected 0 to %g)", key, value, limit)
+	}
+	return n, nil
+}
+
+// intFormValue reads a non-negative integer option, using the fallback when it is absent
+func intFormValue(c *gin.Context, key string, fallback int) (int, error) {
+	value := formValue(c, key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return n, nil
+}
+
+// transcribe preprocesses the audio, waits for a free slot and runs the transcription.
+// Repeated audio with the same options is answered from the result cache.
+func (s *Service) transcribe(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	// The checksum is returned to clients and doubles as the cache key
+	checksum, err := fileSHA256(audioPath)
+	if err != nil {
+		log.Printf("Could not checksum audio: %v", err)
+	}
+
+	var key string
+	if !opts.NoCache && checksum != "" {
+		key = cacheKey(checksum, opts)
+		if response, ok := s.Cache.Get(key); ok {
+			response.Cached = true
+			response.AudioSHA256 = checksum
+			return response, nil
+		}
+	}
+
+	run := s.runPipeline
+	if opts.Channel != "" {
+		run = s.transcribeChannels
+	}
+	response, err := run(ctx, audioPath, opts)
+	if err != nil {
+		return TranscriptionResponse{}, err
+	}
+	response.AudioSHA256 = checksum
+	reportStage(ctx, "finishing")
+
+	// Credits are charged on the length of the upload, so it is probed while the file is around
+	if len(currentConfig().Pricing) > 0 {
+		response.DurationSeconds = audioDuration(ctx, audioPath, response.Segments)
+	}
+
+	// Peaks come from the upload itself so they line up with what a player plays
+	if opts.WaveformResolution > 0 {
+		if waveform, err := computeWaveform(ctx, audioPath, opts.WaveformResolution); err != nil {
+			log.Printf("Error computing waveform: %v", err)
+			response.Warnings = append(response.Warnings, "Waveform peaks unavailable: "+err.Error())
+		} else {
+			response.Waveform = waveform
+		}
+	}
+	// Partial and text-only results are worth returning once, not worth keeping
+	if key != "" && !response.Partial && !response.TimestampsUnavailable {
+		s.Cache.Put(key, response)
+	}
+	if !opts.NoCache {
+		s.History.Add(HistoryEntry{CompletedAt: time.Now(), Model: opts.Model, Language: response.Language, Segments: response.Segments})
+	}
+	return response, nil
+}
+
+// runPipeline preprocesses the audio, waits for a free slot and runs the transcription
+func (s *Service) runPipeline(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	reportStage(ctx, "preprocessing")
+	audioPath, shift, err := preprocessAudio(ctx, audioPath, opts)
+	if err != nil {
+		return TranscriptionResponse{}, err
+	}
+	ctx = withShiftedProgress(ctx, shift)
+
+	// Long silences go last, so their map back applies before the shift of the other steps
+	var speech speechMap
+	var vadWarning string
+	if opts.VAD {
+		if audioPath, speech, vadWarning, err = removeSilence(ctx, audioPath); err != nil {
+			return TranscriptionResponse{}, err
+		}
+		ctx = speech.progressContext(ctx)
+	}
+
+	response, err := s.runEngineChunked(ctx, audioPath, opts)
+	if err != nil {
+		return TranscriptionResponse{}, err
+	}
+	if vadWarning != "" {
+		response.Warnings = append(response.Warnings, vadWarning)
+	}
+
+	speech.remap(response.Segments)
+	shiftSegments(response.Segments, shift)
+
+	if opts.TranslateTo != "" {
+		if response.Translation, err = translateResponse(ctx, response, opts.TranslateTo); err != nil {
+			return TranscriptionResponse{}, err
+		}
+	}
+	return response, nil
+}
+
+// runEngine transcribes on the request's engine. Local engines wait for a free slot and feed
+// the circuit breaker; with OVERFLOW_ENGINE set, requests that would have to wait go to that
+// remote engine instead.
+func (s *Service) runEngine(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	cfg := currentConfig()
+	acquired := false
+	if opts.Engine == "" && cfg.OverflowEngine != "" {
+		if acquired = s.Scheduler.TryAcquire(); acquired {
+			defer s.Scheduler.Release()
+		} else {
+			opts.Engine = cfg.OverflowEngine
+			log.Printf("All transcription slots are busy, sending the transcription to %s", opts.Engine)
+		}
+	}
+
+	if cfg.IsRemoteEngine(opts.Engine) {
+		reportStage(ctx, "transcribing")
+		response, err := runTranscription(ctx, audioPath, opts)
+		if err != nil {
+			return TranscriptionResponse{}, err
+		}
+		return retryWithFallbackLanguage(ctx, audioPath, opts, response), nil
+	}
+
+	// A missing model would only fail deep inside the bridge; other engines keep their models elsewhere
+	if !opts.Download && cfg.Engine == transcriber.EnginePythonWhisper {
+		if err := s.Models.Require(ctx, opts.Model); err != nil {
+			return TranscriptionResponse{}, err
+		}
+	}
+
+	// Fail fast while the backend is known to be broken
+	if err := s.Breaker.Allow(); err != nil {
+		return TranscriptionResponse{}, err
+	}
+
+	if !acquired {
+		reportStage(ctx, "waiting")
+		if err := s.Scheduler.Acquire(ctx, opts.Priority); err != nil {
+			// A request that never left the queue says nothing about the backend's health
+			s.Breaker.Release()
+			if errors.Is(err, errQueueFull) {
+				log.Printf("Rejected transcription, %d already queued", cfg.MaxQueuedJobs)
+				return TranscriptionResponse{}, err
+			}
+			log.Printf("Client gave up while queued: %v", err)
+			return TranscriptionResponse{}, err
+		}
+		defer s.Scheduler.Release()
+	}
+	reportStage(ctx, "transcribing")
+
+	response, err := runTranscription(ctx, audioPath, opts)
+	s.Breaker.Record(err)
+	if err != nil {
+		return TranscriptionResponse{}, err
+	}
+	return retryWithFallbackLanguage(ctx, audioPath, opts, response), nil
+}
+
+// saveUpload stores an uploaded file in the given directory and returns its path
+func saveUpload(c *gin.Context, file *multipart.FileHeader, dir string) (string, error) {
+	audioPath := filepath.Join(dir, filepath.Base(file.Filename))
+	if err := c.SaveUploadedFile(file, audioPath); err != nil {
+		log.Printf("Error saving uploaded file: %v", err)
+		return "", err
+	}
+
+	log.Printf("Saved file: %s (size: %.2f MB)", audioPath, float64(file.Size)/(1024*1024))
+	return audioPath, nil
+}
+
+// transcribeUpload saves an uploaded file to a temp directory and transcribes it
+func (s *Service) transcribeUpload(c *gin.Context, file *multipart.FileHeader, opts TranscribeOptions) (TranscriptionResponse, error) {
+	// Create temp directory for uploaded files
+	tmpDir, err := makeTempDir("audio-upload")
+	if err != nil {
+		return TranscriptionResponse{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Save the uploaded file
+	audioPath, err := saveUpload(c, file, tmpDir)
+	if err != nil {
+		if isStorageError(err) {
+			return TranscriptionResponse{}, storageError(err)
+		}
+		return TranscriptionResponse{}, &APIError{Status: http.StatusInternalServerError, Message: "Failed to save uploaded file"}
+	}
+
+	return s.transcribe(c.Request.Context(), audioPath, opts)
+}
+
+// handleTranscribe transcribes a single uploaded audio file
+func (s *Service) handleTranscribe(c *gin.Context) {
+	// Clients behind proxies with short timeouts can poll a job instead of waiting, and a
+	// callback only makes sense for a job
+	if formValue(c, "async") == "true" || formValue(c, "callback_url") != "" {
+		s.handleCreateJob(c)
+		return
+	}
+
+	startTime := time.Now()
+
+	// Get the uploaded file
+	file, err := c.FormFile("audio")
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "No audio file provided"))
+		return
+	}
+
+	// Limit file size
+	if exceedsUploadLimit(file.Filename, file.Size) {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", uploadLimitMB(file.Filename))))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	opts.Filename = file.Filename
+
+	// Identical uploads from the same client can be rejected or share one run
+	cfg := currentConfig()
+	duplicateKey := fmt.Sprintf("%s|%s|%d", c.ClientIP(), file.Filename, file.Size)
+	run := func() (TranscriptionResponse, error) {
+		return s.Inflight.Do(c.Request.Context(), duplicateKey, cfg.DuplicatePolicy, cfg.DuplicateWindow(), func() (TranscriptionResponse, error) {
+			return s.transcribeUpload(c, file, opts)
+		})
+	}
+
+	// Long JSON requests can ask for keep-alive newlines so proxies don't cut them off
+	var response TranscriptionResponse
+	committed := false
+	if opts.KeepAlive && cfg.KeepAliveSeconds > 0 && isJSONFormat(opts.Format) && !wantsProtobuf(c) {
+		response, committed, err = runWithKeepAlive(c, cfg.KeepAliveInterval(), run)
+	} else {
+		response, err = run()
+	}
+	if err != nil {
+		if clientGone(c) {
+			return
+		}
+		if committed {
+			status, body := errorBody(requestError(c, err))
+			writeCommittedJSON(c, status, body)
+			return
+		}
+		writeError(c, err)
+		return
+	}
+
+	// Return the transcription
+	duration := time.Since(startTime)
+	log.Printf("Transcription completed in %v with %d segments", duration, len(response.Segments))
+
+	if committed && opts.Output != nil {
+		location, size, err := s.storeOutput(c.Request.Context(), response, duration, opts)
+		if err != nil {
+			status, body := errorBody(err)
+			writeCommittedJSON(c, status, body)
+			return
+		}
+		writeCommittedJSON(c, http.StatusOK, withDeletedAfter(storedOutputBody(location, size, response, duration), time.Now()))
+		return
+	}
+	if committed {
+		writeCommittedJSON(c, http.StatusOK, buildResult(response, duration, opts))
+		return
+	}
+
+	s.deliver(c, response, duration, opts)
+}
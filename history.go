@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// HistoryEntry is a finished transcription kept for analytics
+type HistoryEntry struct {
+	CompletedAt time.Time
+	Model       string
+	Language    string
+	Segments    []TranscriptionSegment
+}
+
+// HistoryStore keeps the most recent HISTORY_SIZE transcriptions in memory
+type HistoryStore struct {
+	mu      sync.Mutex
+	entries []HistoryEntry // oldest first
+}
+
+// NewHistoryStore creates an empty history
+func NewHistoryStore() *HistoryStore {
+	return &HistoryStore{}
+}
+
+// Add records a transcription, dropping the oldest ones beyond HISTORY_SIZE
+func (h *HistoryStore) Add(entry HistoryEntry) {
+	size := currentConfig().HistorySize
+	if size <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, entry)
+	if excess := len(h.entries) - size; excess > 0 {
+		h.entries = append([]HistoryEntry(nil), h.entries[excess:]...)
+	}
+}
+
+// Recent returns a snapshot of the recorded transcriptions, oldest first
+func (h *HistoryStore) Recent() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HistoryEntry(nil), h.entries...)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Duplicate request policies for identical uploads that are still being processed
+const (
+	DuplicatePolicyOff    = "off"
+	DuplicatePolicyReject = "reject"
+	DuplicatePolicyAttach = "attach"
+)
+
+// errDuplicateRequest is returned when a duplicate upload is rejected
+var errDuplicateRequest = &APIError{
+	Status:  http.StatusConflict,
+	Code:    "duplicate_request",
+	Message: "Duplicate request: an identical request from this client is already in progress",
+}
+
+// inflightCall is a transcription that identical requests can wait on
+type inflightCall struct {
+	started  time.Time
+	done     chan struct{}
+	response TranscriptionResponse
+	err      error
+}
+
+// InflightTracker detects identical requests that arrive while one is still running
+type InflightTracker struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// NewInflightTracker creates an empty tracker
+func NewInflightTracker() *InflightTracker {
+	return &InflightTracker{calls: make(map[string]*inflightCall)}
+}
+
+// Do runs fn unless an identical request started within the window is still running.
+// Depending on the policy a duplicate is rejected or waits for and shares the first result.
+func (t *InflightTracker) Do(ctx context.Context, key, policy string, window time.Duration, fn func() (TranscriptionResponse, error)) (TranscriptionResponse, error) {
+	if policy != DuplicatePolicyReject && policy != DuplicatePolicyAttach {
+		return fn()
+	}
+
+	t.mu.Lock()
+	if call, ok := t.calls[key]; ok && time.Since(call.started) <= window {
+		t.mu.Unlock()
+		if policy == DuplicatePolicyReject {
+			return TranscriptionResponse{}, errDuplicateRequest
+		}
+
+		select {
+		case <-call.done:
+			return call.response, call.err
+		case <-ctx.Done():
+			return TranscriptionResponse{}, ctx.Err()
+		}
+	}
+
+	call := &inflightCall{started: time.Now(), done: make(chan struct{})}
+	t.calls[key] = call
+	t.mu.Unlock()
+
+	call.response, call.err = fn()
+	close(call.done)
+
+	t.mu.Lock()
+	if t.calls[key] == call {
+		delete(t.calls, key)
+	}
+	t.mu.Unlock()
+
+	return call.response, call.err
+}
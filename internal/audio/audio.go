@@ -0,0 +1,375 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SampleRate is the sample rate Whisper works at internally
+const SampleRate = 16000
+
+// ffmpegSlots bounds how many ffmpeg processes run at once; nil means no limit
+var (
+	ffmpegSlotsMu sync.Mutex
+	ffmpegSlots   chan struct{}
+)
+
+// SetMaxProcesses limits concurrent ffmpeg processes, with zero or less removing the limit.
+// Processes already running finish under the limit they started with.
+func SetMaxProcesses(n int) {
+	ffmpegSlotsMu.Lock()
+	defer ffmpegSlotsMu.Unlock()
+	if n <= 0 {
+		ffmpegSlots = nil
+		return
+	}
+	if ffmpegSlots == nil || cap(ffmpegSlots) != n {
+		ffmpegSlots = make(chan struct{}, n)
+	}
+}
+
+// RunningProcesses returns how many ffmpeg processes hold a slot under the current limit
+func RunningProcesses() int {
+	ffmpegSlotsMu.Lock()
+	defer ffmpegSlotsMu.Unlock()
+	return len(ffmpegSlots)
+}
+
+// acquireFFmpeg waits for a free ffmpeg slot and returns the function that gives it back
+func acquireFFmpeg(ctx context.Context) (func(), error) {
+	ffmpegSlotsMu.Lock()
+	slots := ffmpegSlots
+	ffmpegSlotsMu.Unlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for ffmpeg: %w", ctx.Err())
+	}
+}
+
+// runFFmpeg runs ffmpeg quietly, overwriting the output and reporting its stderr on failure
+func runFFmpeg(ctx context.Context, args ...string) error {
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}, args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Duration returns the length of a media file in seconds using ffprobe
+func Duration(ctx context.Context, input string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		input,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ffprobe duration %q: %w", strings.TrimSpace(string(output)), err)
+	}
+	return duration, nil
+}
+
+// Info is what ffprobe reports about a media file's container and audio streams
+type Info struct {
+	Format       string  // container format, such as "mp3" or "mov,mp4,m4a,3gp,3g2,mj2"
+	Duration     float64 // in seconds, 0 when the container doesn't say
+	AudioStreams int
+	Codec        string // codec of the first audio stream
+	SampleRate   int    // of the first audio stream
+	Channels     int    // of the first audio stream
+}
+
+// IsWhisperWAV reports whether the file is already the 16kHz mono 16-bit WAV that Whisper
+// reads, so transcoding it again would change nothing
+func (i Info) IsWhisperWAV() bool {
+	return i.Format == "wav" && i.Codec == "pcm_s16le" && i.SampleRate == SampleRate && i.Channels == 1
+}
+
+// Probe reads a media file's container and audio streams with ffprobe. Files ffprobe can't
+// parse, such as corrupt or truncated uploads, fail with its explanation.
+func Probe(ctx context.Context, input string) (Info, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "format=format_name,duration:stream=codec_name,sample_rate,channels",
+		"-of", "json",
+		input,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// ffprobe names the input in its messages; the caller already knows which file it is
+		message := strings.TrimSpace(strings.ReplaceAll(stderr.String(), input+": ", ""))
+		return Info{}, fmt.Errorf("ffprobe failed: %w: %s", err, message)
+	}
+
+	var probe struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return Info{}, fmt.Errorf("unexpected ffprobe output: %w", err)
+	}
+	info := Info{Format: probe.Format.FormatName, AudioStreams: len(probe.Streams)}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	if len(probe.Streams) > 0 {
+		info.Codec = probe.Streams[0].CodecName
+		info.SampleRate, _ = strconv.Atoi(probe.Streams[0].SampleRate)
+		info.Channels = probe.Streams[0].Channels
+	}
+	return info, nil
+}
+
+// Clip writes a 16kHz mono WAV copy of length seconds of the input starting at start
+func Clip(ctx context.Context, input, output string, start, length float64) error {
+	return runFFmpeg(ctx,
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-i", input,
+		"-t", strconv.FormatFloat(length, 'f', 3, 64),
+		"-ar", fmt.Sprint(SampleRate),
+		"-ac", "1",
+		output,
+	)
+}
+
+// PadStart writes a 16kHz mono WAV copy of the input with leading silence prepended
+func PadStart(ctx context.Context, input, output string, seconds float64) error {
+	delay := int(seconds * 1000)
+	return runFFmpeg(ctx,
+		"-i", input,
+		"-af", fmt.Sprintf("adelay=delays=%d:all=1", delay),
+		"-ar", fmt.Sprint(SampleRate),
+		"-ac", "1",
+		output,
+	)
+}
+
+// TrimSilence writes a 16kHz mono WAV copy of the input with leading and trailing silence
+// below thresholdDB removed by ffmpeg's silenceremove, keeping up to keep seconds of it at
+// either end. It returns how many seconds were cut from the start, so timestamps can be
+// moved back onto the original timeline.
+func TrimSilence(ctx context.Context, input, output string, thresholdDB, keep float64) (float64, error) {
+	trim := fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%gdB:start_silence=%g", thresholdDB, keep)
+
+	// The start is trimmed on its own first so the cut can be measured
+	leadPath := output + ".lead.wav"
+	defer os.Remove(leadPath)
+	if err := runFFmpeg(ctx,
+		"-i", input,
+		"-af", trim,
+		"-ar", fmt.Sprint(SampleRate),
+		"-ac", "1",
+		leadPath,
+	); err != nil {
+		return 0, err
+	}
+
+	before, err := Duration(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	after, err := Duration(ctx, leadPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// silenceremove only works on the start, so the end is trimmed on the reversed audio
+	if err := runFFmpeg(ctx,
+		"-i", leadPath,
+		"-af", "areverse,"+trim+",areverse",
+		output,
+	); err != nil {
+		return 0, err
+	}
+	return max(0, before-after), nil
+}
+
+// KeepSpans writes a 16kHz mono WAV of only the given spans of the input, in seconds,
+// joined end to end. Spans must be in order and should start and end on 10ms boundaries,
+// the size of the frames that are kept or dropped, so the output lines up with them exactly.
+func KeepSpans(ctx context.Context, input, output string, spans [][2]float64) error {
+	frame := SampleRate / 100
+	terms := make([]string, len(spans))
+	for i, span := range spans {
+		// A frame is kept when it starts inside the span; half a frame of slack absorbs rounding
+		terms[i] = fmt.Sprintf("between(t,%.3f,%.3f)", span[0]-0.005, span[1]-0.005)
+	}
+	return runFFmpeg(ctx,
+		"-i", input,
+		"-af", fmt.Sprintf("aresample=%d,asetnsamples=n=%d:p=0,aselect='%s',asetpts=N/SR/TB", SampleRate, frame, strings.Join(terms, "+")),
+		"-ac", "1",
+		output,
+	)
+}
+
+// peakSampleRate is the rate audio is decoded at for waveform peaks, plenty for a display
+const peakSampleRate = 8000
+
+// Peaks returns the peak amplitude, between 0 and 1, of each 1/perSecond slice of the
+// input's audio, mixed down to mono. The audio is streamed from ffmpeg so long recordings
+// are not held in memory.
+func Peaks(ctx context.Context, input string, perSecond int) ([]float64, error) {
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", input,
+		"-vn",
+		"-ac", "1",
+		"-ar", fmt.Sprint(peakSampleRate),
+		"-f", "s16le",
+		"-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	var peaks []float64
+	reader := bufio.NewReader(stdout)
+	sample := make([]byte, 2)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(reader, sample); err != nil {
+			break
+		}
+		bucket := n * perSecond / peakSampleRate
+		if bucket == len(peaks) {
+			peaks = append(peaks, 0)
+		}
+		amplitude := math.Abs(float64(int16(binary.LittleEndian.Uint16(sample)))) / 32768
+		peaks[bucket] = max(peaks[bucket], amplitude)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return peaks, nil
+}
+
+// Samples decodes the input's audio to 16kHz mono float samples, the form whisper.cpp
+// transcribes from memory
+func Samples(ctx context.Context, input string) ([]float32, error) {
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", input,
+		"-vn",
+		"-ac", "1",
+		"-ar", fmt.Sprint(SampleRate),
+		"-f", "f32le",
+		"-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	samples := make([]float32, len(output)/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(output[i*4:]))
+	}
+	return samples, nil
+}
+
+// Channels returns the number of channels in the first audio stream using ffprobe
+func Channels(ctx context.Context, input string) (int, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=channels",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		input,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	channels, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ffprobe channel count %q: %w", strings.TrimSpace(string(output)), err)
+	}
+	return channels, nil
+}
+
+// ExtractChannel writes a 16kHz mono WAV of a single channel of the input, counting from 0
+func ExtractChannel(ctx context.Context, input, output string, channel int) error {
+	return runFFmpeg(ctx,
+		"-i", input,
+		"-af", fmt.Sprintf("pan=mono|c0=c%d", channel),
+		"-ar", fmt.Sprint(SampleRate),
+		output,
+	)
+}
+
+// ExtractAudio writes a 16kHz mono 16-bit WAV of an audio stream of any media file ffmpeg
+// reads, dropping video. A negative stream index lets ffmpeg pick the default audio stream.
+func ExtractAudio(ctx context.Context, input, output string, stream int) error {
+	args := []string{"-i", input}
+	if stream >= 0 {
+		args = append(args, "-map", fmt.Sprintf("0:a:%d", stream))
+	}
+	args = append(args,
+		"-vn",
+		"-ar", fmt.Sprint(SampleRate),
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+		output,
+	)
+	return runFFmpeg(ctx, args...)
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Validator decides whether an API key may use the service. An error means the
+// answer is unknown, not that the key is invalid.
+type Validator interface {
+	Validate(ctx context.Context, key string) (bool, error)
+}
+
+// Backend names accepted by New
+const (
+	BackendNone          = ""
+	BackendStatic        = "static"
+	BackendIntrospection = "introspection"
+)
+
+// New creates the validator for the named backend, or returns nil when API keys are not required
+func New(backend string, keys []string, introspectionURL, clientSecret string, cacheTTL time.Duration) (Validator, error) {
+	switch backend {
+	case BackendNone:
+		return nil, nil
+	case BackendStatic:
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("the static backend needs at least one key")
+		}
+		return NewStatic(keys), nil
+	case BackendIntrospection:
+		if introspectionURL == "" {
+			return nil, fmt.Errorf("the introspection backend needs a URL")
+		}
+		return NewCached(&Introspection{URL: introspectionURL, ClientSecret: clientSecret, Client: http.DefaultClient}, cacheTTL), nil
+	}
+	return nil, fmt.Errorf("unknown auth backend %q (expected static or introspection)", backend)
+}
+
+// Static accepts a fixed list of keys
+type Static struct {
+	keys [][]byte
+}
+
+// NewStatic creates a validator for the given keys, ignoring empty ones
+func NewStatic(keys []string) *Static {
+	s := &Static{}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			s.keys = append(s.keys, []byte(key))
+		}
+	}
+	return s
+}
+
+// Validate compares the key against every configured key in constant time
+func (s *Static) Validate(_ context.Context, key string) (bool, error) {
+	valid := false
+	for _, known := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key), known) == 1 {
+			valid = true
+		}
+	}
+	return valid, nil
+}
+
+// Introspection asks an OAuth 2.0 token introspection endpoint (RFC 7662) whether a key is
+// active. The key is posted as the form field token and the response must carry "active".
+type Introspection struct {
+	URL          string
+	ClientSecret string // sent as a bearer token to the endpoint when set
+	Client       *http.Client
+}
+
+// Validate posts the key to the introspection endpoint
+func (v *Introspection) Validate(ctx context.Context, key string) (bool, error) {
+	form := url.Values{"token": {key}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if v.ClientSecret != "" {
+		req.Header.Set("Authorization", "Bearer "+v.ClientSecret)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, fmt.Errorf("failed to read introspection response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("introspection endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, fmt.Errorf("failed to parse introspection response: %w", err)
+	}
+	if result.Active == nil {
+		return false, fmt.Errorf("introspection response has no active field")
+	}
+	return *result.Active, nil
+}
+
+// maxCachedResults bounds the validation cache
+const maxCachedResults = 10000
+
+// cachedResult is a remembered validation outcome
+type cachedResult struct {
+	valid   bool
+	expires time.Time
+}
+
+// Cached remembers the answers of another validator for a short while, so every request
+// doesn't cost a round trip. Keys are stored hashed; errors are never cached.
+type Cached struct {
+	next Validator
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]cachedResult
+}
+
+// NewCached wraps a validator with a result cache; a TTL of zero or less disables caching
+func NewCached(next Validator, ttl time.Duration) *Cached {
+	return &Cached{next: next, ttl: ttl, results: make(map[[sha256.Size]byte]cachedResult)}
+}
+
+// Validate answers from the cache when possible and asks the wrapped validator otherwise
+func (c *Cached) Validate(ctx context.Context, key string) (bool, error) {
+	if c.ttl <= 0 {
+		return c.next.Validate(ctx, key)
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	now := time.Now()
+	c.mu.Lock()
+	result, ok := c.results[hash]
+	c.mu.Unlock()
+	if ok && now.Before(result.expires) {
+		return result.valid, nil
+	}
+
+	valid, err := c.next.Validate(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop expired entries now and then so random keys can't grow the map forever
+	if len(c.results) >= maxCachedResults {
+		for k, r := range c.results {
+			if !now.Before(r.expires) {
+				delete(c.results, k)
+			}
+		}
+		if len(c.results) >= maxCachedResults {
+			clear(c.results)
+		}
+	}
+	c.results[hash] = cachedResult{valid: valid, expires: now.Add(c.ttl)}
+	return valid, nil
+}
@@ -0,0 +1,480 @@
+// Package bridge keeps whisper_bridge.py running as long-lived workers, so torch is imported
+// and models are loaded once per worker instead of once per transcription
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// startTimeout bounds how long a worker may take to import torch and report ready
+	startTimeout = 5 * time.Minute
+
+	// pingTimeout bounds how long an idle worker may take to answer a health check
+	pingTimeout = 10 * time.Second
+
+	// stderrLimit is how much of a worker's stderr is kept to explain a crash
+	stderrLimit = 16 << 10
+)
+
+// Prefixes of the lines a worker writes on stdout
+const (
+	readyPrefix    = "READY "
+	donePrefix     = "DONE "
+	progressPrefix = "PROGRESS "
+)
+
+// ErrClosed is returned for requests made after the pool was closed
+var ErrClosed = errors.New("bridge workers are shut down")
+
+// ExitError is returned when a worker exits in the middle of a request
+type ExitError struct {
+	Stderr string // the end of what the worker wrote on stderr
+}
+
+func (e *ExitError) Error() string {
+	return "bridge worker exited unexpectedly"
+}
+
+// Reply is a worker's answer to a request: what a one-off bridge run would have exited with
+// and printed
+type Reply struct {
+	ExitCode int    `json:"exit_code"`
+	Log      string `json:"log"`
+}
+
+// Pool hands requests to a limited number of workers, starting them when needed and
+// restarting any that die or stop answering. Requests beyond the limit wait for a worker.
+type Pool struct {
+	script string
+
+	mu      sync.Mutex
+	size    int       // workers wanted
+	total   int       // workers that exist, idle or busy
+	idle    []*worker // workers free to take a request
+	wake    chan struct{}
+	closed  bool
+	created int // workers ever created, for their log index
+}
+
+// NewPool creates a pool of up to size workers running script. Workers start on first use or
+// on the first health check, whichever comes sooner.
+func NewPool(script string, size int) *Pool {
+	return &Pool{script: script, size: max(size, 1), wake: make(chan struct{})}
+}
+
+// Resize changes how many workers the pool keeps. Extra idle workers stop right away and
+// extra busy ones once they finish their request; new ones start on demand.
+func (p *Pool) Resize(size int) {
+	size = max(size, 1)
+	p.mu.Lock()
+	if size == p.size {
+		p.mu.Unlock()
+		return
+	}
+	log.Printf("Resizing bridge workers from %d to %d", p.size, size)
+	p.size = size
+	var retired []*worker
+	for p.total > p.size && len(p.idle) > 0 {
+		retired = append(retired, p.idle[len(p.idle)-1])
+		p.idle = p.idle[:len(p.idle)-1]
+		p.total--
+	}
+	p.broadcast()
+	p.mu.Unlock()
+
+	for _, w := range retired {
+		w.stop()
+	}
+}
+
+// Stats reports the wanted number of workers and how many are busy and idle
+func (p *Pool) Stats() (size, busy, idle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size, p.total - len(p.idle), len(p.idle)
+}
+
+// acquire takes an idle worker, creates one while the pool is below its size, or waits
+func (p *Pool) acquire(ctx context.Context) (*worker, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if n := len(p.idle); n > 0 {
+			w := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			return w, nil
+		}
+		if p.total < p.size {
+			p.total++
+			p.created++
+			w := &worker{script: p.script, index: p.created}
+			p.mu.Unlock()
+			return w, nil
+		}
+		wake := p.wake
+		p.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release gives a worker back, or stops it when the pool shrank or closed meanwhile
+func (p *Pool) release(w *worker) {
+	p.mu.Lock()
+	retire := p.closed || p.total > p.size
+	if retire {
+		p.total--
+	} else {
+		p.idle = append(p.idle, w)
+	}
+	p.broadcast()
+	p.mu.Unlock()
+
+	if retire {
+		w.stop()
+	}
+}
+
+// broadcast wakes everyone waiting in acquire; callers hold mu
+func (p *Pool) broadcast() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// Run sends a bridge command line to an idle worker and waits for its reply. progress
+// receives the payload of each progress line the worker writes meanwhile. A worker that
+// hasn't answered when ctx ends is killed, since whisper can't be interrupted mid-file, and
+// is started again for the next request.
+func (p *Pool) Run(ctx context.Context, args []string, progress func([]byte)) (Reply, error) {
+	w, err := p.acquire(ctx)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer p.release(w)
+
+	if !w.running() {
+		w.stop()
+		if err := w.start(ctx); err != nil {
+			return Reply{}, err
+		}
+	}
+	reply, err := w.call(ctx, request{Args: args}, progress)
+	if err != nil {
+		w.stop()
+	}
+	return reply, err
+}
+
+// Output runs args like Run and reports like exec's CombinedOutput: what the run printed,
+// with an error when it failed or exited with a non-zero status
+func (p *Pool) Output(ctx context.Context, args []string, progress func([]byte)) ([]byte, error) {
+	reply, err := p.Run(ctx, args, progress)
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return []byte(exitErr.Stderr), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reply.ExitCode != 0 {
+		return []byte(reply.Log), fmt.Errorf("bridge exited with status %d", reply.ExitCode)
+	}
+	return []byte(reply.Log), nil
+}
+
+// Maintain starts the workers and then health-checks the idle ones every interval until ctx
+// ends or the pool closes, restarting any that crashed or don't answer a ping
+func (p *Pool) Maintain(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// check visits each idle worker and tops the pool up to its size; busy ones are evidently
+// alive. Workers leave the pool one at a time, so the rest keep serving while one is pinged
+// or restarted.
+func (p *Pool) check(ctx context.Context) {
+	p.mu.Lock()
+	visit := slices.Clone(p.idle)
+	p.mu.Unlock()
+
+	for _, w := range visit {
+		// A worker a request took meanwhile needs no check
+		if !p.take(w) {
+			continue
+		}
+		p.checkWorker(ctx, w)
+		p.release(w)
+	}
+
+	for {
+		p.mu.Lock()
+		if p.total >= p.size || p.closed {
+			p.mu.Unlock()
+			return
+		}
+		p.total++
+		p.created++
+		w := &worker{script: p.script, index: p.created}
+		p.mu.Unlock()
+
+		p.checkWorker(ctx, w)
+		p.release(w)
+	}
+}
+
+// take removes w from the idle workers, reporting false when it isn't idle
+func (p *Pool) take(w *worker) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.Index(p.idle, w)
+	if i < 0 {
+		return false
+	}
+	p.idle = slices.Delete(p.idle, i, i+1)
+	return true
+}
+
+// checkWorker pings a worker and (re)starts it when it isn't healthy
+func (p *Pool) checkWorker(ctx context.Context, w *worker) {
+	if w.running() {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		_, err := w.call(pingCtx, request{Ping: true}, nil)
+		cancel()
+		if err == nil {
+			return
+		}
+		log.Printf("Bridge worker %d failed its health check, restarting it: %v", w.index, err)
+	} else if w.started {
+		log.Printf("Bridge worker %d exited, restarting it: %s", w.index, strings.TrimSpace(w.stderr.String()))
+	}
+	w.stop()
+	if err := w.start(ctx); err != nil {
+		log.Printf("Failed to start bridge worker %d: %v", w.index, err)
+	}
+}
+
+// Close stops the idle workers now and busy ones once they finish their request
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	workers := p.idle
+	p.idle = nil
+	p.total -= len(workers)
+	p.broadcast()
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.stop()
+	}
+}
+
+// request is a line sent to a worker's stdin
+type request struct {
+	ID   int      `json:"id"`
+	Args []string `json:"args,omitempty"`
+	Ping bool     `json:"ping,omitempty"`
+}
+
+// worker is one whisper_bridge.py --serve process. Only the goroutine that took it from the
+// pool touches it.
+type worker struct {
+	script  string
+	index   int
+	started bool // whether the worker ever started, to tell a crash from the first start
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string   // stdout lines
+	done   chan struct{} // closed when stdout ends, usually because the process exited
+	quit   chan struct{} // closed by stop so the stdout reader never blocks on lines
+	stderr *tailBuffer
+	nextID int
+}
+
+// running reports whether the worker has a process whose stdout is still open
+func (w *worker) running() bool {
+	if w.cmd == nil {
+		return false
+	}
+	select {
+	case <-w.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// start launches the process and waits until it has imported whisper
+func (w *worker) start(ctx context.Context) error {
+	cmd := exec.Command("python3", w.script, "--serve")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	w.stderr = &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = w.stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start bridge worker: %w", err)
+	}
+	w.cmd, w.stdin, w.started = cmd, stdin, true
+	w.lines, w.done, w.quit = make(chan string), make(chan struct{}), make(chan struct{})
+	go read(stdout, w.lines, w.done, w.quit)
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	for {
+		line, err := w.next(ctx)
+		if err != nil {
+			w.stop()
+			return fmt.Errorf("bridge worker did not become ready: %w", err)
+		}
+		if strings.HasPrefix(line, readyPrefix) {
+			log.Printf("Bridge worker %d ready (pid %d)", w.index, cmd.Process.Pid)
+			return nil
+		}
+	}
+}
+
+// read forwards stdout lines until the process closes it or the worker is stopped
+func read(stdout io.Reader, lines chan<- string, done chan<- struct{}, quit <-chan struct{}) {
+	defer close(done)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-quit:
+			return
+		}
+	}
+}
+
+// next returns the worker's next stdout line
+func (w *worker) next(ctx context.Context) (string, error) {
+	select {
+	case line := <-w.lines:
+		return line, nil
+	case <-w.done:
+		return "", &ExitError{Stderr: w.stderr.String()}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// call sends a request and waits for the reply with its id
+func (w *worker) call(ctx context.Context, req request, progress func([]byte)) (Reply, error) {
+	w.nextID++
+	req.ID = w.nextID
+	line, err := json.Marshal(req)
+	if err != nil {
+		return Reply{}, err
+	}
+	if _, err := w.stdin.Write(append(line, '\n')); err != nil {
+		return Reply{}, fmt.Errorf("failed to send request to bridge worker: %w", err)
+	}
+
+	for {
+		line, err := w.next(ctx)
+		if err != nil {
+			return Reply{}, err
+		}
+		if payload, ok := strings.CutPrefix(line, progressPrefix); ok {
+			if progress != nil {
+				progress([]byte(payload))
+			}
+			continue
+		}
+		payload, ok := strings.CutPrefix(line, donePrefix)
+		if !ok {
+			continue
+		}
+		var reply struct {
+			ID int `json:"id"`
+			Reply
+		}
+		if err := json.Unmarshal([]byte(payload), &reply); err != nil {
+			return Reply{}, fmt.Errorf("malformed reply from bridge worker: %w", err)
+		}
+		// A reply to an earlier request that timed out is skipped
+		if reply.ID == req.ID {
+			return reply.Reply, nil
+		}
+	}
+}
+
+// stop kills the process; closing stdin alone would let it finish the file it is on
+func (w *worker) stop() {
+	if w.cmd == nil {
+		return
+	}
+	select {
+	case <-w.quit:
+	default:
+		close(w.quit)
+	}
+	w.stdin.Close()
+	_ = w.cmd.Process.Kill()
+	_ = w.cmd.Wait()
+	w.cmd = nil
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,232 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrBlockedAddress is returned when a URL points at an address downloads may not reach
+	ErrBlockedAddress = errors.New("address not allowed")
+	// ErrTooLarge is returned when the remote file is bigger than the download limit
+	ErrTooLarge = errors.New("file too large")
+	// ErrUnsupportedURL is returned for URLs that are not plain http or https
+	ErrUnsupportedURL = errors.New("only http and https URLs are supported")
+)
+
+// StatusError is a download that the remote server answered with an error status
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice though not in net
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// blockedIP reports whether an address is loopback, private, link-local or otherwise not
+// a public unicast address
+func blockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// Downloader fetches remote media files with retries, a size limit and SSRF protection
+type Downloader struct {
+	Timeout      time.Duration // per attempt
+	Retries      int           // extra attempts after network errors, 429 and 5xx responses
+	MaxRedirects int
+	MaxBytes     int64
+	AllowPrivate bool // allow private, loopback and link-local addresses
+}
+
+// client builds the HTTP client for one download. Addresses are checked when connecting,
+// after DNS resolution, so a hostname can't be re-pointed at an internal address between
+// the check and the request. Proxies from the environment are ignored for the same reason.
+func (d *Downloader) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if d.AllowPrivate {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if blockedIP(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > d.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", d.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+}
+
+// Download saves the file at rawURL into dir and returns its path. The file is named after
+// the last path element of the final URL, with an extension from the Content-Type when the
+// name has none, so the media type can still be told from the name.
+func (d *Downloader) Download(ctx context.Context, rawURL, dir string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", ErrUnsupportedURL
+	}
+
+	var destination string
+	client := d.client()
+	for attempt := 0; ; attempt++ {
+		destination, err = d.fetch(ctx, client, parsed.String(), dir)
+		if err == nil || attempt >= d.Retries || !retryable(err) {
+			break
+		}
+
+		backoff := time.Duration(1<<attempt) * 500 * time.Millisecond
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if err != nil {
+		if destination != "" {
+			os.Remove(destination)
+		}
+		return "", err
+	}
+	return destination, nil
+}
+
+// fetch makes one download attempt, returning the path it wrote to even when it failed
+func (d *Downloader) fetch(ctx context.Context, client *http.Client, rawURL, dir string) (string, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Status: resp.StatusCode}
+	}
+	if d.MaxBytes > 0 && resp.ContentLength > d.MaxBytes {
+		return "", ErrTooLarge
+	}
+
+	destination := filepath.Join(dir, fileName(resp.Request.URL, resp.Header.Get("Content-Type")))
+	f, err := os.Create(destination)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	body := io.Reader(resp.Body)
+	if d.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, d.MaxBytes+1)
+	}
+	written, err := io.Copy(f, body)
+	if err != nil {
+		return destination, err
+	}
+	if d.MaxBytes > 0 && written > d.MaxBytes {
+		return destination, ErrTooLarge
+	}
+	return destination, f.Close()
+}
+
+// retryable reports whether a failed attempt is worth repeating
+func retryable(err error) bool {
+	if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrTooLarge) || errors.Is(err, ErrUnsupportedURL) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status == http.StatusTooManyRequests || statusErr.Status >= http.StatusInternalServerError
+	}
+	var pathErr *os.PathError
+	return !errors.As(err, &pathErr) // local disk errors won't go away on a retry
+}
+
+// fileName picks a safe local name for a downloaded URL
+func fileName(u *url.URL, contentType string) string {
+	name := path.Base(u.Path)
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" || name == ".." {
+		name = "download"
+	}
+
+	if path.Ext(name) == "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			if ext, ok := mediaExtensions[mediaType]; ok {
+				name += ext
+			}
+		}
+	}
+	return name
+}
+
+// mediaExtensions maps the audio and video media types servers send to file extensions
+var mediaExtensions = map[string]string{
+	"audio/mpeg":       ".mp3",
+	"audio/mp3":        ".mp3",
+	"audio/wav":        ".wav",
+	"audio/x-wav":      ".wav",
+	"audio/wave":       ".wav",
+	"audio/mp4":        ".m4a",
+	"audio/x-m4a":      ".m4a",
+	"audio/aac":        ".aac",
+	"audio/flac":       ".flac",
+	"audio/x-flac":     ".flac",
+	"audio/ogg":        ".ogg",
+	"audio/opus":       ".opus",
+	"audio/webm":       ".webm",
+	"video/mp4":        ".mp4",
+	"video/webm":       ".webm",
+	"video/quicktime":  ".mov",
+	"video/x-matroska": ".mkv",
+}
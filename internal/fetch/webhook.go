@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Notifier POSTs JSON payloads to callback URLs, retrying with backoff and refusing the same
+// addresses as downloads. Redirects are not followed.
+type Notifier struct {
+	Timeout      time.Duration // per attempt
+	Retries      int           // extra attempts after network errors, 408, 429 and 5xx responses
+	Backoff      time.Duration // before the first retry, doubling after each one
+	Secret       string        // signs payloads in X-Signature-SHA256 when set
+	AllowPrivate bool
+}
+
+// ValidCallbackURL reports whether a callback URL is an absolute http or https URL
+func ValidCallbackURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// Notify delivers payload to rawURL, calling onAttempt after every attempt, and returns the
+// number of attempts made with the last error
+func (n *Notifier) Notify(ctx context.Context, rawURL string, payload []byte, onAttempt func(attempt int, err error)) (int, error) {
+	if !ValidCallbackURL(rawURL) {
+		return 0, ErrUnsupportedURL
+	}
+
+	client := (&Downloader{AllowPrivate: n.AllowPrivate}).client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	backoff := n.Backoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(ctx, client, rawURL, payload)
+		if onAttempt != nil {
+			onAttempt(attempt, err)
+		}
+		if err == nil || attempt > n.Retries || !retryableStatus(err) {
+			return attempt, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt; any 2xx response counts as delivered
+func (n *Notifier) post(ctx context.Context, client *http.Client, rawURL string, payload []byte) error {
+	if n.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Signature-SHA256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Status: resp.StatusCode}
+	}
+	return nil
+}
+
+// retryableStatus is retryable, plus request timeouts, which receivers send when busy
+func retryableStatus(err error) bool {
+	if statusErr, ok := err.(*StatusError); ok && statusErr.Status == http.StatusRequestTimeout {
+		return true
+	}
+	return retryable(err)
+}
@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTooLong is returned when a video is longer than the allowed duration
+	ErrTooLong = errors.New("media is longer than allowed")
+	// ErrLiveStream is returned for live streams, which have no end to wait for
+	ErrLiveStream = errors.New("live streams are not supported")
+	// ErrYTDLPMissing is returned when the yt-dlp binary can't be found
+	ErrYTDLPMissing = errors.New("yt-dlp is not installed")
+)
+
+// SourceError is a video site refusing or failing to hand out a video: it is private,
+// removed, region-locked, or the site couldn't be read
+type SourceError struct {
+	Message string
+}
+
+func (e *SourceError) Error() string {
+	return e.Message
+}
+
+// videoSites lists the hosts, subdomains included, whose links go through yt-dlp
+var videoSites = []string{"youtube.com", "youtu.be", "youtube-nocookie.com", "vimeo.com"}
+
+// IsVideoSite reports whether a URL points at a video site that needs yt-dlp
+func IsVideoSite(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, site := range videoSites {
+		if host == site || strings.HasSuffix(host, "."+site) {
+			return true
+		}
+	}
+	return false
+}
+
+// YTDLP downloads the audio track of videos on sites like YouTube and Vimeo with yt-dlp
+type YTDLP struct {
+	Binary      string
+	Timeout     time.Duration // for the metadata lookup and the download together
+	MaxDuration time.Duration // zero allows any length
+	MaxBytes    int64
+}
+
+// videoInfo is the part of yt-dlp's metadata that is checked before downloading
+type videoInfo struct {
+	Duration float64 `json:"duration"`
+	IsLive   bool    `json:"is_live"`
+}
+
+// Download checks the video's length and saves its best audio-only format into dir
+// (falling back to the whole video when there is none), returning the file's path
+func (y *YTDLP) Download(ctx context.Context, rawURL, dir string) (string, error) {
+	if !IsVideoSite(rawURL) {
+		return "", ErrUnsupportedURL
+	}
+	if y.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, y.Timeout)
+		defer cancel()
+	}
+
+	// Only site extractors run, so yt-dlp can't be pointed at arbitrary pages
+	common := []string{"--no-playlist", "--no-warnings", "--use-extractors", "default,-generic"}
+
+	output, err := y.run(ctx, append(common, "--dump-single-json", "--skip-download", "--", rawURL)...)
+	if err != nil {
+		return "", err
+	}
+	var info videoInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return "", &SourceError{Message: "could not read video metadata"}
+	}
+	if info.IsLive {
+		return "", ErrLiveStream
+	}
+	if y.MaxDuration > 0 && info.Duration > y.MaxDuration.Seconds() {
+		return "", fmt.Errorf("%w: %s over the %s limit", ErrTooLong, time.Duration(info.Duration*float64(time.Second)).Round(time.Second), y.MaxDuration)
+	}
+
+	args := append(common, "--format", "bestaudio/best", "--output", filepath.Join(dir, "media.%(ext)s"))
+	if y.MaxBytes > 0 {
+		args = append(args, "--max-filesize", strconv.FormatInt(y.MaxBytes, 10))
+	}
+	if _, err := y.run(ctx, append(args, "--", rawURL)...); err != nil {
+		return "", err
+	}
+
+	// yt-dlp skips files over --max-filesize without failing, leaving nothing behind
+	matches, _ := filepath.Glob(filepath.Join(dir, "media.*"))
+	for _, match := range matches {
+		if !strings.HasSuffix(match, ".part") {
+			return match, nil
+		}
+	}
+	if y.MaxBytes > 0 {
+		return "", ErrTooLarge
+	}
+	return "", &SourceError{Message: "yt-dlp finished without producing a file"}
+}
+
+// run executes yt-dlp and returns its stdout, turning failures into errors by kind
+func (y *YTDLP) run(ctx context.Context, args ...string) ([]byte, error) {
+	binary := y.Binary
+	if binary == "" {
+		binary = "yt-dlp"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	switch {
+	case err == nil:
+		return stdout.Bytes(), nil
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, ErrYTDLPMissing
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	}
+	return nil, &SourceError{Message: ytdlpMessage(stderr.String())}
+}
+
+// ytdlpMessage picks the reason out of yt-dlp's error output
+func ytdlpMessage(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if message, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "ERROR: "); ok {
+			return message
+		}
+	}
+	if len(lines) > 0 && lines[len(lines)-1] != "" {
+		return lines[len(lines)-1]
+	}
+	return "yt-dlp failed"
+}
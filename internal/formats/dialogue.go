@@ -0,0 +1,50 @@
+package formats
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diarizationLabel matches the SPEAKER_00 style labels pyannote assigns
+var diarizationLabel = regexp.MustCompile(`^SPEAKER_(\d+)$`)
+
+// SpeakerName turns a diarization label into a readable name, numbering speakers from 1
+func SpeakerName(label string) string {
+	if label == "" {
+		return "Unknown"
+	}
+	if match := diarizationLabel.FindStringSubmatch(label); match != nil {
+		if n, err := strconv.Atoi(match[1]); err == nil {
+			return fmt.Sprintf("Speaker %d", n+1)
+		}
+	}
+	return label
+}
+
+// Dialogue renders cues as a meeting transcript with one line per speaker turn, like
+// "[00:01:05] Speaker 1: ...". Consecutive cues from the same speaker are joined into one
+// turn stamped with its first cue's start.
+func Dialogue(cues []Cue, timestamps bool) string {
+	var b strings.Builder
+	for i := 0; i < len(cues); {
+		turn := cues[i]
+		parts := []string{strings.TrimSpace(turn.Text)}
+		j := i + 1
+		for ; j < len(cues) && cues[j].Speaker == turn.Speaker; j++ {
+			parts = append(parts, strings.TrimSpace(cues[j].Text))
+		}
+		i = j
+
+		text := strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+		if text == "" {
+			continue
+		}
+		if timestamps {
+			fmt.Fprintf(&b, "[%s] ", FormatClock(turn.Start))
+		}
+		fmt.Fprintf(&b, "%s: %s\n", SpeakerName(turn.Speaker), text)
+	}
+	return b.String()
+}
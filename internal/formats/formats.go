@@ -0,0 +1,133 @@
+package formats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Cue is a timed piece of text in a subtitle file
+type Cue struct {
+	Text  string
+	Start float64 // in seconds
+	End   float64 // in seconds
+
+	Speaker string // diarization label, empty when unknown
+
+	Confidence *float64 // between 0 and 1, nil when unknown
+}
+
+// WrapOptions controls how cue text is broken into lines.
+// A zero value for either field disables that limit.
+type WrapOptions struct {
+	MaxLineChars int
+	MaxLines     int
+}
+
+// clockParts splits seconds into hours, minutes, seconds and milliseconds
+func clockParts(seconds float64) (hours, minutes, secs, millis int64) {
+	if seconds < 0 {
+		seconds = 0
+	}
+	totalMillis := int64(math.Round(seconds * 1000))
+	return totalMillis / 3600000, (totalMillis % 3600000) / 60000, (totalMillis % 60000) / 1000, totalMillis % 1000
+}
+
+// FormatTimestamp converts seconds to HH:MM:SS with milliseconds after the given separator
+func FormatTimestamp(seconds float64, separator string) string {
+	hours, minutes, secs, millis := clockParts(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, secs, separator, millis)
+}
+
+// FormatClock converts seconds to a plain HH:MM:SS clock string
+func FormatClock(seconds float64) string {
+	hours, minutes, secs, _ := clockParts(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, secs)
+}
+
+// WrapLines breaks text on word boundaries into lines of at most maxChars characters.
+// Words longer than the limit are kept whole on their own line.
+func WrapLines(text string, maxChars int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if maxChars <= 0 {
+		return []string{strings.Join(words, " ")}
+	}
+
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word)) > maxChars {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+// SplitCue wraps a cue's text and splits it into several cues when it needs more
+// than MaxLines lines. Timing is shared out in proportion to each part's length, and every
+// part keeps the cue's speaker and confidence.
+func SplitCue(cue Cue, opts WrapOptions) []Cue {
+	lines := WrapLines(cue.Text, opts.MaxLineChars)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	perCue := opts.MaxLines
+	if perCue <= 0 {
+		perCue = len(lines)
+	}
+
+	totalChars := 0
+	for _, line := range lines {
+		totalChars += len([]rune(line))
+	}
+
+	var cues []Cue
+	start := cue.Start
+	duration := cue.End - cue.Start
+	consumed := 0
+	for i := 0; i < len(lines); i += perCue {
+		end := min(i+perCue, len(lines))
+		group := lines[i:end]
+		for _, line := range group {
+			consumed += len([]rune(line))
+		}
+
+		cueEnd := cue.End
+		if end < len(lines) && totalChars > 0 {
+			cueEnd = cue.Start + duration*float64(consumed)/float64(totalChars)
+		}
+		cues = append(cues, Cue{Text: strings.Join(group, "\n"), Start: start, End: cueEnd, Speaker: cue.Speaker, Confidence: cue.Confidence})
+		start = cueEnd
+	}
+	return cues
+}
+
+// wrapCues applies the wrap options to every cue
+func wrapCues(cues []Cue, opts WrapOptions) []Cue {
+	var wrapped []Cue
+	for _, cue := range cues {
+		wrapped = append(wrapped, SplitCue(cue, opts)...)
+	}
+	return wrapped
+}
+
+// SRT renders cues as a SubRip subtitle file
+func SRT(cues []Cue, opts WrapOptions) string {
+	var b strings.Builder
+	for i, cue := range wrapCues(cues, opts) {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n",
+			i+1,
+			FormatTimestamp(cue.Start, ","),
+			FormatTimestamp(cue.End, ","),
+			cue.Text,
+		)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package formats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSRT(t *testing.T) {
+	tests := []struct {
+		name string
+		cues []Cue
+		opts WrapOptions
+		want string
+	}{
+		{
+			name: "timing",
+			cues: []Cue{{Text: "Hello", Start: 0, End: 1.5}, {Text: "again", Start: 3661.2345, End: 3662}},
+			want: "1\n00:00:00,000 --> 00:00:01,500\nHello\n\n" +
+				"2\n01:01:01,235 --> 01:01:02,000\nagain\n\n",
+		},
+		{
+			name: "speakers left out",
+			cues: []Cue{{Text: "Hi", Start: 0, End: 1, Speaker: "SPEAKER_00"}, {Text: "Who?", Start: 1, End: 2}},
+			want: "1\n00:00:00,000 --> 00:00:01,000\nHi\n\n" +
+				"2\n00:00:01,000 --> 00:00:02,000\nWho?\n\n",
+		},
+		{
+			name: "split cues",
+			cues: []Cue{{Text: "one two six ten", Start: 0, End: 4}},
+			opts: WrapOptions{MaxLineChars: 7, MaxLines: 1},
+			want: "1\n00:00:00,000 --> 00:00:02,000\none two\n\n" +
+				"2\n00:00:02,000 --> 00:00:04,000\nsix ten\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SRT(tt.cues, tt.opts); got != tt.want {
+				t.Fatalf("SRT() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCueKeepsSpeakerAndConfidence(t *testing.T) {
+	confidence := 0.8
+	got := SplitCue(Cue{Text: "one two six ten", Start: 0, End: 4, Speaker: "SPEAKER_01", Confidence: &confidence}, WrapOptions{MaxLineChars: 7, MaxLines: 1})
+	want := []Cue{
+		{Text: "one two", Start: 0, End: 2, Speaker: "SPEAKER_01", Confidence: &confidence},
+		{Text: "six ten", Start: 2, End: 4, Speaker: "SPEAKER_01", Confidence: &confidence},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitCue() = %+v, want %+v", got, want)
+	}
+}
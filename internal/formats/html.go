@@ -0,0 +1,81 @@
+package formats
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// Confidence thresholds for the HTML highlight classes
+const (
+	HighConfidence   = 0.8
+	MediumConfidence = 0.5
+)
+
+// htmlTemplate is a self-contained page, so it can be opened straight from disk
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transcript</title>
+<style>
+body { font-family: sans-serif; line-height: 1.6; max-width: 48em; margin: 2em auto; padding: 0 1em; }
+.segment { padding: 0.1em 0.2em; border-radius: 0.2em; }
+.segment time { color: #888; font-size: 0.8em; margin-right: 0.4em; }
+.confidence-medium { background: #fff3bf; }
+.confidence-low { background: #ffc9c9; }
+</style>
+</head>
+<body>
+<p>
+{{- range .}}
+<span class="segment {{.Class}}" data-start="{{.Start}}" data-end="{{.End}}"{{if .Confidence}} data-confidence="{{.Confidence}}" title="confidence {{.Confidence}}"{{end}}><time>{{.Clock}}</time>{{.Text}}</span>
+{{- end}}
+</p>
+</body>
+</html>
+`))
+
+// htmlCue is a cue prepared for the HTML template
+type htmlCue struct {
+	Text       string
+	Start      string
+	End        string
+	Clock      string
+	Confidence string
+	Class      string
+}
+
+// HTML renders cues as a standalone HTML page. Each cue becomes a span with its timestamps
+// in data attributes, highlighted when its confidence is medium or low.
+func HTML(cues []Cue) string {
+	items := make([]htmlCue, 0, len(cues))
+	for _, cue := range cues {
+		item := htmlCue{
+			Text:  strings.TrimSpace(cue.Text),
+			Start: fmt.Sprintf("%.3f", cue.Start),
+			End:   fmt.Sprintf("%.3f", cue.End),
+			Clock: FormatClock(cue.Start),
+		}
+		if cue.Confidence != nil {
+			item.Confidence = fmt.Sprintf("%.2f", *cue.Confidence)
+			item.Class = confidenceClass(*cue.Confidence)
+		}
+		items = append(items, item)
+	}
+
+	var b strings.Builder
+	_ = htmlTemplate.Execute(&b, items)
+	return b.String()
+}
+
+// confidenceClass picks the highlight class for a confidence between 0 and 1
+func confidenceClass(confidence float64) string {
+	switch {
+	case confidence >= HighConfidence:
+		return "confidence-high"
+	case confidence >= MediumConfidence:
+		return "confidence-medium"
+	}
+	return "confidence-low"
+}
@@ -0,0 +1,35 @@
+package formats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markdownEscaper backslash-escapes the characters that would start inline Markdown syntax.
+// Block syntax needs no escaping since every line starts with a timestamp link.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "~", `\~`, "&", `\&`, "!", `\!`, "|", `\|`,
+)
+
+// linkEscaper keeps characters that would end a Markdown link destination out of the URL
+var linkEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+
+// Markdown renders cues as a Markdown document with one paragraph per cue, each starting
+// with a [HH:MM:SS] link. In linkTemplate, {seconds} is replaced with the whole seconds and
+// {timestamp} with the HH:MM:SS clock of the cue start.
+func Markdown(cues []Cue, linkTemplate string) string {
+	var b strings.Builder
+	for i, cue := range cues {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		clock := FormatClock(cue.Start)
+		link := strings.NewReplacer(
+			"{seconds}", fmt.Sprint(int64(max(0, cue.Start))),
+			"{timestamp}", clock,
+		).Replace(linkTemplate)
+		fmt.Fprintf(&b, "[%s](%s) %s\n", clock, linkEscaper.Replace(link), markdownEscaper.Replace(strings.TrimSpace(cue.Text)))
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package formats
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// vttEscaper escapes the characters WebVTT cue text reserves for markup. Escaping ">" also
+// keeps "-->" out of cue text, where it would be read as a timing line.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// vttAnnotationEscaper keeps a voice name from closing its <v> tag early
+var vttAnnotationEscaper = strings.NewReplacer("&", "&amp;", ">", "&gt;", "\n", " ")
+
+// VTTSettings are the cue settings written after every WebVTT timing line, positioning
+// the cues on the video. Empty fields are left to the player.
+type VTTSettings struct {
+	Align    string // start, center, end, left or right
+	Line     string // a line number or percentage, optionally followed by ,start|center|end
+	Position string // a percentage, optionally followed by ,line-left|center|line-right
+	Size     string // a percentage
+}
+
+var (
+	vttPercentage = `(\d{1,2}(\.\d+)?|100(\.0+)?)%`
+	vttLine       = regexp.MustCompile(`^(-?\d+|` + vttPercentage + `)(,(start|center|end))?$`)
+	vttPosition   = regexp.MustCompile(`^` + vttPercentage + `(,(line-left|center|line-right))?$`)
+	vttSize       = regexp.MustCompile(`^` + vttPercentage + `$`)
+)
+
+// Validate checks every setting against the WebVTT syntax
+func (s VTTSettings) Validate() error {
+	switch s.Align {
+	case "", "start", "center", "end", "left", "right":
+	default:
+		return fmt.Errorf("invalid vtt_align %q (expected start, center, end, left or right)", s.Align)
+	}
+	if s.Line != "" && !vttLine.MatchString(s.Line) {
+		return fmt.Errorf("invalid vtt_line %q (expected a line number or percentage, e.g. -2 or 90%%)", s.Line)
+	}
+	if s.Position != "" && !vttPosition.MatchString(s.Position) {
+		return fmt.Errorf("invalid vtt_position %q (expected a percentage, e.g. 50%%)", s.Position)
+	}
+	if s.Size != "" && !vttSize.MatchString(s.Size) {
+		return fmt.Errorf("invalid vtt_size %q (expected a percentage, e.g. 80%%)", s.Size)
+	}
+	return nil
+}
+
+// String renders the settings as they follow the timing line, with a leading space
+func (s VTTSettings) String() string {
+	var b strings.Builder
+	for _, setting := range []struct{ name, value string }{
+		{"align", s.Align}, {"line", s.Line}, {"position", s.Position}, {"size", s.Size},
+	} {
+		if setting.value != "" {
+			fmt.Fprintf(&b, " %s:%s", setting.name, setting.value)
+		}
+	}
+	return b.String()
+}
+
+// VTT renders cues as a WebVTT subtitle file with the given cue settings. Cue text is
+// escaped, and cues with a speaker carry it as a <v> voice span.
+func VTT(cues []Cue, opts WrapOptions, settings VTTSettings) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		for _, part := range SplitCue(cue, opts) {
+			text := vttEscaper.Replace(part.Text)
+			if cue.Speaker != "" {
+				text = fmt.Sprintf("<v %s>%s", vttAnnotationEscaper.Replace(SpeakerName(cue.Speaker)), text)
+			}
+			fmt.Fprintf(&b, "%s --> %s%s\n%s\n\n",
+				FormatTimestamp(part.Start, "."),
+				FormatTimestamp(max(part.End, part.Start), "."),
+				settings,
+				text,
+			)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVTT(t *testing.T) {
+	tests := []struct {
+		name     string
+		cues     []Cue
+		settings VTTSettings
+		want     string
+	}{
+		{
+			name: "timing",
+			cues: []Cue{{Text: "Hello", Start: 0, End: 1.5}, {Text: "again", Start: 3661.2345, End: 3662}},
+			want: "WEBVTT\n\n" +
+				"00:00:00.000 --> 00:00:01.500\nHello\n\n" +
+				"01:01:01.235 --> 01:01:02.000\nagain\n\n",
+		},
+		{
+			name: "end before start",
+			cues: []Cue{{Text: "Hi", Start: 2, End: 1}},
+			want: "WEBVTT\n\n00:00:02.000 --> 00:00:02.000\nHi\n\n",
+		},
+		{
+			name: "escaping",
+			cues: []Cue{{Text: "Tom & Jerry <3 a --> b", Start: 0, End: 1}},
+			want: "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nTom &amp; Jerry &lt;3 a --&gt; b\n\n",
+		},
+		{
+			name:     "settings",
+			cues:     []Cue{{Text: "Hi", Start: 0, End: 1}},
+			settings: VTTSettings{Align: "start", Line: "-2", Position: "10%,line-left", Size: "80%"},
+			want:     "WEBVTT\n\n00:00:00.000 --> 00:00:01.000 align:start line:-2 position:10%,line-left size:80%\nHi\n\n",
+		},
+		{
+			name: "voice span",
+			cues: []Cue{{Text: "Hi", Start: 0, End: 1, Speaker: "SPEAKER_01"}, {Text: "Yo", Start: 1, End: 2, Speaker: "Ann & <Bob>"}},
+			want: "WEBVTT\n\n" +
+				"00:00:00.000 --> 00:00:01.000\n<v Speaker 2>Hi\n\n" +
+				"00:00:01.000 --> 00:00:02.000\n<v Ann &amp; <Bob&gt;>Yo\n\n",
+		},
+		{
+			name: "empty cue",
+			cues: []Cue{{Text: "  ", Start: 0, End: 1}},
+			want: "WEBVTT\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VTT(tt.cues, WrapOptions{}, tt.settings); got != tt.want {
+				t.Fatalf("VTT() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVTTWrappedCuesKeepTheirVoice(t *testing.T) {
+	cues := []Cue{{Text: "one two three four", Start: 0, End: 4, Speaker: "SPEAKER_00"}}
+	got := VTT(cues, WrapOptions{MaxLineChars: 9, MaxLines: 1}, VTTSettings{})
+	for _, block := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(got, "WEBVTT\n\n"), "\n\n"), "\n\n") {
+		lines := strings.Split(block, "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[1], "<v Speaker 1>") {
+			t.Fatalf("cue %q doesn't carry the voice span", block)
+		}
+	}
+}
+
+func TestVTTSettingsValidate(t *testing.T) {
+	tests := []struct {
+		settings VTTSettings
+		valid    bool
+	}{
+		{VTTSettings{}, true},
+		{VTTSettings{Align: "center", Line: "90%,end", Position: "50%,center", Size: "100%"}, true},
+		{VTTSettings{Line: "0"}, true},
+		{VTTSettings{Align: "middle"}, false},
+		{VTTSettings{Line: "top"}, false},
+		{VTTSettings{Position: "50"}, false},
+		{VTTSettings{Position: "101%"}, false},
+		{VTTSettings{Size: "80%,start"}, false},
+		{VTTSettings{Size: "80% position:0%"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.settings.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v.Validate() = %v, want valid %v", tt.settings, err, tt.valid)
+		}
+	}
+}
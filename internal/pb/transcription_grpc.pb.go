@@ -0,0 +1,180 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: transcription.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Transcription_Transcribe_FullMethodName       = "/transcription.Transcription/Transcribe"
+	Transcription_TranscribeStream_FullMethodName = "/transcription.Transcription/TranscribeStream"
+)
+
+// TranscriptionClient is the client API for Transcription service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Transcription is the gRPC counterpart of the HTTP transcription API
+type TranscriptionClient interface {
+	Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscriptionResponse, error)
+	TranscribeStream(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (Transcription_TranscribeStreamClient, error)
+}
+
+type transcriptionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTranscriptionClient(cc grpc.ClientConnInterface) TranscriptionClient {
+	return &transcriptionClient{cc}
+}
+
+func (c *transcriptionClient) Transcribe(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (*TranscriptionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TranscriptionResponse)
+	err := c.cc.Invoke(ctx, Transcription_Transcribe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcriptionClient) TranscribeStream(ctx context.Context, in *TranscribeRequest, opts ...grpc.CallOption) (Transcription_TranscribeStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transcription_ServiceDesc.Streams[0], Transcription_TranscribeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &transcriptionTranscribeStreamClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Transcription_TranscribeStreamClient interface {
+	Recv() (*SegmentChunk, error)
+	grpc.ClientStream
+}
+
+type transcriptionTranscribeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *transcriptionTranscribeStreamClient) Recv() (*SegmentChunk, error) {
+	m := new(SegmentChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TranscriptionServer is the server API for Transcription service.
+// All implementations must embed UnimplementedTranscriptionServer
+// for forward compatibility
+//
+// Transcription is the gRPC counterpart of the HTTP transcription API
+type TranscriptionServer interface {
+	Transcribe(context.Context, *TranscribeRequest) (*TranscriptionResponse, error)
+	TranscribeStream(*TranscribeRequest, Transcription_TranscribeStreamServer) error
+	mustEmbedUnimplementedTranscriptionServer()
+}
+
+// UnimplementedTranscriptionServer must be embedded to have forward compatible implementations.
+type UnimplementedTranscriptionServer struct {
+}
+
+func (UnimplementedTranscriptionServer) Transcribe(context.Context, *TranscribeRequest) (*TranscriptionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transcribe not implemented")
+}
+func (UnimplementedTranscriptionServer) TranscribeStream(*TranscribeRequest, Transcription_TranscribeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method TranscribeStream not implemented")
+}
+func (UnimplementedTranscriptionServer) mustEmbedUnimplementedTranscriptionServer() {}
+
+// UnsafeTranscriptionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TranscriptionServer will
+// result in compilation errors.
+type UnsafeTranscriptionServer interface {
+	mustEmbedUnimplementedTranscriptionServer()
+}
+
+func RegisterTranscriptionServer(s grpc.ServiceRegistrar, srv TranscriptionServer) {
+	s.RegisterService(&Transcription_ServiceDesc, srv)
+}
+
+func _Transcription_Transcribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscriptionServer).Transcribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transcription_Transcribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscriptionServer).Transcribe(ctx, req.(*TranscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transcription_TranscribeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TranscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TranscriptionServer).TranscribeStream(m, &transcriptionTranscribeStreamServer{ServerStream: stream})
+}
+
+type Transcription_TranscribeStreamServer interface {
+	Send(*SegmentChunk) error
+	grpc.ServerStream
+}
+
+type transcriptionTranscribeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *transcriptionTranscribeStreamServer) Send(m *SegmentChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Transcription_ServiceDesc is the grpc.ServiceDesc for Transcription service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Transcription_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transcription.Transcription",
+	HandlerType: (*TranscriptionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transcribe",
+			Handler:    _Transcription_Transcribe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "TranscribeStream",
+			Handler:       _Transcription_TranscribeStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "transcription.proto",
+}
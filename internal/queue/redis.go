@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces the queue's keys in a Redis shared with other applications
+const keyPrefix = "transcription:"
+
+// Keys of the queue: a list of job IDs waiting, a sorted set of claimed IDs scored by the
+// time their claim expires, and a hash per job with its payload, audio and attempt count
+const (
+	pendingKey  = keyPrefix + "pending"
+	inflightKey = keyPrefix + "inflight"
+	jobKey      = keyPrefix + "job:"
+)
+
+// Task is a claimed job
+type Task struct {
+	ID      string
+	Payload []byte // what the enqueuing instance needs the worker to know about the job
+	Audio   []byte // the upload
+	Attempt int    // 1 on the first claim, more after claims that expired
+}
+
+// Redis is a job queue in Redis that any number of instances enqueue to and claim from.
+// A claim is only good for the visibility timeout; the worker extends it while it works,
+// and claims that lapse because their worker crashed are requeued until a job has been
+// tried maxAttempts times. Jobs are delivered at least once.
+type Redis struct {
+	client      *redis.Client
+	visibility  time.Duration
+	maxAttempts int
+}
+
+// NewRedis connects to the Redis at url, a redis:// or rediss:// URL
+func NewRedis(ctx context.Context, url string, visibility time.Duration, maxAttempts int) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return &Redis{client: client, visibility: visibility, maxAttempts: max(maxAttempts, 1)}, nil
+}
+
+// Close disconnects from Redis
+func (q *Redis) Close() error {
+	return q.client.Close()
+}
+
+// Visibility returns how long a claim lasts without being extended
+func (q *Redis) Visibility() time.Duration {
+	return q.visibility
+}
+
+// Enqueue stores a job and puts it at the back of the queue
+func (q *Redis) Enqueue(ctx context.Context, id string, payload, audio []byte) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobKey+id, "payload", payload, "audio", audio, "attempts", 0)
+		pipe.LPush(ctx, pendingKey, id)
+		return nil
+	})
+	return err
+}
+
+// claimScript moves the oldest waiting job to the claimed set, timing the claim by the
+// server's clock so instances with skewed clocks agree
+var claimScript = redis.NewScript(`
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+local now = redis.call('TIME')
+local deadline = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000) + tonumber(ARGV[1])
+redis.call('ZADD', KEYS[2], deadline, id)
+local attempts = redis.call('HINCRBY', ARGV[2] .. id, 'attempts', 1)
+return {id, attempts}
+`)
+
+// Claim takes the oldest waiting job, reporting false when there is none
+func (q *Redis) Claim(ctx context.Context) (Task, bool, error) {
+	result, err := claimScript.Run(ctx, q.client, []string{pendingKey, inflightKey}, q.visibility.Milliseconds(), jobKey).Slice()
+	if errors.Is(err, redis.Nil) {
+		return Task{}, false, nil
+	}
+	if err != nil {
+		return Task{}, false, err
+	}
+	task := Task{ID: fmt.Sprint(result[0])}
+	if attempts, ok := result[1].(int64); ok {
+		task.Attempt = int(attempts)
+	}
+
+	fields, err := q.client.HMGet(ctx, jobKey+task.ID, "payload", "audio").Result()
+	if err != nil {
+		return Task{}, false, err
+	}
+	payload, _ := fields[0].(string)
+	audio, _ := fields[1].(string)
+	if payload == "" {
+		// The job was dropped meanwhile; nothing is left to run
+		return Task{}, false, q.Ack(ctx, task.ID)
+	}
+	task.Payload, task.Audio = []byte(payload), []byte(audio)
+	return task, true, nil
+}
+
+// extendScript pushes a claim's deadline out, unless the claim already lapsed
+var extendScript = redis.NewScript(`
+if not redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	return 0
+end
+local now = redis.call('TIME')
+local deadline = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000) + tonumber(ARGV[2])
+redis.call('ZADD', KEYS[1], 'XX', deadline, ARGV[1])
+return 1
+`)
+
+// ErrClaimLost is returned when extending a claim that lapsed and may have gone to another worker
+var ErrClaimLost = errors.New("claim on the job lapsed")
+
+// Extend renews the claim on a job for another visibility timeout
+func (q *Redis) Extend(ctx context.Context, id string) error {
+	extended, err := extendScript.Run(ctx, q.client, []string{inflightKey}, id, q.visibility.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if extended == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// Ack removes a finished job from the queue along with its audio
+func (q *Redis) Ack(ctx context.Context, id string) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, inflightKey, id)
+		pipe.Del(ctx, jobKey+id)
+		return nil
+	})
+	return err
+}
+
+// requeueScript returns jobs whose claims lapsed to the front of the queue, and drops the
+// ones that have used up their attempts
+var requeueScript = redis.NewScript(`
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', nowMs)
+local requeued, dropped = {}, {}
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	local attempts = tonumber(redis.call('HGET', ARGV[2] .. id, 'attempts') or '0')
+	if attempts >= tonumber(ARGV[1]) then
+		redis.call('DEL', ARGV[2] .. id)
+		table.insert(dropped, id)
+	else
+		redis.call('RPUSH', KEYS[1], id)
+		table.insert(requeued, id)
+	end
+end
+return {requeued, dropped}
+`)
+
+// RequeueExpired puts jobs whose workers stopped extending their claims back in the queue.
+// Jobs that were already tried maxAttempts times are dropped and returned as dropped.
+func (q *Redis) RequeueExpired(ctx context.Context) (requeued, dropped []string, err error) {
+	result, err := requeueScript.Run(ctx, q.client, []string{pendingKey, inflightKey}, strconv.Itoa(q.maxAttempts), jobKey).Slice()
+	if err != nil {
+		return nil, nil, err
+	}
+	return stringList(result[0]), stringList(result[1]), nil
+}
+
+// stringList converts a Lua array of strings from a script reply
+func stringList(value any) []string {
+	items, _ := value.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, fmt.Sprint(item))
+	}
+	return out
+}
+
+// Depth reports how many jobs are waiting and how many are claimed
+func (q *Redis) Depth(ctx context.Context) (pending, inflight int64, err error) {
+	pipe := q.client.Pipeline()
+	pendingCmd := pipe.LLen(ctx, pendingKey)
+	inflightCmd := pipe.ZCard(ctx, inflightKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return pendingCmd.Val(), inflightCmd.Val(), nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// S3 is a Store backed by Amazon S3 or an S3-compatible service
+type S3 struct {
+	client *s3.Client
+}
+
+// NewS3 creates an S3 store with credentials and region from the standard AWS sources:
+// environment variables, shared config files and the instance metadata service. A custom
+// endpoint, for MinIO and other S3-compatible services, switches to path-style addressing.
+func NewS3(ctx context.Context, endpoint string) (*S3, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3{client: client}, nil
+}
+
+// Download saves the object into dir
+func (s *S3) Download(ctx context.Context, loc Location, dir string, maxBytes int64) (string, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(loc.Bucket), Key: aws.String(loc.Key)})
+	if err != nil {
+		return "", s3Error(err)
+	}
+	defer output.Body.Close()
+	if maxBytes > 0 && aws.ToInt64(output.ContentLength) > maxBytes {
+		return "", ErrTooLarge
+	}
+
+	destination := filepath.Join(dir, objectFileName(loc.Key))
+	f, err := os.Create(destination)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	body := io.Reader(output.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(output.Body, maxBytes+1)
+	}
+	written, err := io.Copy(f, body)
+	if err == nil && maxBytes > 0 && written > maxBytes {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(destination)
+		return "", err
+	}
+	return destination, nil
+}
+
+// Upload writes data to the object
+func (s *S3) Upload(ctx context.Context, loc Location, data []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(loc.Bucket),
+		Key:         aws.String(loc.Key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return s3Error(err)
+	}
+	return nil
+}
+
+// Delete removes the object
+func (s *S3) Delete(ctx context.Context, loc Location) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(loc.Bucket), Key: aws.String(loc.Key)})
+	if err != nil {
+		return s3Error(err)
+	}
+	return nil
+}
+
+// s3Error wraps S3 failures in the package errors callers can tell apart
+func s3Error(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		var kind error
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NoSuchBucket", "NotFound":
+			kind = ErrNotFound
+		case "AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			kind = ErrAccessDenied
+		}
+		if kind != nil && apiErr.ErrorMessage() != "" {
+			return fmt.Errorf("%w: %s", kind, apiErr.ErrorMessage())
+		}
+		if kind != nil {
+			return fmt.Errorf("%w: %s", kind, apiErr.ErrorCode())
+		}
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		switch statusErr.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		case http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrAccessDenied, err)
+		}
+	}
+	return err
+}
+
+// objectFileName picks a safe local name for an object key
+func objectFileName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, path.Base(key))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "object"
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the bucket or object doesn't exist
+	ErrNotFound = errors.New("object not found")
+	// ErrAccessDenied is returned when the credentials may not read or write the object
+	ErrAccessDenied = errors.New("access denied")
+	// ErrTooLarge is returned when an object is bigger than the download limit
+	ErrTooLarge = errors.New("object too large")
+	// ErrInvalidLocation is returned for URLs that don't name a bucket and key
+	ErrInvalidLocation = errors.New("expected s3://bucket/key")
+)
+
+// Location is an object in a bucket
+type Location struct {
+	Bucket string
+	Key    string
+}
+
+func (l Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Key
+}
+
+// IsStorageURL reports whether a URL points into object storage rather than at a web server
+func IsStorageURL(rawURL string) bool {
+	return strings.HasPrefix(strings.ToLower(rawURL), "s3://")
+}
+
+// ParseURL parses s3://bucket/key
+func ParseURL(rawURL string) (Location, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "s3") || parsed.Host == "" {
+		return Location{}, ErrInvalidLocation
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if key == "" || strings.HasSuffix(key, "/") {
+		return Location{}, ErrInvalidLocation
+	}
+	return Location{Bucket: parsed.Host, Key: key}, nil
+}
+
+// ParsePrefix parses where outputs go: a bucket name, bucket/prefix, or s3://bucket/prefix.
+// A non-empty prefix always ends in a slash, so keys can be appended directly.
+func ParsePrefix(value string) (Location, error) {
+	value = strings.TrimPrefix(value, "s3://")
+	bucket, prefix, _ := strings.Cut(value, "/")
+	if bucket == "" || strings.ContainsAny(bucket, " \\?#") {
+		return Location{}, fmt.Errorf("invalid output bucket %q", value)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return Location{Bucket: bucket, Key: prefix}, nil
+}
+
+// Join returns the location of name under a prefix location
+func (l Location) Join(name string) Location {
+	return Location{Bucket: l.Bucket, Key: l.Key + path.Base(name)}
+}
+
+// Store reads and writes objects in an object store
+type Store interface {
+	// Download saves the object into dir, named after the last element of its key
+	Download(ctx context.Context, loc Location, dir string, maxBytes int64) (string, error)
+	// Upload writes data to the object, replacing it if it exists
+	Upload(ctx context.Context, loc Location, data []byte, contentType string) error
+	// Delete removes the object; deleting one that doesn't exist is not an error
+	Delete(ctx context.Context, loc Location) error
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Output is a result object written to object storage, tracked until it is due for deletion
+type Output struct {
+	Bucket    string
+	Key       string
+	ExpiresAt time.Time
+}
+
+// migrateRetention creates the table of result objects waiting to be deleted
+func (s *Store) migrateRetention(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS outputs (
+		bucket TEXT NOT NULL,
+		object_key TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (bucket, object_key)
+	)`)
+	return err
+}
+
+// Delete removes a job and its indexed segments
+func (s *Store) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM segments WHERE job_id = ?`), id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM jobs WHERE id = ?`), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FinishedBefore returns the IDs of up to limit jobs that finished before t
+func (s *Store) FinishedBefore(ctx context.Context, t time.Time, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id FROM jobs WHERE finished_at < ? ORDER BY finished_at LIMIT ?`), s.timeArg(t), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// TrackOutput records a result object to be deleted at expiresAt
+func (s *Store) TrackOutput(ctx context.Context, output Output) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO outputs (bucket, object_key, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, object_key) DO UPDATE SET expires_at = excluded.expires_at`),
+		output.Bucket, output.Key, s.timeArg(output.ExpiresAt))
+	return err
+}
+
+// ExpiredOutputs returns up to limit tracked result objects due for deletion at now
+func (s *Store) ExpiredOutputs(ctx context.Context, now time.Time, limit int) ([]Output, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT bucket, object_key, expires_at FROM outputs WHERE expires_at <= ? ORDER BY expires_at LIMIT ?`), s.timeArg(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outputs []Output
+	for rows.Next() {
+		var output Output
+		if err := rows.Scan(&output.Bucket, &output.Key, &output.ExpiresAt); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, rows.Err()
+}
+
+// ForgetOutput stops tracking a result object once it has been deleted
+func (s *Store) ForgetOutput(ctx context.Context, bucket, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM outputs WHERE bucket = ? AND object_key = ?`), bucket, key)
+	return err
+}
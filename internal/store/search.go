@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Segment is a timed piece of a completed job's transcript, indexed for search
+type Segment struct {
+	JobID   string
+	Index   int
+	Start   float64
+	End     float64
+	Speaker string
+	Text    string
+}
+
+// SearchHit is a segment matching a search, with the job it belongs to
+type SearchHit struct {
+	Segment
+	Filename  string
+	CreatedAt time.Time
+}
+
+// migrateSearch creates the segment index: an FTS5 table in SQLite, a table with a
+// generated tsvector column and a GIN index in Postgres. Both stem English words, so
+// "refund" also finds "refunds".
+func (s *Store) migrateSearch(ctx context.Context) error {
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS segments USING fts5(
+			job_id UNINDEXED, idx UNINDEXED, start_time UNINDEXED, end_time UNINDEXED, speaker UNINDEXED, text,
+			tokenize = 'porter unicode61')`,
+	}
+	if s.postgres {
+		statements = []string{
+			`CREATE TABLE IF NOT EXISTS segments (
+				job_id TEXT NOT NULL,
+				idx INTEGER NOT NULL,
+				start_time DOUBLE PRECISION NOT NULL,
+				end_time DOUBLE PRECISION NOT NULL,
+				speaker TEXT NOT NULL,
+				text TEXT NOT NULL,
+				search TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', text)) STORED,
+				PRIMARY KEY (job_id, idx)
+			)`,
+			`CREATE INDEX IF NOT EXISTS segments_search ON segments USING GIN (search)`,
+		}
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexSegments replaces the indexed segments of a job
+func (s *Store) IndexSegments(ctx context.Context, jobID string, segments []Segment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM segments WHERE job_id = ?`), jobID); err != nil {
+		return err
+	}
+	insert, err := tx.PrepareContext(ctx, s.rebind(`INSERT INTO segments (job_id, idx, start_time, end_time, speaker, text) VALUES (?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, segment := range segments {
+		if _, err := insert.ExecContext(ctx, jobID, segment.Index, segment.Start, segment.End, segment.Speaker, segment.Text); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Search returns the segments matching a query, best matches first. Words must all appear
+// in a segment; "quoted phrases" must appear as written.
+func (s *Store) Search(ctx context.Context, query string, limit, offset int) ([]SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var rows *sql.Rows
+	var err error
+	if s.postgres {
+		rows, err = s.db.QueryContext(ctx, `SELECT s.job_id, s.idx, s.start_time, s.end_time, s.speaker, s.text, j.filename, j.created_at
+			FROM segments s JOIN jobs j ON j.id = s.job_id, websearch_to_tsquery('english', $1) q
+			WHERE s.search @@ q
+			ORDER BY ts_rank(s.search, q) DESC, j.created_at DESC, s.idx
+			LIMIT $2 OFFSET $3`, strings.Join(terms, " "), limit, offset)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `SELECT s.job_id, s.idx, s.start_time, s.end_time, s.speaker, s.text, j.filename, j.created_at
+			FROM segments s JOIN jobs j ON j.id = s.job_id
+			WHERE segments MATCH ?
+			ORDER BY s.rank, j.created_at DESC, s.idx
+			LIMIT ? OFFSET ?`, strings.Join(terms, " "), limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.JobID, &hit.Index, &hit.Start, &hit.End, &hit.Speaker, &hit.Text, &hit.Filename, &hit.CreatedAt); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// searchTerms splits a query into double-quoted words and phrases, which both FTS5 and
+// websearch_to_tsquery read literally, so punctuation in queries can't be a syntax error
+func searchTerms(query string) []string {
+	var terms []string
+	add := func(term string) {
+		words := strings.FieldsFunc(term, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+		})
+		if len(words) > 0 {
+			terms = append(terms, `"`+strings.ReplaceAll(strings.Join(words, " "), `"`, "")+`"`)
+		}
+	}
+
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			add(part) // inside quotes: one phrase
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word)
+		}
+	}
+	return terms
+}
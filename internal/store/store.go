@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	_ "modernc.org/sqlite"             // registers the "sqlite" driver
+)
+
+// ErrNotFound is returned when no job has the requested ID
+var ErrNotFound = errors.New("job not found")
+
+// Job is the persisted record of an asynchronous transcription
+type Job struct {
+	ID         string
+	Status     string
+	Filename   string
+	Model      string
+	Duration   float64 // processing time in seconds, once finished
+	CreatedAt  time.Time
+	StartedAt  time.Time       // zero until the job starts
+	FinishedAt time.Time       // zero until the job finishes
+	Result     json.RawMessage // the JSON result of a completed job
+	Error      json.RawMessage // the error body of a failed job
+}
+
+// Store persists jobs in SQLite or Postgres
+type Store struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// IsPostgresURL reports whether dsn names a Postgres database rather than a SQLite file
+func IsPostgresURL(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// Open connects to the database named by dsn: a postgres:// or postgresql:// URL for
+// Postgres, otherwise the path of a SQLite file, optionally prefixed with sqlite://. The
+// schema is created when missing.
+func Open(ctx context.Context, dsn string) (*Store, error) {
+	s := &Store{}
+	var err error
+	switch {
+	case IsPostgresURL(dsn):
+		s.postgres = true
+		s.db, err = sql.Open("pgx", dsn)
+	default:
+		path := strings.TrimPrefix(dsn, "sqlite://")
+		s.db, err = sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.postgres {
+		// SQLite allows one writer; a single connection avoids "database is locked"
+		s.db.SetMaxOpenConns(1)
+	}
+	if err := s.migrate(ctx); err != nil {
+		s.db.Close()
+		return nil, fmt.Errorf("creating job tables: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate creates the jobs table and the tables kept alongside it
+func (s *Store) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		status TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		model TEXT NOT NULL,
+		duration_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP,
+		result TEXT,
+		error TEXT
+	)`)
+	if err != nil {
+		return err
+	}
+	if _, err = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS jobs_created_at ON jobs (created_at)`); err != nil {
+		return err
+	}
+	if err = s.migrateSearch(ctx); err != nil {
+		return err
+	}
+	return s.migrateRetention(ctx)
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... for Postgres
+func (s *Store) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Save inserts the job or replaces its stored state
+func (s *Store) Save(ctx context.Context, job Job) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO jobs (id, status, filename, model, duration_seconds, created_at, started_at, finished_at, result, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, duration_seconds = excluded.duration_seconds,
+			started_at = excluded.started_at, finished_at = excluded.finished_at, result = excluded.result, error = excluded.error`),
+		job.ID, job.Status, job.Filename, job.Model, job.Duration, s.timeArg(job.CreatedAt),
+		s.nullTimeArg(job.StartedAt), s.nullTimeArg(job.FinishedAt), nullJSON(job.Result), nullJSON(job.Error))
+	return err
+}
+
+// Get returns the job with the given ID
+func (s *Store) Get(ctx context.Context, id string) (Job, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`), id)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, ErrNotFound
+	}
+	return job, err
+}
+
+// FailUnfinished marks jobs that were queued or running, which a restart interrupted, as
+// failed with the given error body, and returns how many there were
+func (s *Store) FailUnfinished(ctx context.Context, errorBody json.RawMessage) (int64, error) {
+	result, err := s.db.ExecContext(ctx, s.rebind(`UPDATE jobs SET status = 'failed', finished_at = ?, error = ?
+		WHERE status IN ('queued', 'running')`), s.timeArg(time.Now()), string(errorBody))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// jobColumns lists the columns scanJob reads, in order
+const jobColumns = `id, status, filename, model, duration_seconds, created_at, started_at, finished_at, result, error`
+
+// scanJob reads a job selected with jobColumns
+func scanJob(row interface{ Scan(...any) error }) (Job, error) {
+	var job Job
+	var startedAt, finishedAt sql.NullTime
+	var result, errorBody sql.NullString
+	err := row.Scan(&job.ID, &job.Status, &job.Filename, &job.Model, &job.Duration, &job.CreatedAt, &startedAt, &finishedAt, &result, &errorBody)
+	if err != nil {
+		return Job{}, err
+	}
+	job.StartedAt = startedAt.Time
+	job.FinishedAt = finishedAt.Time
+	if result.Valid {
+		job.Result = json.RawMessage(result.String)
+	}
+	if errorBody.Valid {
+		job.Error = json.RawMessage(errorBody.String)
+	}
+	return job, nil
+}
+
+// sqliteTimeFormat is a fixed-width UTC layout, so times stored as text in SQLite compare
+// and sort like the times themselves
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000-07:00"
+
+// timeArg prepares a time for a query
+func (s *Store) timeArg(t time.Time) any {
+	if s.postgres {
+		return t.UTC()
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// nullTimeArg prepares a time for a query, storing zero times as NULL
+func (s *Store) nullTimeArg(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return s.timeArg(t)
+}
+
+// nullJSON stores missing JSON as NULL
+func nullJSON(data json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(data), Valid: len(data) > 0}
+}
+
+// Filter selects jobs for List
+type Filter struct {
+	Status string    // empty for any status
+	From   time.Time // created at or after, zero for no bound
+	To     time.Time // created before, zero for no bound
+	Limit  int
+	// Jobs created before this one, in listing order, for the next page
+	AfterCreatedAt time.Time
+	AfterID        string
+}
+
+// jobSummaryColumns is jobColumns without the result and error bodies, for listings
+const jobSummaryColumns = `id, status, filename, model, duration_seconds, created_at, started_at, finished_at, NULL, NULL`
+
+// List returns jobs matching the filter, newest first, without their results
+func (s *Store) List(ctx context.Context, filter Filter) ([]Job, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, s.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, s.timeArg(filter.To))
+	}
+	if filter.AfterID != "" {
+		conditions = append(conditions, "(created_at < ? OR (created_at = ? AND id < ?))")
+		after := s.timeArg(filter.AfterCreatedAt)
+		args = append(args, after, after, filter.AfterID)
+	}
+
+	query := `SELECT ` + jobSummaryColumns + ` FROM jobs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Matches reports whether a job passes the filter, for lists built outside the database
+func (f Filter) Matches(job Job) bool {
+	switch {
+	case f.Status != "" && job.Status != f.Status:
+		return false
+	case !f.From.IsZero() && job.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !job.CreatedAt.Before(f.To):
+		return false
+	case f.AfterID != "" && !(job.CreatedAt.Before(f.AfterCreatedAt) || (job.CreatedAt.Equal(f.AfterCreatedAt) && job.ID < f.AfterID)):
+		return false
+	}
+	return true
+}
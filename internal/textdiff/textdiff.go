@@ -0,0 +1,192 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind says how a stretch of words differs between the two texts
+type Kind string
+
+// Diff operation kinds
+const (
+	Equal   Kind = "equal"
+	Removed Kind = "removed" // only in the first text
+	Added   Kind = "added"   // only in the second text
+	Changed Kind = "changed" // replaced: removed words directly followed by added ones
+)
+
+// Op is one stretch of the diff. A holds the words from the first text and B those from
+// the second; equal stretches carry the first text's words in A.
+type Op struct {
+	Kind Kind
+	A    []string
+	B    []string
+}
+
+// maxEdits bounds the search so very different long texts can't take quadratic memory.
+// Past it the differing middle is reported as one change.
+const maxEdits = 2000
+
+// Words diffs two texts word by word. Words are compared ignoring case and surrounding
+// punctuation, so "Hello," and "hello" count as the same word.
+func Words(a, b string) []Op {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	keysA, keysB := normalize(wordsA), normalize(wordsB)
+
+	// Common ends are cheap to strip and keep the search small
+	prefix := 0
+	for prefix < len(keysA) && prefix < len(keysB) && keysA[prefix] == keysB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(keysA)-prefix && suffix < len(keysB)-prefix && keysA[len(keysA)-1-suffix] == keysB[len(keysB)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	if prefix > 0 {
+		ops = append(ops, Op{Kind: Equal, A: wordsA[:prefix]})
+	}
+	midA, midB := wordsA[prefix:len(wordsA)-suffix], wordsB[prefix:len(wordsB)-suffix]
+	edits, ok := myers(keysA[prefix:len(keysA)-suffix], keysB[prefix:len(keysB)-suffix])
+	if !ok {
+		edits = nil
+		for range midA {
+			edits = append(edits, Removed)
+		}
+		for range midB {
+			edits = append(edits, Added)
+		}
+	}
+	ops = append(ops, group(edits, midA, midB)...)
+	if suffix > 0 {
+		ops = append(ops, Op{Kind: Equal, A: wordsA[len(wordsA)-suffix:]})
+	}
+	return ops
+}
+
+// normalize lowercases words and strips the punctuation around them
+func normalize(words []string) []string {
+	keys := make([]string, len(words))
+	for i, word := range words {
+		keys[i] = strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSymbol(r)
+		}))
+	}
+	return keys
+}
+
+// myers finds a shortest edit script turning a into b with Myers' O(ND) algorithm, one
+// kind per word in order. It reports false when more than maxEdits edits are needed.
+func myers(a, b []string) ([]Kind, bool) {
+	n, m := len(a), len(b)
+	// trace[d][k+d] is the furthest x reached on diagonal k = x - y with d edits
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEdits {
+			return nil, false
+		}
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || (k != d && trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]):
+				x = trace[d-1][k+1+d-1] // a word of b inserted
+			default:
+				x = trace[d-1][k-1+d-1] + 1 // a word of a removed
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, v)
+				return backtrack(trace, n, m), true
+			}
+		}
+		trace = append(trace, v)
+	}
+	return nil, true
+}
+
+// backtrack walks the Myers trace back from the end and returns the edit kinds in order
+func backtrack(trace [][]int, n, m int) []Kind {
+	var reversed []Kind
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		k := x - y
+		prev := trace[d-1]
+		var prevK int
+		inserted := k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1])
+		if inserted {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Equal)
+			x--
+			y--
+		}
+		if inserted {
+			reversed = append(reversed, Added)
+		} else {
+			reversed = append(reversed, Removed)
+		}
+		x, y = prevX, prevY
+	}
+	for ; x > 0; x-- {
+		reversed = append(reversed, Equal)
+	}
+
+	kinds := make([]Kind, len(reversed))
+	for i, kind := range reversed {
+		kinds[len(reversed)-1-i] = kind
+	}
+	return kinds
+}
+
+// group turns per-word edit kinds into stretches, merging removals followed by
+// additions into changes
+func group(edits []Kind, a, b []string) []Op {
+	var ops []Op
+	x, y := 0, 0
+	for i := 0; i < len(edits); {
+		if edits[i] == Equal {
+			start := x
+			for ; i < len(edits) && edits[i] == Equal; i++ {
+				x++
+				y++
+			}
+			ops = append(ops, Op{Kind: Equal, A: a[start:x]})
+			continue
+		}
+
+		startX, startY := x, y
+		for ; i < len(edits) && edits[i] != Equal; i++ {
+			if edits[i] == Removed {
+				x++
+			} else {
+				y++
+			}
+		}
+		op := Op{A: a[startX:x], B: b[startY:y]}
+		switch {
+		case len(op.A) > 0 && len(op.B) > 0:
+			op.Kind = Changed
+		case len(op.A) > 0:
+			op.Kind = Removed
+		default:
+			op.Kind = Added
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
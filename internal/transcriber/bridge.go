@@ -0,0 +1,202 @@
+package transcriber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"transription-service/internal/bridge"
+)
+
+func init() {
+	Register(EnginePythonWhisper, func(settings Settings) (Engine, error) {
+		if settings.BridgeScript == "" {
+			return nil, fmt.Errorf("the %s engine needs the bridge script", EnginePythonWhisper)
+		}
+		return &Bridge{Script: settings.BridgeScript, Workers: settings.BridgeWorkers}, nil
+	})
+}
+
+// Bridge runs whisper_bridge.py, which transcribes with openai-whisper, on persistent workers
+// when there are any and as a process per file otherwise
+type Bridge struct {
+	Script  string
+	Workers *bridge.Pool
+}
+
+// Transcribe runs the bridge on the request's file and reads the JSON it writes next to it
+func (b *Bridge) Transcribe(ctx context.Context, req Request) (Result, error) {
+	outputPath := req.AudioPath + ".json"
+	args := b.args(req, outputPath)
+
+	var output []byte
+	var err error
+	if b.Workers != nil {
+		output, err = b.runWorker(ctx, args, req.Progress)
+	} else {
+		output, err = b.runProcess(ctx, args, req.Progress)
+	}
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+	if err != nil {
+		if _, statErr := os.Stat(outputPath); statErr != nil {
+			return Result{}, &ProcessError{Err: err, Output: string(output)}
+		}
+		log.Printf("Bridge failed with %v but left output behind, trying to use it. Output: %s", err, output)
+	}
+	exitErr := err
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read transcription results: %w", err)
+	}
+
+	// A BOM or stray non-UTF-8 bytes from the bridge are tolerated
+	data = []byte(DecodeOutput(data))
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Result{}, &OutputError{Err: err, Data: data}
+	}
+	result.Output = data
+	// A bridge that exited with an error failed even when its output parses
+	if exitErr != nil && result.Error == "" {
+		result.Error = fmt.Sprintf("bridge exited with %v", exitErr)
+	}
+	return result, nil
+}
+
+// runProcess runs the bridge as a process of its own, returning what it printed
+func (b *Bridge) runProcess(ctx context.Context, args []string, progress func(ProgressEvent)) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "python3", append([]string{b.Script}, args...)...)
+	if progress != nil {
+		return runWithProgress(cmd, progress)
+	}
+	return cmd.CombinedOutput()
+}
+
+// runWorker hands the command line to a persistent worker, whose reply carries the log a
+// process of its own would have printed
+func (b *Bridge) runWorker(ctx context.Context, args []string, progress func(ProgressEvent)) ([]byte, error) {
+	var onProgress func([]byte)
+	if progress != nil {
+		onProgress = func(payload []byte) {
+			var event ProgressEvent
+			if json.Unmarshal(payload, &event) == nil {
+				progress(event)
+			}
+		}
+	}
+	return b.Workers.Output(ctx, args, onProgress)
+}
+
+// args builds the bridge's arguments for a request
+func (b *Bridge) args(req Request, outputPath string) []string {
+	args := []string{
+		"--input", req.AudioPath,
+		"--output", outputPath,
+		"--model", req.Model,
+	}
+
+	// Punctuation restoration is opt-in since it needs an extra model
+	if req.Punctuate {
+		args = append(args, "--punctuate")
+	}
+
+	if req.Diarize {
+		args = append(args, "--diarize")
+	}
+
+	// Language identification per segment for code-switched audio
+	if req.SegmentLanguage {
+		args = append(args, "--segment-language")
+	}
+
+	// Restrict language detection to the candidates the client listed
+	if len(req.Languages) > 0 {
+		args = append(args, "--languages", strings.Join(req.Languages, ","))
+	}
+
+	// Lightweight speaker count estimate without full diarization
+	if req.EstimateSpeakers {
+		args = append(args, "--estimate-speakers")
+	}
+
+	// Extra candidate texts per segment for reviewers to choose from
+	if req.NBest > 1 {
+		args = append(args, "--n-best", strconv.Itoa(req.NBest))
+	}
+
+	// Whisper's own translation to English instead of a transcript in the spoken language
+	if req.Task != "" {
+		args = append(args, "--task", req.Task)
+	}
+
+	// Chinese output in the script the client reads
+	if req.Script != "" {
+		args = append(args, "--script", req.Script)
+	}
+
+	// Progress lines are only printed, and only split off, when someone is listening
+	if req.Progress != nil {
+		args = append(args, "--progress")
+	}
+	return args
+}
+
+// progressPrefix marks the bridge's stdout lines that carry progress events
+const progressPrefix = "PROGRESS "
+
+// lockedBuffer collects the output of both pipes of a process
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// runWithProgress runs the bridge like CombinedOutput, except that progress lines on stdout
+// are passed to report instead of being collected
+func runWithProgress(cmd *exec.Cmd, report func(ProgressEvent)) ([]byte, error) {
+	var output lockedBuffer
+	cmd.Stderr = &output
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if payload, ok := strings.CutPrefix(line, progressPrefix); ok {
+			var event ProgressEvent
+			if json.Unmarshal([]byte(payload), &event) == nil {
+				report(event)
+				continue
+			}
+		}
+		output.Write([]byte(line + "\n"))
+	}
+	// Keep draining after an oversized line so the bridge never blocks on a full pipe
+	_, _ = io.Copy(&output, stdout)
+
+	err = cmd.Wait()
+	return output.buf.Bytes(), err
+}
@@ -0,0 +1,44 @@
+package transcriber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Byte order marks that may start a backend output file
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// DecodeOutput turns a backend output file into UTF-8 text. A leading UTF-8 BOM is
+// stripped, UTF-16 with a BOM is converted, and any invalid bytes that remain are
+// replaced with U+FFFD so they can't break parsing of the surrounding text.
+func DecodeOutput(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		data = data[len(utf8BOM):]
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return decodeUTF16(data[len(utf16LEBOM):], binary.LittleEndian)
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return decodeUTF16(data[len(utf16BEBOM):], binary.BigEndian)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// decodeUTF16 converts UTF-16 bytes in the given byte order to a UTF-8 string. An odd
+// trailing byte, half a code unit, becomes U+FFFD rather than being dropped.
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	text := string(utf16.Decode(units))
+	if len(data)%2 != 0 {
+		text += "\uFFFD"
+	}
+	return text
+}
@@ -0,0 +1,30 @@
+package transcriber
+
+import "testing"
+
+func TestDecodeOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"plain UTF-8", []byte("héllo"), "héllo"},
+		{"UTF-8 BOM", append([]byte{0xEF, 0xBB, 0xBF}, "héllo"...), "héllo"},
+		{"invalid UTF-8", []byte("a\xffb"), "a�b"},
+		{"UTF-16 LE", []byte{0xFF, 0xFE, 'h', 0, 0xE9, 0, 'y', 0}, "héy"},
+		{"UTF-16 BE", []byte{0xFE, 0xFF, 0, 'h', 0, 0xE9, 0, 'y'}, "héy"},
+		{"UTF-16 LE surrogate pair", []byte{0xFF, 0xFE, 0x3D, 0xD8, 0x00, 0xDE}, "😀"},
+		{"UTF-16 BE surrogate pair", []byte{0xFE, 0xFF, 0xD8, 0x3D, 0xDE, 0x00}, "😀"},
+		{"UTF-16 LE odd trailing byte", []byte{0xFF, 0xFE, 'o', 0, 'k', 0, 0x21}, "ok�"},
+		{"UTF-16 BE odd trailing byte", []byte{0xFE, 0xFF, 0, 'o', 0, 'k', 0}, "ok�"},
+		{"UTF-16 BOM only", []byte{0xFF, 0xFE}, ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeOutput(tt.data); got != tt.want {
+				t.Fatalf("DecodeOutput(% x) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
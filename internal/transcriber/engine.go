@@ -0,0 +1,140 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"transription-service/internal/bridge"
+)
+
+// Engine names accepted by New
+const (
+	EnginePythonWhisper = "python-whisper"
+	EngineWhisperCPP    = "whisper.cpp"
+)
+
+// Engine turns an audio file into timed segments
+type Engine interface {
+	Transcribe(ctx context.Context, req Request) (Result, error)
+}
+
+// Request is one transcription for an engine. Engines ignore the options they can't honour
+// and say so in the result's warnings.
+type Request struct {
+	AudioPath        string
+	Model            string
+	Task             string   // translate to output English, empty to transcribe
+	Languages        []string // candidates to choose between, a single one forces it
+	Punctuate        bool
+	Diarize          bool
+	SegmentLanguage  bool
+	EstimateSpeakers bool
+	NBest            int
+	Script           string
+	Progress         func(ProgressEvent) // nil when nobody listens
+}
+
+// Result is an engine's transcription
+type Result struct {
+	Segments          []TranscriptionSegment `json:"segments"`
+	Language          string                 `json:"language,omitempty"`
+	Warnings          []string               `json:"warnings,omitempty"`
+	EstimatedSpeakers *int                   `json:"estimated_speakers,omitempty"`
+	Error             string                 `json:"error,omitempty"` // reported along with whatever segments were produced
+
+	// Output is the engine's output as written, for salvaging the text when Segments is empty
+	Output []byte `json:"-"`
+}
+
+// ProcessError is an engine process that failed without leaving any output behind
+type ProcessError struct {
+	Err    error
+	Output string // what the process printed
+}
+
+func (e *ProcessError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// OutputError is engine output that could not be parsed. Data is the decoded output, kept
+// so callers can salvage what it holds.
+type OutputError struct {
+	Err  error
+	Data []byte
+}
+
+func (e *OutputError) Error() string {
+	return "failed to parse transcription output: " + e.Err.Error()
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// Settings configures the engines; each reads only the fields it needs
+type Settings struct {
+	BridgeScript     string // python-whisper: path of whisper_bridge.py
+	WhisperCPPPath   string // whisper.cpp: the whisper-cli binary
+	WhisperCPPModels string // whisper.cpp: directory holding ggml-<model>.bin files
+	OpenAIAPIKey     string // openai
+	OpenAIBaseURL    string // openai: API root, for compatible services and proxies
+	OpenAIModel      string // openai: hosted model, whisper-1 by default
+
+	// python-whisper: persistent bridge workers; without them each file starts a process
+	BridgeWorkers *bridge.Pool
+}
+
+// IsRemote reports whether an engine runs elsewhere, needing no local transcription slot
+func IsRemote(engine Engine) bool {
+	remote, ok := engine.(interface{ Remote() bool })
+	return ok && remote.Remote()
+}
+
+// Factory creates an engine from the settings, failing when they are incomplete
+type Factory func(Settings) (Engine, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes an engine available to New under a name. Engines register themselves from
+// init, so ones behind build tags only exist in binaries built with them.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("transcriber: engine " + name + " registered twice")
+	}
+	registry[name] = factory
+}
+
+// Engines lists the registered engine names
+func Engines() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// New creates the named engine
+func New(name string, settings Settings) (Engine, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown transcription engine %q (expected %s)", name, strings.Join(Engines(), ", "))
+	}
+	return factory(settings)
+}
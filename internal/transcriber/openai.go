@@ -0,0 +1,175 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// EngineOpenAI names the engine that calls OpenAI's hosted transcription API
+const EngineOpenAI = "openai"
+
+// openAIMaxBytes is the largest file OpenAI's transcription API accepts
+const openAIMaxBytes = 25 << 20
+
+// openAIFormats are the file types OpenAI's transcription API reads
+var openAIFormats = []string{".flac", ".m4a", ".mp3", ".mp4", ".mpeg", ".mpga", ".oga", ".ogg", ".wav", ".webm"}
+
+func init() {
+	Register(EngineOpenAI, func(settings Settings) (Engine, error) {
+		if settings.OpenAIAPIKey == "" {
+			return nil, fmt.Errorf("the %s engine needs an API key", EngineOpenAI)
+		}
+		baseURL := settings.OpenAIBaseURL
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		model := settings.OpenAIModel
+		if model == "" {
+			model = "whisper-1"
+		}
+		return &OpenAI{APIKey: settings.OpenAIAPIKey, BaseURL: strings.TrimRight(baseURL, "/"), Model: model, Client: http.DefaultClient}, nil
+	})
+}
+
+// OpenAI forwards audio to OpenAI's hosted Whisper, which runs off this machine
+type OpenAI struct {
+	APIKey  string
+	BaseURL string
+	Model   string // the hosted model, whatever local model the request named
+	Client  *http.Client
+}
+
+// Remote reports that transcriptions don't use local compute
+func (o *OpenAI) Remote() bool {
+	return true
+}
+
+// openAIResponse is the verbose_json form of a transcription
+type openAIResponse struct {
+	Language string `json:"language"` // a name such as "english", not a code
+	Text     string `json:"text"`
+	Segments []struct {
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+		Text       string  `json:"text"`
+		AvgLogprob float64 `json:"avg_logprob"`
+	} `json:"segments"`
+}
+
+// Transcribe uploads the file to the transcriptions endpoint, or to translations when the
+// request asks for English output
+func (o *OpenAI) Transcribe(ctx context.Context, req Request) (Result, error) {
+	if ext := strings.ToLower(filepath.Ext(req.AudioPath)); !slices.Contains(openAIFormats, ext) {
+		return Result{}, fmt.Errorf("the %s engine can't send %s files (expected one of %s)", EngineOpenAI, ext, strings.Join(openAIFormats, ", "))
+	}
+	file, err := os.Open(req.AudioPath)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() > openAIMaxBytes {
+		return Result{}, fmt.Errorf("the %s engine accepts files up to %dMB", EngineOpenAI, openAIMaxBytes>>20)
+	}
+
+	endpoint := "/audio/transcriptions"
+	if req.Task == "translate" {
+		endpoint = "/audio/translations"
+	}
+
+	// The form is built in memory so it goes out with a length; files are at most 25MB
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := o.writeForm(form, file, req); err != nil {
+		return Result{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.BaseURL+endpoint, &body)
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := o.Client.Do(httpReq)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s request failed: %w", EngineOpenAI, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return Result{}, fmt.Errorf("%s response could not be read: %w", EngineOpenAI, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		return Result{}, &ProcessError{Err: fmt.Errorf("%s returned %s", EngineOpenAI, resp.Status), Output: message}
+	}
+
+	var parsed openAIResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return Result{}, &OutputError{Err: err, Data: data}
+	}
+
+	result := Result{Language: parsed.Language, Output: data, Segments: []TranscriptionSegment{}}
+	for _, segment := range parsed.Segments {
+		// The average token log probability maps to a rough 0-1 confidence, as in the bridge
+		confidence := math.Round(math.Exp(segment.AvgLogprob)*10000) / 10000
+		result.Segments = append(result.Segments, TranscriptionSegment{
+			Text:       segment.Text,
+			StartTime:  segment.Start,
+			EndTime:    segment.End,
+			Confidence: &confidence,
+		})
+	}
+	result.Warnings = unsupportedOptions(req, EngineOpenAI)
+	return result, nil
+}
+
+// writeForm writes the multipart request: the file and the options the API understands
+func (o *OpenAI) writeForm(form *multipart.Writer, file *os.File, req Request) error {
+	fields := map[string]string{
+		"model":           o.Model,
+		"response_format": "verbose_json",
+	}
+	// Translations are always English, so only transcriptions take a language
+	if len(req.Languages) == 1 && req.Task != "translate" {
+		fields["language"] = req.Languages[0]
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	if req.Task != "translate" {
+		if err := form.WriteField("timestamp_granularities[]", "segment"); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile("file", filepath.Base(req.AudioPath))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	return form.Close()
+}
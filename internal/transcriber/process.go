@@ -0,0 +1,63 @@
+package transcriber
+
+import "strings"
+
+// SegmentProcessor is one post-processing step over transcribed segments. Processors return
+// a new slice and leave their input untouched, so they can be chained in any order.
+type SegmentProcessor interface {
+	Name() string
+	Process(segments []TranscriptionSegment) []TranscriptionSegment
+}
+
+// ProcessorFunc turns a plain function into a named SegmentProcessor
+type ProcessorFunc struct {
+	ProcessorName string
+	Fn            func([]TranscriptionSegment) []TranscriptionSegment
+}
+
+// Name returns the processor name
+func (p ProcessorFunc) Name() string {
+	return p.ProcessorName
+}
+
+// Process runs the function
+func (p ProcessorFunc) Process(segments []TranscriptionSegment) []TranscriptionSegment {
+	return p.Fn(segments)
+}
+
+// Chain runs its processors in order, each on the output of the previous one
+type Chain []SegmentProcessor
+
+// Process runs the segments through every processor of the chain
+func (c Chain) Process(segments []TranscriptionSegment) []TranscriptionSegment {
+	for _, processor := range c {
+		segments = processor.Process(segments)
+	}
+	return segments
+}
+
+// Trim returns a processor that strips surrounding whitespace from segment texts and drops
+// segments left empty
+func Trim() SegmentProcessor {
+	return ProcessorFunc{ProcessorName: "trim", Fn: func(segments []TranscriptionSegment) []TranscriptionSegment {
+		trimmed := make([]TranscriptionSegment, 0, len(segments))
+		for _, segment := range segments {
+			if segment.Text = strings.TrimSpace(segment.Text); segment.Text != "" {
+				trimmed = append(trimmed, segment)
+			}
+		}
+		return trimmed
+	}}
+}
+
+// Sentences returns a processor that re-splits segments at sentence boundaries
+func Sentences() SegmentProcessor {
+	return ProcessorFunc{ProcessorName: "sentences", Fn: SplitSentences}
+}
+
+// SplitLong returns a processor that splits segments longer than maxDuration seconds
+func SplitLong(maxDuration float64) SegmentProcessor {
+	return ProcessorFunc{ProcessorName: "split", Fn: func(segments []TranscriptionSegment) []TranscriptionSegment {
+		return SplitLongSegments(segments, maxDuration)
+	}}
+}
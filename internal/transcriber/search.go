@@ -0,0 +1,65 @@
+package transcriber
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// SegmentFilter keeps the segments whose text matches a search query, ignoring case
+type SegmentFilter struct {
+	pattern *regexp.Regexp
+}
+
+// NewSegmentFilter creates a filter for the query, read as a regular expression when
+// useRegex is set and as a plain substring otherwise
+func NewSegmentFilter(query string, useRegex bool) (*SegmentFilter, error) {
+	if !useRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	pattern, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+	return &SegmentFilter{pattern: pattern}, nil
+}
+
+// MarshalJSON writes the filter as its pattern, so options with a filter can be queued
+func (f *SegmentFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.pattern.String())
+}
+
+// UnmarshalJSON reads a filter written by MarshalJSON
+func (f *SegmentFilter) UnmarshalJSON(data []byte) error {
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err != nil {
+		return err
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid search pattern: %w", err)
+	}
+	f.pattern = compiled
+	return nil
+}
+
+// Name identifies the filter in a processing chain
+func (f *SegmentFilter) Name() string {
+	return "search"
+}
+
+// Process keeps the matching segments, so a filter can run as a SegmentProcessor
+func (f *SegmentFilter) Process(segments []TranscriptionSegment) []TranscriptionSegment {
+	return f.Filter(segments)
+}
+
+// Filter returns the matching segments in their original order
+func (f *SegmentFilter) Filter(segments []TranscriptionSegment) []TranscriptionSegment {
+	matches := make([]TranscriptionSegment, 0)
+	for _, segment := range segments {
+		if f.pattern.MatchString(segment.Text) {
+			matches = append(matches, segment)
+		}
+	}
+	return matches
+}
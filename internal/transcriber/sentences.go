@@ -0,0 +1,91 @@
+package transcriber
+
+import (
+	"strings"
+)
+
+// timedWord is a word with its approximate position on the timeline
+type timedWord struct {
+	text        string
+	start       float64
+	end         float64
+	speaker     string
+	language    string
+	sentenceEnd bool
+}
+
+// SplitSentences re-splits segments at sentence boundaries. Whisper's segments break at
+// arbitrary points, so the text is joined and cut after '.', '?' and '!'. Each word gets a
+// time proportional to its character position within its original segment, and each
+// sentence spans its first to its last word.
+func SplitSentences(segments []TranscriptionSegment) []TranscriptionSegment {
+	var words []timedWord
+	for _, segment := range segments {
+		words = append(words, timeWords(segment)...)
+	}
+
+	var sentences []TranscriptionSegment
+	var current []timedWord
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		texts := make([]string, len(current))
+		for i, word := range current {
+			texts[i] = word.text
+		}
+		sentences = append(sentences, TranscriptionSegment{
+			Text:      strings.Join(texts, " "),
+			StartTime: current[0].start,
+			EndTime:   current[len(current)-1].end,
+			Speaker:   current[0].speaker,
+			Language:  current[0].language,
+		})
+		current = nil
+	}
+
+	for _, word := range words {
+		current = append(current, word)
+		if word.sentenceEnd {
+			flush()
+		}
+	}
+	flush()
+
+	return sentences
+}
+
+// timeWords spreads a segment's duration over its words by character count
+func timeWords(segment TranscriptionSegment) []timedWord {
+	fields := strings.Fields(segment.Text)
+	totalChars := 0
+	for _, field := range fields {
+		totalChars += len([]rune(field))
+	}
+	if totalChars == 0 {
+		return nil
+	}
+
+	duration := segment.EndTime - segment.StartTime
+	words := make([]timedWord, 0, len(fields))
+	position := 0
+	for _, field := range fields {
+		length := len([]rune(field))
+		words = append(words, timedWord{
+			text:        field,
+			start:       segment.StartTime + duration*float64(position)/float64(totalChars),
+			end:         segment.StartTime + duration*float64(position+length)/float64(totalChars),
+			speaker:     segment.Speaker,
+			language:    segment.Language,
+			sentenceEnd: isSentenceEnd(field),
+		})
+		position += length
+	}
+	return words
+}
+
+// isSentenceEnd reports whether a word closes a sentence, allowing trailing quotes or brackets
+func isSentenceEnd(word string) bool {
+	word = strings.TrimRight(word, `"')]»”’`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "…")
+}
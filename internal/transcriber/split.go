@@ -0,0 +1,46 @@
+package transcriber
+
+import "strings"
+
+// SplitLongSegments breaks segments longer than maxDuration seconds into consecutive pieces
+// at word boundaries. Word times are estimated the same way as in SplitSentences, and each
+// piece keeps the other fields of its segment. A single word longer than the limit stays
+// whole. A maxDuration of zero or less leaves the segments unchanged.
+func SplitLongSegments(segments []TranscriptionSegment, maxDuration float64) []TranscriptionSegment {
+	if maxDuration <= 0 {
+		return segments
+	}
+
+	split := make([]TranscriptionSegment, 0, len(segments))
+	for _, segment := range segments {
+		words := timeWords(segment)
+		if segment.EndTime-segment.StartTime <= maxDuration || len(words) < 2 {
+			split = append(split, segment)
+			continue
+		}
+
+		var current []timedWord
+		flush := func() {
+			texts := make([]string, len(current))
+			for i, word := range current {
+				texts[i] = word.text
+			}
+			piece := segment
+			piece.Text = strings.Join(texts, " ")
+			piece.Alternatives = nil // they cover the whole segment, not the piece
+			piece.StartTime = current[0].start
+			piece.EndTime = current[len(current)-1].end
+			split = append(split, piece)
+			current = nil
+		}
+
+		for _, word := range words {
+			if len(current) > 0 && word.end-current[0].start > maxDuration {
+				flush()
+			}
+			current = append(current, word)
+		}
+		flush()
+	}
+	return split
+}
@@ -0,0 +1,93 @@
+package transcriber
+
+// StreamWindow is a stretch of live audio ready to be transcribed. Interim windows cover
+// the audio of the current chunk received so far and are superseded by later ones; the
+// final window of a chunk is transcribed once and its segments are settled.
+type StreamWindow struct {
+	Chunk  int
+	Offset float64 // start of the chunk in the stream, in seconds
+	PCM    []byte  // 16-bit little-endian samples
+	Final  bool
+}
+
+// Stream cuts live 16-bit PCM into windows for transcription: a final window every
+// chunk seconds and, in between, interim windows every interim seconds of new audio
+// so clients see text before the chunk is complete.
+type Stream struct {
+	frameBytes     int // bytes per sample frame across all channels
+	bytesPerSecond int
+	chunkBytes     int
+	interimBytes   int // zero disables interim windows
+
+	buffer      []byte
+	chunk       int
+	lastInterim int // buffer length at the last interim window
+}
+
+// NewStream creates a stream for PCM with the given format. An interim interval of zero
+// or less only produces final windows.
+func NewStream(sampleRate, channels int, chunkSeconds, interimSeconds float64) *Stream {
+	frameBytes := channels * 2
+	bytesPerSecond := sampleRate * frameBytes
+	alignedBytes := func(seconds float64) int {
+		n := int(seconds * float64(bytesPerSecond))
+		return n - n%frameBytes
+	}
+
+	s := &Stream{
+		frameBytes:     frameBytes,
+		bytesPerSecond: bytesPerSecond,
+		chunkBytes:     max(alignedBytes(chunkSeconds), frameBytes),
+	}
+	if interimSeconds > 0 && interimSeconds < chunkSeconds {
+		s.interimBytes = max(alignedBytes(interimSeconds), frameBytes)
+	}
+	return s
+}
+
+// Write adds audio and returns the windows that became ready, in order
+func (s *Stream) Write(pcm []byte) []StreamWindow {
+	var windows []StreamWindow
+	for len(pcm) > 0 {
+		n := min(len(pcm), s.chunkBytes-len(s.buffer))
+		s.buffer = append(s.buffer, pcm[:n]...)
+		pcm = pcm[n:]
+
+		if len(s.buffer) == s.chunkBytes {
+			windows = append(windows, s.cut(true))
+		}
+	}
+
+	if s.interimBytes > 0 && len(s.buffer)-s.lastInterim >= s.interimBytes {
+		windows = append(windows, s.cut(false))
+	}
+	return windows
+}
+
+// Flush returns the rest of the audio as a final window, or nothing when no whole sample
+// frame is left
+func (s *Stream) Flush() []StreamWindow {
+	s.buffer = s.buffer[:len(s.buffer)-len(s.buffer)%s.frameBytes]
+	if len(s.buffer) == 0 {
+		return nil
+	}
+	return []StreamWindow{s.cut(true)}
+}
+
+// cut snapshots the current chunk as a window, starting the next chunk after a final one
+func (s *Stream) cut(final bool) StreamWindow {
+	window := StreamWindow{
+		Chunk:  s.chunk,
+		Offset: float64(s.chunk*s.chunkBytes) / float64(s.bytesPerSecond),
+		PCM:    append([]byte(nil), s.buffer...),
+		Final:  final,
+	}
+	if final {
+		s.buffer = s.buffer[:0]
+		s.chunk++
+		s.lastInterim = 0
+	} else {
+		s.lastInterim = len(s.buffer)
+	}
+	return window
+}
@@ -0,0 +1,151 @@
+//go:build whisper_cgo
+
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+
+	"transription-service/internal/audio"
+)
+
+// EngineWhisperCPPNative is whisper.cpp linked into the service, in binaries built with
+// -tags whisper_cgo against libwhisper
+const EngineWhisperCPPNative = "whisper.cpp-native"
+
+func init() {
+	Register(EngineWhisperCPPNative, func(settings Settings) (Engine, error) {
+		return &NativeWhisperCPP{ModelDir: settings.WhisperCPPModels}, nil
+	})
+}
+
+// NativeWhisperCPP transcribes in-process with the whisper.cpp library, without Python or a
+// process per file
+type NativeWhisperCPP struct {
+	ModelDir string // holds ggml-<model>.bin for each model name
+}
+
+// nativeModel is a loaded ggml model. whisper.cpp keeps one decoding state per model, so a
+// model transcribes one file at a time.
+type nativeModel struct {
+	mu    sync.Mutex
+	model whisper.Model
+}
+
+// nativeModels are loaded on first use and kept for the life of the process
+var (
+	nativeModelsMu sync.Mutex
+	nativeModels   = map[string]*nativeModel{}
+)
+
+// loadNativeModel returns the loaded model of a ggml file, loading it when needed
+func loadNativeModel(path string) (*nativeModel, error) {
+	nativeModelsMu.Lock()
+	defer nativeModelsMu.Unlock()
+	if loaded, ok := nativeModels[path]; ok {
+		return loaded, nil
+	}
+	model, err := whisper.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whisper.cpp model %s: %w", path, err)
+	}
+	loaded := &nativeModel{model: model}
+	nativeModels[path] = loaded
+	return loaded, nil
+}
+
+// Transcribe decodes the file to samples with ffmpeg and runs whisper.cpp on them
+func (n *NativeWhisperCPP) Transcribe(ctx context.Context, req Request) (Result, error) {
+	samples, err := audio.Samples(ctx, req.AudioPath)
+	if err != nil {
+		return Result{}, err
+	}
+	loaded, err := loadNativeModel(ggmlModelPath(n.ModelDir, req.Model))
+	if err != nil {
+		return Result{}, err
+	}
+
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+	if req.Progress != nil {
+		req.Progress(ProgressEvent{Stage: "model_loaded"})
+	}
+
+	wctx, err := loaded.model.NewContext()
+	if err != nil {
+		return Result{}, err
+	}
+	// Only a single language can be forced; candidates fall back to detection
+	language := "auto"
+	if len(req.Languages) == 1 {
+		language = req.Languages[0]
+	}
+	if err := wctx.SetLanguage(language); err != nil && !errors.Is(err, whisper.ErrModelNotMultilingual) {
+		return Result{}, fmt.Errorf("whisper.cpp can't transcribe language %q: %w", language, err)
+	}
+	wctx.SetTranslate(req.Task == "translate")
+
+	var onProgress whisper.ProgressCallback
+	if req.Progress != nil {
+		req.Progress(ProgressEvent{Stage: "decoding"})
+		onProgress = func(percent int) {
+			req.Progress(ProgressEvent{Fraction: float64(percent) / 100})
+		}
+	}
+	// whisper.cpp checks back before each encoder pass, which is where a timeout stops it
+	keepGoing := func() bool { return ctx.Err() == nil }
+	if err := wctx.Process(samples, keepGoing, nil, onProgress); err != nil {
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		return Result{}, fmt.Errorf("whisper.cpp failed: %w", err)
+	}
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+
+	result := Result{Language: wctx.DetectedLanguage(), Segments: []TranscriptionSegment{}}
+	for {
+		segment, err := wctx.NextSegment()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		entry := TranscriptionSegment{
+			Text:      segment.Text,
+			StartTime: segment.Start.Seconds(),
+			EndTime:   segment.End.Seconds(),
+		}
+		if confidence, ok := nativeConfidence(wctx, segment.Tokens); ok {
+			entry.Confidence = &confidence
+		}
+		result.Segments = append(result.Segments, entry)
+	}
+	result.Warnings = unsupportedOptions(req, EngineWhisperCPPNative)
+	return result, nil
+}
+
+// nativeConfidence maps the average log probability of a segment's text tokens to 0-1, like
+// the bridge does with whisper's avg_logprob
+func nativeConfidence(wctx whisper.Context, tokens []whisper.Token) (float64, bool) {
+	var sum float64
+	var count int
+	for _, token := range tokens {
+		if wctx.IsText(token) && token.P > 0 {
+			sum += math.Log(float64(token.P))
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return math.Round(math.Exp(sum/float64(count))*10000) / 10000, true
+}
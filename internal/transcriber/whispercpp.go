@@ -0,0 +1,131 @@
+package transcriber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"transription-service/internal/audio"
+)
+
+func init() {
+	Register(EngineWhisperCPP, func(settings Settings) (Engine, error) {
+		if settings.WhisperCPPPath == "" {
+			return nil, fmt.Errorf("the %s engine needs the whisper-cli binary", EngineWhisperCPP)
+		}
+		return &WhisperCPP{Binary: settings.WhisperCPPPath, ModelDir: settings.WhisperCPPModels}, nil
+	})
+}
+
+// WhisperCPP transcribes with the whisper.cpp command line tool and its ggml models
+type WhisperCPP struct {
+	Binary   string
+	ModelDir string // holds ggml-<model>.bin for each model name
+}
+
+// whisperCPPOutput is the part of whisper.cpp's JSON output that is read
+type whisperCPPOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // milliseconds
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// Transcribe converts the file to the 16kHz WAV whisper.cpp reads and runs it with JSON output
+func (w *WhisperCPP) Transcribe(ctx context.Context, req Request) (Result, error) {
+	wavPath := req.AudioPath + ".whispercpp.wav"
+	if err := audio.ExtractAudio(ctx, req.AudioPath, wavPath, -1); err != nil {
+		return Result{}, err
+	}
+	defer os.Remove(wavPath)
+
+	outputBase := req.AudioPath + ".whispercpp"
+	args := []string{
+		"--model", ggmlModelPath(w.ModelDir, req.Model),
+		"--file", wavPath,
+		"--output-json",
+		"--output-file", outputBase,
+		"--no-prints",
+	}
+	// Only a single language can be forced; candidates fall back to detection
+	language := "auto"
+	if len(req.Languages) == 1 {
+		language = req.Languages[0]
+	}
+	args = append(args, "--language", language)
+	if req.Task == "translate" {
+		args = append(args, "--translate")
+	}
+
+	output, err := exec.CommandContext(ctx, w.Binary, args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+	if err != nil {
+		return Result{}, &ProcessError{Err: err, Output: string(output)}
+	}
+
+	data, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read transcription results: %w", err)
+	}
+	defer os.Remove(outputBase + ".json")
+
+	data = []byte(DecodeOutput(data))
+	var parsed whisperCPPOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return Result{}, &OutputError{Err: err, Data: data}
+	}
+
+	result := Result{Language: parsed.Result.Language, Output: data, Segments: []TranscriptionSegment{}}
+	for _, entry := range parsed.Transcription {
+		result.Segments = append(result.Segments, TranscriptionSegment{
+			Text:      entry.Text,
+			StartTime: float64(entry.Offsets.From) / 1000,
+			EndTime:   float64(entry.Offsets.To) / 1000,
+		})
+	}
+	result.Warnings = unsupportedOptions(req, EngineWhisperCPP)
+	return result, nil
+}
+
+// ggmlModelPath finds the ggml file of a model name in dir; paths to model files are used
+// as they are
+func ggmlModelPath(dir, model string) string {
+	if strings.ContainsRune(model, os.PathSeparator) || strings.HasSuffix(model, ".bin") {
+		return model
+	}
+	return filepath.Join(dir, "ggml-"+model+".bin")
+}
+
+// unsupportedOptions warns about the requested options that only the Python bridge provides
+func unsupportedOptions(req Request, engine string) []string {
+	var warnings []string
+	for _, option := range []struct {
+		name      string
+		requested bool
+	}{
+		{"punctuation restoration", req.Punctuate},
+		{"diarization", req.Diarize},
+		{"per-segment language detection", req.SegmentLanguage},
+		{"speaker count estimation", req.EstimateSpeakers},
+		{"alternative transcriptions", req.NBest > 1},
+		{"script conversion", req.Script != ""},
+		{"choosing between candidate languages", len(req.Languages) > 1},
+	} {
+		if option.requested {
+			warnings = append(warnings, fmt.Sprintf("The %s engine does not support %s; it was skipped", engine, option.name))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,138 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// Translator turns a batch of texts from the source language into the target language.
+// The source may be empty when it is unknown.
+type Translator interface {
+	Translate(ctx context.Context, texts []string, source, target string) ([]string, error)
+}
+
+// Backend names accepted by New
+const (
+	BackendNone           = ""
+	BackendLibreTranslate = "libretranslate"
+	BackendCommand        = "command"
+)
+
+// New creates the translator for the named backend, or returns nil when translation is disabled
+func New(backend, url, apiKey, command string) (Translator, error) {
+	switch backend {
+	case BackendNone:
+		return nil, nil
+	case BackendLibreTranslate:
+		if url == "" {
+			return nil, fmt.Errorf("the libretranslate backend needs a URL")
+		}
+		return &LibreTranslate{URL: strings.TrimRight(url, "/"), APIKey: apiKey, Client: http.DefaultClient}, nil
+	case BackendCommand:
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("the command backend needs a command")
+		}
+		return &Command{Args: args}, nil
+	}
+	return nil, fmt.Errorf("unknown translation backend %q (expected libretranslate or command)", backend)
+}
+
+// LibreTranslate calls a LibreTranslate-compatible HTTP API
+type LibreTranslate struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// Translate sends all texts in a single /translate request
+func (t *LibreTranslate) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	if source == "" {
+		source = "auto"
+	}
+	payload, err := json.Marshal(map[string]any{
+		"q":       texts,
+		"source":  source,
+		"target":  target,
+		"format":  "text",
+		"api_key": t.APIKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL+"/translate", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("translation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("translation service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+	return checkCount(result.TranslatedText, texts)
+}
+
+// Command runs an external tool that reads {"source", "target", "texts"} as JSON on
+// stdin and writes a JSON array with one translated string per text to stdout
+type Command struct {
+	Args []string
+}
+
+// Translate runs the command once for the whole batch
+func (t *Command) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	input, err := json.Marshal(map[string]any{
+		"source": source,
+		"target": target,
+		"texts":  texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Args[0], t.Args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("translation command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var translated []string
+	if err := json.Unmarshal(output, &translated); err != nil {
+		return nil, fmt.Errorf("failed to parse translation command output: %w", err)
+	}
+	return checkCount(translated, texts)
+}
+
+// checkCount makes sure the backend returned one translation per input text
+func checkCount(translated, texts []string) ([]string, error) {
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("expected %d translations, got %d", len(texts), len(translated))
+	}
+	return translated, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"transription-service/internal/queue"
+)
+
+// Timing of the shared job queue
+const (
+	jobQueuePollInterval = time.Second      // how long an idle worker waits before asking again
+	jobQueueReapInterval = 10 * time.Second // how often lapsed claims are requeued
+)
+
+// errQueueUnavailable is returned when a job can't be handed to the shared queue
+var errQueueUnavailable = &APIError{Status: http.StatusServiceUnavailable, Code: "queue_unavailable", Message: "Job queue unavailable, try again later"}
+
+// jobMessage is what a replica needs to run a job another replica accepted
+type jobMessage struct {
+	Filename  string            `json:"filename"`
+	AudioName string            `json:"audio_name"` // base name of the upload, whose extension ffmpeg relies on
+	Options   TranscribeOptions `json:"options"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// asyncQueueDepth counts the async jobs waiting for a transcription slot once extra more are
+// accepted: those beyond the slots on this replica or, with REDIS_URL, those in the shared queue
+func (s *Service) asyncQueueDepth(ctx context.Context, extra int) (int, error) {
+	if s.Queue == nil {
+		return max(0, s.Jobs.Unfinished()+extra-currentConfig().MaxConcurrentJobs), nil
+	}
+	pending, _, err := s.Queue.Depth(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int(pending) + extra, nil
+}
+
+// admitJobs checks that n more async jobs fit within MAX_QUEUED_JOBS before they are created,
+// so a full queue turns the request away instead of failing its jobs once they start
+func (s *Service) admitJobs(ctx context.Context, n int) error {
+	limit := currentConfig().MaxQueuedJobs
+	if limit <= 0 {
+		return nil
+	}
+	depth, err := s.asyncQueueDepth(ctx, n)
+	if err != nil {
+		log.Printf("Error reading the job queue depth: %v", err)
+		return errQueueUnavailable
+	}
+	if depth > limit {
+		log.Printf("Rejected %d job(s), the job queue would hold %d of %d", n, depth, limit)
+		return errQueueFull
+	}
+	return nil
+}
+
+// startJob runs a created job here or, with REDIS_URL, queues it for whichever replica is free
+func (s *Service) startJob(ctx context.Context, job *Job, audioPath string) error {
+	if s.Queue == nil {
+		go s.runJob(job, audioPath)
+		return nil
+	}
+	return s.enqueueJob(ctx, job, audioPath)
+}
+
+// enqueueJob hands a job and its upload to the shared queue. The local copy is dropped so
+// status requests read the database, as they do on every other replica.
+func (s *Service) enqueueJob(ctx context.Context, job *Job, audioPath string) error {
+	defer os.RemoveAll(job.dir)
+
+	job.mu.Lock()
+	message := jobMessage{
+		Filename:  job.filename,
+		AudioName: filepath.Base(audioPath),
+		Options:   job.opts,
+		CreatedAt: job.createdAt,
+	}
+	job.mu.Unlock()
+
+	payload, err := json.Marshal(message)
+	var audioData []byte
+	if err == nil {
+		audioData, err = os.ReadFile(audioPath)
+	}
+	if err == nil {
+		err = s.Queue.Enqueue(ctx, job.id, payload, audioData)
+	}
+	if err != nil {
+		log.Printf("Error queueing job %s: %v", job.id, err)
+		job.finish(TranscriptionResponse{}, 0, errQueueUnavailable)
+		s.Jobs.save(job)
+		return errQueueUnavailable
+	}
+	s.Jobs.forget(job.id)
+	return nil
+}
+
+// runQueueWorkers claims jobs from the shared queue while this replica has free
+// transcription slots, and requeues jobs whose workers stopped responding
+func (s *Service) runQueueWorkers() {
+	go s.runQueueReaper()
+
+	var active atomic.Int32
+	for {
+		if int(active.Load()) >= currentConfig().MaxConcurrentJobs {
+			time.Sleep(jobQueuePollInterval)
+			continue
+		}
+		task, ok, err := s.Queue.Claim(context.Background())
+		if err != nil {
+			log.Printf("Error claiming a queued job: %v", err)
+		}
+		if !ok {
+			time.Sleep(jobQueuePollInterval)
+			continue
+		}
+		active.Add(1)
+		go func() {
+			defer active.Add(-1)
+			s.runQueuedJob(task)
+		}()
+	}
+}
+
+// runQueuedJob runs a claimed job, keeping the claim alive while it works, and removes it
+// from the queue once it has finished either way
+func (s *Service) runQueuedJob(task queue.Task) {
+	var message jobMessage
+	if err := json.Unmarshal(task.Payload, &message); err != nil {
+		log.Printf("Dropping queued job %s with an unreadable payload: %v", task.ID, err)
+		s.ackJob(task.ID)
+		return
+	}
+
+	// Without local storage the claim is left to lapse so another replica can take the job
+	dir, err := makeTempDir("audio-job")
+	if err != nil {
+		log.Printf("Leaving queued job %s for another replica: no temp directory: %v", task.ID, err)
+		return
+	}
+	audioPath := filepath.Join(dir, filepath.Base(message.AudioName))
+	if err := os.WriteFile(audioPath, task.Audio, 0o600); err != nil {
+		os.RemoveAll(dir)
+		log.Printf("Leaving queued job %s for another replica: writing its audio failed: %v", task.ID, err)
+		return
+	}
+
+	if task.Attempt > 1 {
+		log.Printf("Job %s picked up again (attempt %d) after its last worker stopped responding", task.ID, task.Attempt)
+	}
+	job := s.Jobs.adopt(task.ID, dir, message)
+
+	ctx, stop := context.WithCancel(context.Background())
+	go s.extendClaim(ctx, task.ID)
+	s.runJob(job, audioPath)
+	stop()
+	s.ackJob(task.ID)
+}
+
+// extendClaim renews the claim on a running job until ctx is done, so other replicas
+// don't take it over
+func (s *Service) extendClaim(ctx context.Context, id string) {
+	ticker := time.NewTicker(s.Queue.Visibility() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := s.Queue.Extend(ctx, id)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		if errors.Is(err, queue.ErrClaimLost) {
+			log.Printf("Claim on job %s lapsed; another replica may run it again", id)
+			return
+		}
+		log.Printf("Error extending the claim on job %s: %v", id, err)
+	}
+}
+
+// ackJob removes a job the replica is done with from the queue
+func (s *Service) ackJob(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+	if err := s.Queue.Ack(ctx, id); err != nil {
+		log.Printf("Error removing job %s from the queue: %v", id, err)
+	}
+}
+
+// runQueueReaper periodically requeues jobs whose claims lapsed because their replica crashed
+// or hung, and fails the ones that have used up JOB_MAX_ATTEMPTS
+func (s *Service) runQueueReaper() {
+	ticker := time.NewTicker(jobQueueReapInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+		requeued, dropped, err := s.Queue.RequeueExpired(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Error requeueing lapsed jobs: %v", err)
+			continue
+		}
+		for _, id := range requeued {
+			log.Printf("Job %s requeued after its worker stopped responding", id)
+		}
+		for _, id := range dropped {
+			log.Printf("Job %s abandoned after its last attempt stopped responding", id)
+			s.Jobs.abandon(id)
+		}
+	}
+}
+
+// adopt registers a job claimed from the shared queue, with its upload stored in dir
+func (s *JobStore) adopt(id, dir string, message jobMessage) *Job {
+	job := newJob(id, dir, message.Filename, message.Options, message.CreatedAt)
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+	return job
+}
+
+// forget drops a job from memory, leaving its record in the database
+func (s *JobStore) forget(id string) {
+	s.mu.Lock()
+	delete(s.jobs, id)
+	s.mu.Unlock()
+}
+
+// abandon marks a queued job failed in the database once every attempt at it was lost
+func (s *JobStore) abandon(id string) {
+	if s.db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	record, err := s.db.Get(ctx, id)
+	cancel()
+	if err != nil {
+		log.Printf("Error loading abandoned job %s: %v", id, err)
+		return
+	}
+	job := restoreJob(record)
+	job.finish(TranscriptionResponse{}, 0, &APIError{Status: http.StatusServiceUnavailable, Code: "job_abandoned", Message: "Job was abandoned after its workers repeatedly stopped responding"})
+	s.save(job)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdmitJobs(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxConcurrentJobs = 2
+	cfg.MaxQueuedJobs = 3
+	useConfig(t, cfg)
+
+	ctx := context.Background()
+	s := &Service{Jobs: NewJobStore(nil)}
+	for range 4 {
+		if _, err := s.Jobs.Create(t.TempDir(), "talk.mp3", TranscribeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Four unfinished jobs on two slots leave two waiting
+	if depth, _ := s.asyncQueueDepth(ctx, 0); depth != 2 {
+		t.Fatalf("depth = %d, want 2", depth)
+	}
+	if err := s.admitJobs(ctx, 1); err != nil {
+		t.Fatalf("admitting a third waiting job = %v, want nil", err)
+	}
+	if err := s.admitJobs(ctx, 2); err != errQueueFull {
+		t.Fatalf("admitting past MAX_QUEUED_JOBS = %v, want errQueueFull", err)
+	}
+
+	// Finished jobs no longer count
+	job, _ := s.Jobs.Create(t.TempDir(), "talk.mp3", TranscribeOptions{})
+	job.finish(TranscriptionResponse{}, 0, nil)
+	if err := s.admitJobs(ctx, 1); err != nil {
+		t.Fatalf("admitting after a finished job = %v, want nil", err)
+	}
+
+	cfg.MaxQueuedJobs = 0
+	if err := s.admitJobs(ctx, 100); err != nil {
+		t.Fatalf("admitting without a limit = %v, want nil", err)
+	}
+}
+
+func TestCreateJobRejectedWhenQueueFull(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxConcurrentJobs = 1
+	cfg.MaxQueuedJobs = 1
+	useConfig(t, cfg)
+
+	s := &Service{Jobs: NewJobStore(nil)}
+	for range 2 {
+		s.Jobs.Create(t.TempDir(), "talk.mp3", TranscribeOptions{})
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("audio", "talk.mp3")
+	part.Write([]byte("audio"))
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/jobs", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	s.handleCreateJob(c)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", w.Code, w.Body)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After")
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"queue_full"`)) {
+		t.Fatalf("body = %s, want code queue_full", w.Body)
+	}
+	if got := s.Jobs.Unfinished(); got != 2 {
+		t.Fatalf("%d unfinished jobs, want the rejected one not created", got)
+	}
+}
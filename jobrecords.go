@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"transription-service/internal/store"
+)
+
+// jobSaveTimeout bounds a single write of a job record
+const jobSaveTimeout = 5 * time.Second
+
+// record converts the job into its persisted form, with the result in JSON form
+func (j *Job) record() (store.Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	record := store.Job{
+		ID:         j.id,
+		Status:     string(j.status),
+		Filename:   j.filename,
+		Model:      j.opts.Model,
+		Duration:   j.duration.Seconds(),
+		CreatedAt:  j.createdAt,
+		StartedAt:  j.startedAt,
+		FinishedAt: j.finishedAt,
+	}
+
+	var err error
+	switch {
+	case j.status == JobCompleted && j.output.Bucket != "":
+		record.Result, err = json.Marshal(storedOutputBody(j.output, j.outputSize, j.response, j.duration))
+	case j.status == JobCompleted:
+		record.Result, err = json.Marshal(buildResult(j.response, j.duration, j.opts))
+	case j.status == JobFailed:
+		status, body := errorBody(j.err)
+		body["status"] = status
+		record.Error, err = json.Marshal(body)
+	}
+	return record, err
+}
+
+// searchSegments returns a completed job's segments for the search index, redacted like its result
+func (j *Job) searchSegments() []store.Segment {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	response, _ := processResponse(j.response, j.opts)
+	segments := make([]store.Segment, 0, len(response.Segments))
+	for i, segment := range response.Segments {
+		segments = append(segments, store.Segment{
+			JobID:   j.id,
+			Index:   i,
+			Start:   segment.StartTime,
+			End:     segment.EndTime,
+			Speaker: segment.Speaker,
+			Text:    segment.Text,
+		})
+	}
+	return segments
+}
+
+// save writes the job's current state to the database, when there is one, and indexes the
+// segments of completed jobs for search
+func (s *JobStore) save(job *Job) {
+	if s.db == nil {
+		return
+	}
+	record, err := job.record()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+		defer cancel()
+		err = s.db.Save(ctx, record)
+		if err == nil && record.Status == string(JobCompleted) {
+			err = s.db.IndexSegments(ctx, job.id, job.searchSegments())
+		}
+	}
+	if err != nil {
+		log.Printf("Error saving job %s: %v", job.id, err)
+	}
+}
+
+// restoreJob rebuilds a finished job from its record, for jobs the server no longer holds in
+// memory. Its result is served as the stored JSON.
+func restoreJob(record store.Job) *Job {
+	job := &Job{
+		id:         record.ID,
+		filename:   record.Filename,
+		opts:       TranscribeOptions{Model: record.Model},
+		status:     JobStatus(record.Status),
+		updated:    make(chan struct{}),
+		createdAt:  record.CreatedAt,
+		startedAt:  record.StartedAt,
+		finishedAt: record.FinishedAt,
+		duration:   time.Duration(record.Duration * float64(time.Second)),
+		stored:     record.Result,
+	}
+	if job.status == JobCompleted {
+		job.output = storedOutputLocation(record.Result)
+	}
+	if len(record.Error) > 0 {
+		var body struct {
+			Status  int    `json:"status"`
+			Code    string `json:"code"`
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		if err := json.Unmarshal(record.Error, &body); err != nil || body.Status == 0 {
+			body.Status = http.StatusInternalServerError
+		}
+		job.err = &APIError{Status: body.Status, Code: body.Code, Message: body.Error, Details: body.Details}
+	}
+	return job
+}
+
+// Lookup returns a job from memory or, once it has been forgotten or the server restarted,
+// from the database
+func (s *JobStore) Lookup(ctx context.Context, id string) (*Job, bool) {
+	if job, ok := s.Get(id); ok {
+		return job, true
+	}
+	if s.db == nil {
+		return nil, false
+	}
+	record, err := s.db.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Error loading job %s: %v", id, err)
+		}
+		return nil, false
+	}
+	return restoreJob(record), true
+}
+
+// failInterrupted marks jobs a restart cut short as failed, since their uploads are gone
+func (s *JobStore) failInterrupted() {
+	if s.db == nil {
+		return
+	}
+	_, body := errorBody(&APIError{Status: http.StatusServiceUnavailable, Code: "job_interrupted", Message: "Job was interrupted by a server restart"})
+	body["status"] = http.StatusServiceUnavailable
+	data, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+	count, err := s.db.FailUnfinished(ctx, data)
+	if err != nil {
+		log.Printf("Error marking interrupted jobs: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Marked %d jobs interrupted by the last restart as failed", count)
+	}
+}
+
+// List returns jobs matching the filter, newest first and without results, from the
+// database or, without one, from memory
+func (s *JobStore) List(ctx context.Context, filter store.Filter) ([]store.Job, error) {
+	if s.db != nil {
+		return s.db.List(ctx, filter)
+	}
+
+	s.mu.Lock()
+	var records []store.Job
+	for _, job := range s.jobs {
+		job.mu.Lock()
+		record := store.Job{
+			ID:         job.id,
+			Status:     string(job.status),
+			Filename:   job.filename,
+			Model:      job.opts.Model,
+			Duration:   job.duration.Seconds(),
+			CreatedAt:  job.createdAt,
+			StartedAt:  job.startedAt,
+			FinishedAt: job.finishedAt,
+		}
+		job.mu.Unlock()
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(records, func(i, k int) bool {
+		if !records[i].CreatedAt.Equal(records[k].CreatedAt) {
+			return records[i].CreatedAt.After(records[k].CreatedAt)
+		}
+		return records[i].ID > records[k].ID
+	})
+	if len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/storage"
+	"transription-service/internal/store"
+)
+
+// jobURL is the path clients poll for a job's status
+func jobURL(id string) string {
+	return getBasePath() + "/api/jobs/" + id
+}
+
+// JobStatus is where an asynchronous transcription is in its life
+type JobStatus string
+
+// Job statuses; a job is queued until its goroutine starts and running until it finishes
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is a transcription running in the background for a client that polls for the result
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	dir        string // holds the upload until the job finishes
+	filename   string
+	opts       TranscribeOptions
+	status     JobStatus
+	stage      string
+	stages     []string               // every stage reached, in order
+	fraction   float64                // share of the audio decoded, while decoding
+	segments   []TranscriptionSegment // decoded so far, while running
+	updated    chan struct{}          // closed and replaced whenever the job changes
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	response   TranscriptionResponse
+	stored     json.RawMessage // result of a job restored from the database
+	callback   callbackDelivery
+	output     storage.Location // where the result was written, when it went to S3
+	outputSize int
+	duration   time.Duration
+	err        error
+}
+
+// JobStore keeps asynchronous jobs in memory until they have been finished for
+// JOB_TTL_SECONDS, and in the database, when there is one, until RESULT_RETENTION_SECONDS
+// runs out or for good
+type JobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	outputs map[storage.Location]time.Time // result objects due for deletion, without a database
+	db      *store.Store                   // nil keeps jobs in memory only
+}
+
+// NewJobStore creates an empty job store persisting to db, which may be nil
+func NewJobStore(db *store.Store) *JobStore {
+	return &JobStore{jobs: make(map[string]*Job), outputs: make(map[storage.Location]time.Time), db: db}
+}
+
+// newJob returns a queued job for an upload stored in dir
+func newJob(id, dir, filename string, opts TranscribeOptions, createdAt time.Time) *Job {
+	job := &Job{
+		id:        id,
+		dir:       dir,
+		filename:  filename,
+		opts:      opts,
+		status:    JobQueued,
+		stage:     "upload_saved",
+		stages:    []string{"upload_saved"},
+		updated:   make(chan struct{}),
+		createdAt: createdAt,
+	}
+	if opts.CallbackURL != "" {
+		job.callback.status = CallbackPending
+	}
+	return job
+}
+
+// Create registers a queued job for an upload stored in dir
+func (s *JobStore) Create(dir, filename string, opts TranscribeOptions) (*Job, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	job := newJob(hex.EncodeToString(idBytes), dir, filename, opts, time.Now())
+
+	s.mu.Lock()
+	s.jobs[job.id] = job
+	s.mu.Unlock()
+	s.save(job)
+	return job, nil
+}
+
+// Get returns a job by ID
+func (s *JobStore) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// Unfinished counts the jobs on this replica that haven't finished yet
+func (s *JobStore) Unfinished() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, job := range s.jobs {
+		job.mu.Lock()
+		if job.finishedAt.IsZero() {
+			count++
+		}
+		job.mu.Unlock()
+	}
+	return count
+}
+
+// RemoveExpired forgets jobs that finished longer than the TTL ago
+func (s *JobStore) RemoveExpired(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range s.jobs {
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && time.Since(job.finishedAt) > ttl
+		job.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// changed wakes everyone waiting for news of the job; the caller holds j.mu
+func (j *Job) changed() {
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+// onProgress records progress reported by the transcription pipeline
+func (j *Job) onProgress(event ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != JobRunning {
+		return
+	}
+	if event.Stage != "" && event.Stage != j.stage {
+		j.stage = event.Stage
+		j.stages = append(j.stages, event.Stage)
+	}
+	if event.Segment != nil {
+		j.segments = append(j.segments, *event.Segment)
+		j.fraction = event.Fraction
+	}
+	j.changed()
+}
+
+// finish stores the outcome of the job
+func (j *Job) finish(response TranscriptionResponse, duration time.Duration, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer j.changed()
+	j.finishedAt = time.Now()
+	j.duration = duration
+	j.stage = ""
+	j.segments = nil
+	if err != nil {
+		j.status = JobFailed
+		j.err = err
+		return
+	}
+	j.status = JobCompleted
+	j.response = response
+}
+
+// differsFrom reports whether j, a copy of the same job read later, has news since other:
+// another status, more stages or more segments
+func (j *Job) differsFrom(other *Job) bool {
+	if j == other {
+		return false
+	}
+	j.mu.Lock()
+	status, stages, segments := j.status, len(j.stages), len(j.segments)
+	j.mu.Unlock()
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	return status != other.status || stages > len(other.stages) || segments > len(other.segments)
+}
+
+// progress is the rough share of the work done, by stage; the caller holds j.mu
+func (j *Job) progress() float64 {
+	switch j.status {
+	case JobCompleted, JobFailed:
+		return 1
+	}
+	if j.stage == "decoding" && j.fraction > 0 {
+		return stageProgress["decoding"] + (stageProgress["finishing"]-stageProgress["decoding"])*j.fraction
+	}
+	return stageProgress[j.stage]
+}
+
+// statusBody describes the job for clients polling it
+func (j *Job) statusBody() gin.H {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	body := gin.H{
+		"job_id":     j.id,
+		"status":     j.status,
+		"progress":   j.progress(),
+		"filename":   j.filename,
+		"created_at": j.createdAt,
+		"status_url": jobURL(j.id),
+		"result_url": jobURL(j.id) + "/result",
+	}
+	if j.stage != "" {
+		body["stage"] = j.stage
+	}
+	if j.status == JobRunning {
+		body["segments_so_far"] = len(j.segments)
+	}
+	if !j.startedAt.IsZero() {
+		body["started_at"] = j.startedAt
+	}
+	if !j.finishedAt.IsZero() {
+		body["finished_at"] = j.finishedAt
+		body["processing_time_seconds"] = j.duration.Seconds()
+		withDeletedAfter(body, j.finishedAt)
+	}
+	if j.status == JobCompleted && j.output.Bucket == "" && currentConfig().DeleteAfterDownload {
+		body["delete_after_download"] = true
+	}
+	if j.output.Bucket != "" {
+		body["output_url"] = j.output.String()
+	}
+	if j.opts.CallbackURL != "" {
+		body["callback"] = j.callback.body(j.opts.CallbackURL)
+	}
+	if j.err != nil {
+		_, errBody := errorBody(j.err)
+		body["error"] = errBody
+	}
+	return body
+}
+
+// runJob transcribes a job's upload in the background and discards the upload afterwards
+func (s *Service) runJob(job *Job, audioPath string) {
+	defer os.RemoveAll(job.dir)
+
+	job.mu.Lock()
+	job.status = JobRunning
+	job.startedAt = time.Now()
+	opts := job.opts
+	job.changed()
+	job.mu.Unlock()
+	s.Jobs.save(job)
+
+	ctx := withProgress(context.Background(), job.onProgress)
+	response, err := s.transcribe(ctx, audioPath, opts)
+	duration := time.Since(job.startedAt)
+	if err == nil && opts.Output != nil {
+		var location storage.Location
+		var size int
+		location, size, err = s.storeOutput(context.Background(), response, duration, opts)
+		job.mu.Lock()
+		job.output, job.outputSize = location, size
+		job.mu.Unlock()
+	}
+	job.finish(response, duration, err)
+	s.Jobs.save(job)
+	if opts.CallbackURL != "" {
+		go s.deliverCallback(job)
+	}
+
+	if err != nil {
+		log.Printf("Job %s failed after %v: %v", job.id, duration, err)
+		return
+	}
+	log.Printf("Job %s completed in %v with %d segments", job.id, duration, len(response.Segments))
+}
+
+// handleCreateJob accepts an upload like /api/transcribe, starts transcribing it in the
+// background and answers 202 with the job ID right away
+func (s *Service) handleCreateJob(c *gin.Context) {
+	file, err := c.FormFile("audio")
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "No audio file provided"))
+		return
+	}
+	if exceedsUploadLimit(file.Filename, file.Size) {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", uploadLimitMB(file.Filename))))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	opts.Filename = file.Filename
+
+	if err := s.admitJobs(c.Request.Context(), 1); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// The upload outlives the request, so it goes to a directory the job owns
+	tmpDir, err := makeTempDir("audio-job")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	audioPath, err := saveUpload(c, file, tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		if isStorageError(err) {
+			writeError(c, storageError(err))
+			return
+		}
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to save uploaded file"))
+		return
+	}
+
+	job, err := s.Jobs.Create(tmpDir, file.Filename, opts)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		log.Printf("Error creating job: %v", err)
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to create job"))
+		return
+	}
+	if err := s.startJob(c.Request.Context(), job, audioPath); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	log.Printf("Job %s queued for %s", job.id, file.Filename)
+	c.Header("Location", jobURL(job.id))
+	c.JSON(http.StatusAccepted, job.statusBody())
+}
+
+// Page sizes for job listings
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 200
+)
+
+// parseJobFilter reads the status, from, to, limit and cursor query parameters of a listing.
+// Times are RFC 3339 or plain dates; a plain `to` date includes that whole day.
+func parseJobFilter(c *gin.Context) (store.Filter, error) {
+	filter := store.Filter{Status: c.Query("status")}
+	switch JobStatus(filter.Status) {
+	case "", JobQueued, JobRunning, JobCompleted, JobFailed:
+	default:
+		return store.Filter{}, fmt.Errorf("invalid status %q (expected queued, running, completed or failed)", filter.Status)
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*bound.value = t
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return store.Filter{}, fmt.Errorf("invalid %s %q (expected an RFC 3339 time or a date)", bound.name, value)
+		}
+		if bound.name == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		*bound.value = day
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultJobPageSize)))
+	if err != nil || limit < 1 || limit > maxJobPageSize {
+		return store.Filter{}, fmt.Errorf("invalid limit %q (expected 1 to %d)", c.Query("limit"), maxJobPageSize)
+	}
+	filter.Limit = limit
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		createdAt, id, found := strings.Cut(string(decoded), "|")
+		if err != nil || !found {
+			return store.Filter{}, fmt.Errorf("invalid cursor")
+		}
+		if filter.AfterCreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return store.Filter{}, fmt.Errorf("invalid cursor")
+		}
+		filter.AfterID = id
+	}
+	return filter, nil
+}
+
+// jobCursor points just past a job in a listing
+func jobCursor(job store.Job) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(job.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + job.ID))
+}
+
+// jobSummary describes a listed job
+func jobSummary(job store.Job) gin.H {
+	summary := gin.H{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"filename":   job.Filename,
+		"model":      job.Model,
+		"created_at": job.CreatedAt,
+		"status_url": jobURL(job.ID),
+		"result_url": jobURL(job.ID) + "/result",
+	}
+	if !job.StartedAt.IsZero() {
+		summary["started_at"] = job.StartedAt
+	}
+	if !job.FinishedAt.IsZero() {
+		summary["finished_at"] = job.FinishedAt
+		summary["processing_time_seconds"] = job.Duration
+	}
+	return summary
+}
+
+// handleListJobs lists jobs newest first, optionally by status and creation time, one page
+// at a time; next_cursor fetches the following page
+func (s *Service) handleListJobs(c *gin.Context) {
+	filter, err := parseJobFilter(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	// One extra job tells whether there is another page
+	pageSize := filter.Limit
+	filter.Limit++
+	jobs, err := s.Jobs.List(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("Error listing jobs: %v", err)
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to list jobs"))
+		return
+	}
+
+	body := gin.H{}
+	if len(jobs) > pageSize {
+		jobs = jobs[:pageSize]
+		body["next_cursor"] = jobCursor(jobs[len(jobs)-1])
+	}
+	summaries := make([]gin.H, 0, len(jobs))
+	for _, job := range jobs {
+		summaries = append(summaries, jobSummary(job))
+	}
+	body["jobs"] = summaries
+	c.JSON(http.StatusOK, body)
+}
+
+// handleJobStatus reports the status and progress of a job
+func (s *Service) handleJobStatus(c *gin.Context) {
+	job, ok := s.Jobs.Lookup(c.Request.Context(), c.Param("id"))
+	if !ok {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "job_not_found", Message: "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job.statusBody())
+}
+
+// handleJobResult returns a finished job's transcription in the format it was submitted with,
+// the job's error when it failed, or its status with 202 while it is still running
+func (s *Service) handleJobResult(c *gin.Context) {
+	job, ok := s.Jobs.Lookup(c.Request.Context(), c.Param("id"))
+	if !ok {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "job_not_found", Message: "Job not found"})
+		return
+	}
+
+	job.mu.Lock()
+	status, response, duration, opts, err := job.status, job.response, job.duration, job.opts, job.err
+	output, outputSize, stored := job.output, job.outputSize, job.stored
+	job.mu.Unlock()
+
+	switch {
+	case status == JobCompleted && stored != nil:
+		c.Data(http.StatusOK, "application/json; charset=utf-8", stored)
+	case status == JobCompleted && output.Bucket != "":
+		c.JSON(http.StatusOK, storedOutputBody(output, outputSize, response, duration))
+	case status == JobCompleted:
+		respondTranscription(c, response, duration, opts)
+	case status == JobFailed:
+		writeError(c, err)
+	default:
+		c.JSON(http.StatusAccepted, job.statusBody())
+	}
+
+	// Results written to S3 are fetched from the bucket, out of sight, so only their TTL applies
+	if status == JobCompleted && output.Bucket == "" && currentConfig().DeleteAfterDownload && c.Writer.Status() == http.StatusOK {
+		s.deleteDownloadedJob(job.id)
+	}
+}
+
+// jobEventsKeepAlive is how often an idle event stream gets a comment so proxies keep it open
+const jobEventsKeepAlive = 15 * time.Second
+
+// jobEventsReload is how often the stream of a job running on another replica rereads its record
+const jobEventsReload = 2 * time.Second
+
+// handleJobEvents streams a job's progress as server-sent events: "stage" on every stage
+// transition, "progress" with the share done, "segment" for every segment decoded so far and
+// a closing "done" with the final status. Clients joining late get the current state first.
+func (s *Service) handleJobEvents(c *gin.Context) {
+	job, ok := s.Jobs.Lookup(c.Request.Context(), c.Param("id"))
+	if !ok {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "job_not_found", Message: "Job not found"})
+		return
+	}
+
+	// Long jobs outlive the server's write timeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(jobEventsKeepAlive)
+	defer keepAlive.Stop()
+
+	// Jobs running elsewhere only change in the database
+	var reload <-chan time.Time
+	if _, local := s.Jobs.Get(job.id); !local {
+		ticker := time.NewTicker(jobEventsReload)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	stagesSent, segmentsSent, lastProgress := 0, 0, -1.0
+	for {
+		job.mu.Lock()
+		status, progress, updated, err := job.status, job.progress(), job.updated, job.err
+		var stages []string
+		if stagesSent < len(job.stages) {
+			stages = append(stages, job.stages[stagesSent:]...)
+		}
+		var segments []TranscriptionSegment
+		if segmentsSent < len(job.segments) {
+			segments = append(segments, job.segments[segmentsSent:]...)
+		}
+		job.mu.Unlock()
+
+		// Stages that passed between wake-ups are still announced, in order
+		for _, stage := range stages {
+			c.SSEvent("stage", gin.H{"stage": stage})
+		}
+		stagesSent += len(stages)
+		for _, segment := range segments {
+			c.SSEvent("segment", segment)
+		}
+		segmentsSent += len(segments)
+		if progress != lastProgress {
+			c.SSEvent("progress", gin.H{"progress": progress})
+			lastProgress = progress
+		}
+
+		if status == JobCompleted || status == JobFailed {
+			done := gin.H{"status": status, "result_url": jobURL(job.id) + "/result"}
+			if err != nil {
+				_, done["error"] = errorBody(err)
+			}
+			c.SSEvent("done", done)
+			c.Writer.Flush()
+			return
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-updated:
+		case <-reload:
+			// A reread record is a new copy; only what changed in it is sent
+			if latest, ok := s.Jobs.Lookup(c.Request.Context(), job.id); ok && latest.differsFrom(job) {
+				job = latest
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// runJobJanitor periodically forgets jobs whose results have been kept in memory long enough
+// and deletes the ones past RESULT_RETENTION_SECONDS
+func (s *Service) runJobJanitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Jobs.RemoveExpired(currentConfig().JobTTL())
+		s.deleteExpiredResults()
+	}
+}
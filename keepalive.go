@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusTrailer carries the real outcome of a response committed early for keep-alive
+const statusTrailer = "X-Transcription-Status"
+
+// runWithKeepAlive runs fn and, if it outlasts the interval, commits a 200 JSON response
+// and writes a newline every interval so proxies don't time out the idle connection.
+// Leading whitespace is ignored by JSON parsers. It reports whether the response was
+// committed, in which case the result must be written with writeCommittedJSON.
+func runWithKeepAlive(c *gin.Context, interval time.Duration, fn func() (TranscriptionResponse, error)) (TranscriptionResponse, bool, error) {
+	type outcome struct {
+		response TranscriptionResponse
+		err      error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		response, err := fn()
+		done <- outcome{response, err}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	committed := false
+	for {
+		select {
+		case result := <-done:
+			return result.response, committed, result.err
+		case <-ticker.C:
+			if !committed {
+				c.Header("Content-Type", "application/json; charset=utf-8")
+				c.Header("Trailer", statusTrailer)
+				c.Status(http.StatusOK)
+				committed = true
+			}
+			_, _ = c.Writer.Write([]byte("\n"))
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeCommittedJSON finishes a response that keep-alive already started, reporting the
+// real status in the trailer since the status line has been sent
+func writeCommittedJSON(c *gin.Context, status int, body any) {
+	_ = json.NewEncoder(c.Writer).Encode(body)
+	c.Writer.Header().Set(statusTrailer, strconv.Itoa(status))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language describes a language supported by Whisper
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// isSupportedLanguage reports whether the code is a Whisper language code
+func isSupportedLanguage(code string) bool {
+	for _, language := range supportedLanguages {
+		if language.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// languageCode turns a language name such as "english", which some engines report, into its
+// code; codes and unknown names are returned as they are
+func languageCode(language string) string {
+	for _, supported := range supportedLanguages {
+		if strings.EqualFold(supported.Name, language) {
+			return supported.Code
+		}
+	}
+	return language
+}
+
+// parseLanguageList splits a comma-separated list of language codes and validates each one
+func parseLanguageList(value string) ([]string, error) {
+	var codes []string
+	for _, code := range strings.Split(value, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if !isSupportedLanguage(code) {
+			return nil, fmt.Errorf("unsupported language %q", code)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// supportedLanguages mirrors whisper.tokenizer.LANGUAGES for the pinned openai-whisper release
+var supportedLanguages = []Language{
+	{"en", "English"},
+	{"zh", "Chinese"},
+	{"de", "German"},
+	{"es", "Spanish"},
+	{"ru", "Russian"},
+	{"ko", "Korean"},
+	{"fr", "French"},
+	{"ja", "Japanese"},
+	{"pt", "Portuguese"},
+	{"tr", "Turkish"},
+	{"pl", "Polish"},
+	{"ca", "Catalan"},
+	{"nl", "Dutch"},
+	{"ar", "Arabic"},
+	{"sv", "Swedish"},
+	{"it", "Italian"},
+	{"id", "Indonesian"},
+	{"hi", "Hindi"},
+	{"fi", "Finnish"},
+	{"vi", "Vietnamese"},
+	{"he", "Hebrew"},
+	{"uk", "Ukrainian"},
+	{"el", "Greek"},
+	{"ms", "Malay"},
+	{"cs", "Czech"},
+	{"ro", "Romanian"},
+	{"da", "Danish"},
+	{"hu", "Hungarian"},
+	{"ta", "Tamil"},
+	{"no", "Norwegian"},
+	{"th", "Thai"},
+	{"ur", "Urdu"},
+	{"hr", "Croatian"},
+	{"bg", "Bulgarian"},
+	{"lt", "Lithuanian"},
+	{"la", "Latin"},
+	{"mi", "Maori"},
+	{"ml", "Malayalam"},
+	{"cy", "Welsh"},
+	{"sk", "Slovak"},
+	{"te", "Telugu"},
+	{"fa", "Persian"},
+	{"lv", "Latvian"},
+	{"bn", "Bengali"},
+	{"sr", "Serbian"},
+	{"az", "Azerbaijani"},
+	{"sl", "Slovenian"},
+	{"kn", "Kannada"},
+	{"et", "Estonian"},
+	{"mk", "Macedonian"},
+	{"br", "Breton"},
+	{"eu", "Basque"},
+	{"is", "Icelandic"},
+	{"hy", "Armenian"},
+	{"ne", "Nepali"},
+	{"mn", "Mongolian"},
+	{"bs", "Bosnian"},
+	{"kk", "Kazakh"},
+	{"sq", "Albanian"},
+	{"sw", "Swahili"},
+	{"gl", "Galician"},
+	{"mr", "Marathi"},
+	{"pa", "Punjabi"},
+	{"si", "Sinhala"},
+	{"km", "Khmer"},
+	{"sn", "Shona"},
+	{"yo", "Yoruba"},
+	{"so", "Somali"},
+	{"af", "Afrikaans"},
+	{"oc", "Occitan"},
+	{"ka", "Georgian"},
+	{"be", "Belarusian"},
+	{"tg", "Tajik"},
+	{"sd", "Sindhi"},
+	{"gu", "Gujarati"},
+	{"am", "Amharic"},
+	{"yi", "Yiddish"},
+	{"lo", "Lao"},
+	{"uz", "Uzbek"},
+	{"fo", "Faroese"},
+	{"ht", "Haitian Creole"},
+	{"ps", "Pashto"},
+	{"tk", "Turkmen"},
+	{"nn", "Nynorsk"},
+	{"mt", "Maltese"},
+	{"sa", "Sanskrit"},
+	{"lb", "Luxembourgish"},
+	{"my", "Myanmar"},
+	{"bo", "Tibetan"},
+	{"tl", "Tagalog"},
+	{"mg", "Malagasy"},
+	{"as", "Assamese"},
+	{"tt", "Tatar"},
+	{"haw", "Hawaiian"},
+	{"ln", "Lingala"},
+	{"ha", "Hausa"},
+	{"ba", "Bashkir"},
+	{"jw", "Javanese"},
+	{"su", "Sundanese"},
+}
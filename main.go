@@ -2,35 +2,96 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"flag"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/audio"
+	"transription-service/internal/queue"
+	"transription-service/internal/storage"
+	"transription-service/internal/store"
+	"transription-service/internal/transcriber"
 )
 
 // TranscriptionSegment represents a segment of transcribed text with timestamp
-type TranscriptionSegment struct {
-	Text      string  `json:"text"`
-	StartTime float64 `json:"start_time"` // in seconds
-	EndTime   float64 `json:"end_time"`   // in seconds
-}
+type TranscriptionSegment = transcriber.TranscriptionSegment
 
-// TranscriptionResponse represents the response from the Python bridge
+// TranscriptionResponse represents the response from the transcription engine
 type TranscriptionResponse struct {
 	Error    string                 `json:"error,omitempty"`
 	Segments []TranscriptionSegment `json:"segments"`
+	Warnings []string               `json:"warnings,omitempty"`
+	Language string                 `json:"language,omitempty"`
+	Cached   bool                   `json:"-"`
+	Partial  bool                   `json:"-"` // recovered from truncated bridge output
+
+	// Text is the transcript when the segments had no usable timestamps; see TimestampsUnavailable
+	Text                  string `json:"-"`
+	TimestampsUnavailable bool   `json:"-"`
+
+	EstimatedSpeakers *int `json:"estimated_speakers,omitempty"`
+
+	// Engine is the remote engine that transcribed, when it wasn't the configured one
+	Engine string `json:"engine,omitempty"`
+
+	// DurationSeconds is the probed length of the audio, filled in for billing when pricing is configured
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+
+	// AudioSHA256 is the checksum of the uploaded bytes
+	AudioSHA256 string `json:"audio_sha256,omitempty"`
+
+	Translation *Translation `json:"-"`
+	Waveform    *Waveform    `json:"-"`
 }
 
 func main() {
+	// Load configuration up front so a broken config fails fast
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	activeConfig.Store(cfg)
+	initServedBy()
+	audio.SetMaxProcesses(cfg.MaxFFmpegJobs)
+
+	// `transcribe` runs offline on local files instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "transcribe" {
+		os.Exit(runTranscribeCommand(os.Args[2:]))
+	}
+
+	// --mode=worker consumes jobs from a message broker instead of serving HTTP
+	mode := flag.String("mode", "server", "server to serve the HTTP API, or worker to consume jobs from BROKER_URL")
+	flag.Parse()
+	if *mode != "server" && *mode != "worker" {
+		log.Fatalf("Invalid --mode %q (expected server or worker)", *mode)
+	}
+
+	// Check which device the bridge will use; fail fast when a GPU is required but missing
+	device, err := detectDevice(context.Background())
+	if err != nil {
+		log.Printf("Could not detect compute device: %v", err)
+		device = DeviceInfo{Device: "unknown", Error: err.Error()}
+	}
+	if currentConfig().RequireGPU && !device.IsGPU() {
+		log.Fatalf("REQUIRE_GPU is set but the transcription backend is using device %q", device.Device)
+	}
+
+	// Bridge workers import torch at startup and keep models loaded between requests
+	if err := startBridgeWorkers(cfg); err != nil {
+		log.Fatalf("Failed to start bridge workers: %v", err)
+	}
+
 	// Set up Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	router.Use(servedByHeader())
 
 	// Increase timeout for HTTP server
 	server := &http.Server{
@@ -40,160 +101,185 @@ func main() {
 		WriteTimeout: 5 * time.Minute,
 	}
 
-	// Serve static files
-	router.Static("/static", "./static")
-	router.StaticFile("/", "./static/index.html")
-
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	// Every upload passes through the temp directory, so a broken one is fatal
+	if err := checkWorkDir(); err != nil {
+		log.Fatalf("Work directory check failed: %v", err)
+	}
 
-	// API route for transcription
-	router.POST("/api/transcribe", func(c *gin.Context) {
-		startTime := time.Now()
+	// API key validation is set up once; its cache lives as long as the server
+	keys, err := newKeyValidator()
+	if err != nil {
+		log.Fatalf("Invalid auth configuration: %v", err)
+	}
 
-		// Get the uploaded file
-		file, err := c.FormFile("audio")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No audio file provided"})
-			return
-		}
+	// Resumable uploads are assembled on disk until they are transcribed
+	uploads, err := NewUploadStore(filepath.Join(os.TempDir(), "resumable-uploads"))
+	if err != nil {
+		log.Fatalf("Failed to create upload directory: %v", err)
+	}
 
-		// Limit file size
-		if file.Size > 25*1024*1024 { // 25MB limit
-			c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 25MB)"})
-			return
-		}
+	// S3 input and output use the standard AWS credential chain; without it they are disabled
+	objectStore, err := storage.NewS3(context.Background(), currentConfig().S3Endpoint)
+	if err != nil {
+		log.Printf("S3 storage disabled: %v", err)
+	}
 
-		// Create temp directory for uploaded files
-		tmpDir, err := os.MkdirTemp("", "audio-upload")
-		if err != nil {
-			log.Printf("Error creating temp dir: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create temp directory"})
-			return
+	if *mode == "worker" {
+		worker := &Service{
+			Scheduler: NewScheduler(currentConfig().MaxConcurrentJobs),
+			Breaker:   NewCircuitBreaker(),
+			Models:    NewModelCatalog(),
+			Cache:     NewResultCache(),
+			History:   NewHistoryStore(),
+			Device:    device,
 		}
-		defer os.RemoveAll(tmpDir)
-
-		// Save the uploaded file
-		audioPath := filepath.Join(tmpDir, file.Filename)
-		if err := c.SaveUploadedFile(file, audioPath); err != nil {
-			log.Printf("Error saving uploaded file: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save uploaded file"})
-			return
+		if objectStore != nil {
+			worker.Storage = objectStore
 		}
+		os.Exit(worker.runBrokerWorker())
+	}
 
-		log.Printf("Saved file: %s (size: %.2f MB)", audioPath, float64(file.Size)/(1024*1024))
+	// Jobs outlive restarts in SQLite, or Postgres when DATABASE_URL is a postgres:// URL
+	var jobDB *store.Store
+	if databaseURL := currentConfig().DatabaseURL; databaseURL != "" && databaseURL != "none" {
+		if jobDB, err = store.Open(context.Background(), databaseURL); err != nil {
+			log.Fatalf("Failed to open job database: %v", err)
+		}
+		defer jobDB.Close()
+	}
 
-		// Output path for the transcription
-		outputPath := filepath.Join(tmpDir, "output.json")
+	service := &Service{
+		// Limit concurrent transcriptions; excess requests queue by priority
+		Scheduler: NewScheduler(currentConfig().MaxConcurrentJobs),
+		Warmer:    NewWarmer(),
+		Inflight:  NewInflightTracker(),
+		Breaker:   NewCircuitBreaker(),
+		Uploads:   uploads,
+		Models:    NewModelCatalog(),
+		Cache:     NewResultCache(),
+		History:   NewHistoryStore(),
+		Device:    device,
+		Keys:      keys,
+		Jobs:      NewJobStore(jobDB),
+	}
+	if objectStore != nil {
+		service.Storage = objectStore
+	}
 
-		// Get the current directory
-		currentDir, err := os.Getwd()
+	// With REDIS_URL, jobs go through a queue shared by every replica; otherwise unfinished
+	// jobs in the database were cut short by this server's last restart
+	if redisURL := currentConfig().RedisURL; redisURL != "" {
+		jobQueue, err := queue.NewRedis(context.Background(), redisURL, currentConfig().JobVisibilityTimeout(), currentConfig().JobMaxAttempts)
 		if err != nil {
-			log.Printf("Error getting current directory: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current directory"})
-			return
+			log.Fatalf("Failed to connect to the job queue: %v", err)
 		}
+		defer jobQueue.Close()
+		service.Queue = jobQueue
+		go service.runQueueWorkers()
+	} else {
+		service.Jobs.failInterrupted()
+	}
 
-		// Path to the Python bridge script
-		scriptPath := filepath.Join(currentDir, "whisper_bridge.py")
-
-		// Get model size from environment variable or use default
-		modelSize := os.Getenv("WHISPER_MODEL")
-		if modelSize == "" {
-			modelSize = "tiny" // Default to tiny model for speed and memory efficiency
-		}
+	// Abandoned resumable uploads are dropped after a day
+	go service.runUploadJanitor(24 * time.Hour)
 
-		// Set a timeout context - 3 minutes for processing
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
-		defer cancel()
-
-		// Prepare command with the context
-		cmd := exec.CommandContext(ctx,
-			"python3",
-			scriptPath,
-			"--input", audioPath,
-			"--output", outputPath,
-			"--model", modelSize,
-		)
-
-		log.Printf("Running transcription with model: %s", modelSize)
-
-		// Run the command and collect output
-		output, err := cmd.CombinedOutput()
-
-		// Handle different error cases
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Transcription timed out after %v", time.Since(startTime))
-			c.JSON(http.StatusRequestTimeout, gin.H{
-				"error": "Transcription timed out (3 minutes limit)",
-			})
-			return
-		}
+	// Finished jobs are forgotten after JOB_TTL_SECONDS
+	go service.runJobJanitor()
 
-		if err != nil {
-			log.Printf("Transcription error after %v: %v", time.Since(startTime), err)
-			log.Printf("Command output: %s", string(output))
-
-			// Check if output file exists despite the error
-			if _, statErr := os.Stat(outputPath); statErr == nil {
-				log.Printf("Output file exists despite error, trying to use it")
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":  fmt.Sprintf("Transcription failed: %v", err),
-					"output": string(output),
-				})
-				return
-			}
-		}
+	// Keep the model warm in the background when configured
+	go service.runWarmupSchedule(context.Background())
 
-		// Read the output file
-		data, err := os.ReadFile(outputPath)
-		if err != nil {
-			log.Printf("Error reading output file: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to read transcription results",
-				"details": err.Error(),
-			})
-			return
-		}
+	// Every route lives under BASE_PATH so the service can sit behind a path-routing proxy
+	routes := router.Group(getBasePath())
 
-		// Parse the JSON response
-		var response TranscriptionResponse
-		if err := json.Unmarshal(data, &response); err != nil {
-			log.Printf("Error parsing JSON: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to parse transcription output",
-				"details": err.Error(),
-			})
-			return
-		}
+	// Serve static files
+	routes.Static("/static", "./static")
+	routes.StaticFile("/", "./static/index.html")
 
-		// Check if the response contains an error
-		if response.Error != "" {
-			log.Printf("Error from transcription service: %s", response.Error)
-			if len(response.Segments) == 0 {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": response.Error,
-				})
-				return
-			}
-			// If there are segments, we'll still return them with a warning
-		}
+	// Health check endpoint
+	routes.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
 
-		// Return the transcription
-		duration := time.Since(startTime)
-		log.Printf("Transcription completed in %v with %d segments", duration, len(response.Segments))
+	// Build and backend information
+	routes.GET("/version", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
-			"segments":                response.Segments,
-			"processing_time_seconds": duration.Seconds(),
+			"version": version,
+			"model":   getModelName(),
+			"device":  service.Device,
 		})
 	})
 
+	// API routes need a valid key when AUTH_BACKEND is set; admin routes use ADMIN_TOKEN instead
+	api := routes.Group("/api", service.requireAPIKey())
+
+	// Runtime status of the transcription pipeline
+	api.GET("/status", service.handleStatus)
+
+	// Models the backend knows about and whether they are downloaded; changes need ADMIN_TOKEN
+	api.GET("/models", service.handleModels)
+	api.POST("/models/:name/download", requireAdmin(), service.handleDownloadModel)
+	api.DELETE("/models/:name", requireAdmin(), service.handleDeleteModel)
+
+	// Languages the transcription backend understands
+	api.GET("/languages", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"languages": supportedLanguages})
+	})
+
+	// API routes for transcription
+	api.POST("/transcribe", requestDeadline(), service.handleTranscribe)
+	api.POST("/transcribe/batch", requestDeadline(), service.handleBatchTranscribe)
+	api.POST("/transcribe/stream", service.handleStreamTranscribe)
+	api.POST("/transcribe-url", requestDeadline(), service.handleTranscribeURL)
+	api.POST("/detect-language", requestDeadline(), service.handleDetectLanguage)
+
+	// Live transcription of audio sent over a WebSocket
+	api.GET("/stream", service.handleLiveStream)
+
+	// Asynchronous transcription: submit a job, then poll its status and result; past jobs are listed
+	api.POST("/jobs", service.handleCreateJob)
+	api.GET("/jobs", service.handleListJobs)
+	api.GET("/jobs/:id", service.handleJobStatus)
+	api.GET("/jobs/:id/result", service.handleJobResult)
+	api.GET("/jobs/:id/events", service.handleJobEvents)
+
+	// Full-text search over the segments of stored jobs
+	api.GET("/search", service.handleSearch)
+
+	// Admin routes, guarded by ADMIN_TOKEN
+	admin := routes.Group("/api/admin", requireAdmin())
+	admin.POST("/reload", service.handleReload)
+
+	// Segment duration, gap and confidence aggregates over recent transcriptions
+	routes.GET("/api/analytics", requireAdmin(), service.handleAnalytics)
+
+	// Resumable uploads: create, send chunks with Content-Range, then transcribe
+	api.POST("/uploads", service.handleCreateUpload)
+	api.GET("/uploads/:id", service.handleUploadStatus)
+	api.PUT("/uploads/:id", requestDeadline(), service.handleUploadChunk)
+	api.POST("/uploads/:id/transcribe", requestDeadline(), service.handleTranscribeUpload)
+
+	// Price a transcription before running it
+	api.POST("/estimate-cost", service.handleEstimateCost)
+
+	// Transcribe one file with two models and diff the results
+	api.POST("/compare", requestDeadline(), service.handleCompare)
+
+	// Preload the model so the first real request is fast
+	api.POST("/warmup", service.handleWarmup)
+
+	// gRPC clients get the same pipeline on GRPC_PORT
+	if err := service.startGRPCServer(); err != nil {
+		log.Fatalf("Failed to start gRPC server: %v", err)
+	}
+
 	// Start the server
 	log.Println("Starting server on port " + getPort() + "...")
+	if basePath := getBasePath(); basePath != "" {
+		log.Println("Serving under base path: " + basePath)
+	}
 	log.Println("Using Whisper model: " + getModelName())
+	log.Println("Using compute device: " + device.Device)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Failed to start server: %v", err)
 	}
@@ -208,11 +294,26 @@ func getPort() string {
 	return port
 }
 
+// getBasePath returns BASE_PATH normalized to a leading slash and no trailing one,
+// or an empty string to serve from the root
+func getBasePath() string {
+	basePath := strings.Trim(strings.TrimSpace(os.Getenv("BASE_PATH")), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// getEnvInt reads an integer from the environment, falling back to the default
+func getEnvInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 // getModelName gets the configured Whisper model name
 func getModelName() string {
-	model := os.Getenv("WHISPER_MODEL")
-	if model == "" {
-		model = "tiny" // Align with the default in the handler
-	}
-	return model
+	return currentConfig().Model
 }
@@ -0,0 +1,25 @@
+package main
+
+import "path/filepath"
+
+// filenameMetadata extracts the named groups of FILENAME_METADATA_PATTERN from an upload's
+// name. It returns nil when the pattern is unset or does not match.
+func filenameMetadata(filename string) map[string]string {
+	pattern := currentConfig().filenameMetadata
+	if pattern == nil || filename == "" {
+		return nil
+	}
+
+	match := pattern.FindStringSubmatch(filepath.Base(filename))
+	if match == nil {
+		return nil
+	}
+
+	metadata := make(map[string]string)
+	for i, name := range pattern.SubexpNames() {
+		if name != "" && i < len(match) && match[i] != "" {
+			metadata[name] = match[i]
+		}
+	}
+	return metadata
+}
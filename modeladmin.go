@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelDownloadTimeout bounds a model download; the large checkpoints are around 3GB
+const modelDownloadTimeout = time.Hour
+
+// modelChange serializes downloads and deletions, so two operators can't race on one checkpoint
+var modelChange sync.Mutex
+
+// changeModel runs a bridge command that downloads or deletes a model and refreshes the
+// catalog afterwards
+func (s *Service) changeModel(ctx context.Context, timeout time.Duration, flag, name string) (ModelInfo, error) {
+	modelChange.Lock()
+	defer modelChange.Unlock()
+
+	var report struct {
+		ModelInfo
+		Error string `json:"error"`
+	}
+	err := runModelCommand(ctx, timeout, &report, flag, name)
+	if _, refreshErr := s.Models.Refresh(context.WithoutCancel(ctx)); refreshErr != nil {
+		log.Printf("Could not refresh models after %s %s: %v", flag, name, refreshErr)
+	}
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	if report.Error != "" {
+		return ModelInfo{}, fmt.Errorf("%s", report.Error)
+	}
+	return report.ModelInfo, nil
+}
+
+// knownModel looks a model up in the catalog, writing a 404 when the bridge doesn't know it
+func (s *Service) knownModel(c *gin.Context, name string) (ModelInfo, bool) {
+	models, err := s.Models.Refresh(c.Request.Context())
+	if err != nil {
+		writeError(c, &APIError{Status: http.StatusServiceUnavailable, Message: "Failed to list models", Details: err.Error()})
+		return ModelInfo{}, false
+	}
+	model, ok := findModel(models, name)
+	if !ok {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "unknown_model", Message: fmt.Sprintf("Unknown model %q", name)})
+		return ModelInfo{}, false
+	}
+	return model, true
+}
+
+// handleDownloadModel downloads a model into the Whisper cache, doing nothing when it is
+// already there
+func (s *Service) handleDownloadModel(c *gin.Context) {
+	name := c.Param("name")
+	model, ok := s.knownModel(c, name)
+	if !ok {
+		return
+	}
+	if model.Installed {
+		c.JSON(http.StatusOK, gin.H{"name": name, "installed": true, "size_bytes": model.SizeBytes, "already_installed": true})
+		return
+	}
+
+	startTime := time.Now()
+	model, err := s.changeModel(c.Request.Context(), modelDownloadTimeout, "--download-model", name)
+	if err != nil {
+		log.Printf("Download of model %s failed: %v", name, err)
+		writeError(c, &APIError{Status: http.StatusBadGateway, Code: "model_download_failed", Message: fmt.Sprintf("Failed to download model %q", name), Details: err.Error()})
+		return
+	}
+
+	log.Printf("Downloaded model %s (%d bytes) in %v", name, model.SizeBytes, time.Since(startTime))
+	c.JSON(http.StatusOK, gin.H{
+		"name":              name,
+		"installed":         model.Installed,
+		"size_bytes":        model.SizeBytes,
+		"already_installed": false,
+		"duration_seconds":  time.Since(startTime).Seconds(),
+	})
+}
+
+// handleDeleteModel removes a downloaded model from the Whisper cache. Models clients may
+// request stay, since deleting them would only make their requests fail.
+func (s *Service) handleDeleteModel(c *gin.Context) {
+	name := c.Param("name")
+	cfg := currentConfig()
+	if slices.Contains(cfg.ModelChoices(), name) {
+		writeError(c, &APIError{Status: http.StatusConflict, Code: "model_in_use", Message: fmt.Sprintf("Model %q is the default or in ALLOWED_MODELS and can't be deleted", name)})
+		return
+	}
+	model, ok := s.knownModel(c, name)
+	if !ok {
+		return
+	}
+	if !model.Installed {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "model_not_installed", Message: fmt.Sprintf("Model %q is not installed", name)})
+		return
+	}
+
+	if _, err := s.changeModel(c.Request.Context(), time.Minute, "--delete-model", name); err != nil {
+		log.Printf("Deletion of model %s failed: %v", name, err)
+		writeError(c, &APIError{Status: http.StatusInternalServerError, Code: "model_delete_failed", Message: fmt.Sprintf("Failed to delete model %q", name), Details: err.Error()})
+		return
+	}
+	s.Warmer.forget(name)
+
+	log.Printf("Deleted model %s, freeing %d bytes", name, model.SizeBytes)
+	c.JSON(http.StatusOK, gin.H{"name": name, "installed": false, "freed_bytes": model.SizeBytes})
+}
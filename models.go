@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelCatalogTTL is how long the bridge's model report is reused before checking again
+const modelCatalogTTL = time.Minute
+
+// ModelInfo describes a Whisper model the bridge knows about
+type ModelInfo struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	SizeBytes int64  `json:"size_bytes,omitempty"` // of the downloaded checkpoint
+}
+
+// ModelCatalog caches which models are installed so requests don't spawn Python to check
+type ModelCatalog struct {
+	mu        sync.Mutex
+	models    []ModelInfo
+	checkedAt time.Time
+}
+
+// NewModelCatalog creates an empty catalog, filled on first use
+func NewModelCatalog() *ModelCatalog {
+	return &ModelCatalog{}
+}
+
+// List returns the known models, asking the bridge again once the cached report is stale
+func (m *ModelCatalog) List(ctx context.Context) ([]ModelInfo, error) {
+	m.mu.Lock()
+	if m.models != nil && time.Since(m.checkedAt) < modelCatalogTTL {
+		defer m.mu.Unlock()
+		return m.models, nil
+	}
+	m.mu.Unlock()
+	return m.Refresh(ctx)
+}
+
+// Refresh asks the bridge which models exist and which are downloaded
+func (m *ModelCatalog) Refresh(ctx context.Context) ([]ModelInfo, error) {
+	models, err := detectModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = models
+	m.checkedAt = time.Now()
+	return models, nil
+}
+
+// Require fails with a 422 when the model is unknown or not installed. Detection problems
+// are only logged, leaving the bridge to report them.
+func (m *ModelCatalog) Require(ctx context.Context, name string) error {
+	// Checkpoint files can be passed as the model directly
+	if strings.HasSuffix(name, ".pt") {
+		if _, err := os.Stat(name); err != nil {
+			return &APIError{Status: http.StatusUnprocessableEntity, Code: "model_not_installed", Message: fmt.Sprintf("Model file %q not found", name)}
+		}
+		return nil
+	}
+
+	models, err := m.List(ctx)
+	if err != nil {
+		log.Printf("Could not check installed models: %v", err)
+		return nil
+	}
+
+	model, ok := findModel(models, name)
+	if ok && !model.Installed {
+		// It may have been downloaded since the last check
+		if models, err = m.Refresh(ctx); err == nil {
+			model, ok = findModel(models, name)
+		}
+	}
+
+	var installed []string
+	for _, model := range models {
+		if model.Installed {
+			installed = append(installed, model.Name)
+		}
+	}
+
+	switch {
+	case !ok:
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    "unknown_model",
+			Message: fmt.Sprintf("Unknown model %q", name),
+			Fields:  gin.H{"installed_models": installed},
+		}
+	case !model.Installed:
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    "model_not_installed",
+			Message: fmt.Sprintf("Model %q is not installed on the server; warm it up with POST /api/warmup or use an installed model", name),
+			Fields:  gin.H{"installed_models": installed},
+		}
+	}
+	return nil
+}
+
+// requestedModel returns the model a request asked for, or the default when it names none.
+// Only allowed models can be picked, so clients can't load arbitrary models onto the server.
+func requestedModel(name string) (string, error) {
+	cfg := currentConfig()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return cfg.Model, nil
+	}
+	if !cfg.ModelAllowed(name) {
+		return "", fmt.Errorf("model %q is not allowed (expected one of %s)", name, strings.Join(cfg.ModelChoices(), ", "))
+	}
+	return name, nil
+}
+
+// findModel looks a model up by name
+func findModel(models []ModelInfo, name string) (ModelInfo, bool) {
+	for _, model := range models {
+		if model.Name == name {
+			return model, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// runModelCommand runs a bridge model command and decodes the JSON report it prints as
+// the last line of stdout
+func runModelCommand(ctx context.Context, timeout time.Duration, report any, args ...string) error {
+	scriptPath, err := bridgeScriptPath()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "python3", append([]string{scriptPath}, args...)...).Output()
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), report); err != nil {
+		return fmt.Errorf("failed to parse model report: %w", err)
+	}
+	return nil
+}
+
+// detectModels asks the bridge for its model list
+func detectModels(ctx context.Context) ([]ModelInfo, error) {
+	var report struct {
+		Models []ModelInfo `json:"models"`
+		Error  string      `json:"error"`
+	}
+	if err := runModelCommand(ctx, time.Minute, &report, "--list-models"); err != nil {
+		return nil, fmt.Errorf("model check failed: %w", err)
+	}
+	if report.Error != "" {
+		return nil, fmt.Errorf("model check failed: %s", report.Error)
+	}
+	return report.Models, nil
+}
+
+// handleModels lists the models the backend knows about, whether they are installed and
+// their size, and whether each has been loaded and may be requested
+func (s *Service) handleModels(c *gin.Context) {
+	list := s.Models.List
+	if c.Query("refresh") == "true" {
+		list = s.Models.Refresh
+	}
+
+	models, err := list(c.Request.Context())
+	if err != nil {
+		writeError(c, &APIError{Status: http.StatusServiceUnavailable, Message: "Failed to list models", Details: err.Error()})
+		return
+	}
+
+	cfg := currentConfig()
+	entries := make([]gin.H, 0, len(models))
+	for _, model := range models {
+		entry := gin.H{
+			"name":      model.Name,
+			"installed": model.Installed,
+			"loaded":    s.Warmer.IsWarm(model.Name),
+			"allowed":   cfg.ModelAllowed(model.Name),
+		}
+		if model.SizeBytes > 0 {
+			entry["size_bytes"] = model.SizeBytes
+		}
+		entries = append(entries, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"models": entries, "default": cfg.Model, "allowed": cfg.ModelChoices()})
+}
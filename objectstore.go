@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/storage"
+)
+
+// objectDownloader fetches s3:// URLs through the object store
+type objectDownloader struct {
+	store    storage.Store
+	maxBytes int64
+}
+
+func (d objectDownloader) Download(ctx context.Context, rawURL, dir string) (string, error) {
+	location, err := storage.ParseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return d.store.Download(ctx, location, dir, d.maxBytes)
+}
+
+// errObjectStorageDisabled is returned when s3:// input or output is used without a store
+var errObjectStorageDisabled = &APIError{Status: http.StatusServiceUnavailable, Code: "object_storage_unavailable", Message: "S3 is not configured on this server"}
+
+// objectStorageError turns a failed object store call into an API error
+func objectStorageError(err error, action string) *APIError {
+	switch {
+	case errors.Is(err, storage.ErrInvalidLocation):
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_url", Message: err.Error()}
+	case errors.Is(err, storage.ErrNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: "object_not_found", Message: "S3 object or bucket not found", Details: err.Error()}
+	case errors.Is(err, storage.ErrAccessDenied):
+		return &APIError{Status: http.StatusForbidden, Code: "object_access_denied", Message: "Access to the S3 object was denied", Details: err.Error()}
+	case errors.Is(err, storage.ErrTooLarge):
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Code: "file_too_large", Message: fmt.Sprintf("S3 object too large (max %dMB)", currentConfig().MaxVideoUploadMB)}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusGatewayTimeout, Code: "download_timeout", Message: "S3 request timed out"}
+	}
+	return &APIError{Status: http.StatusBadGateway, Code: "object_storage_failed", Message: "S3 " + action + " failed", Details: err.Error()}
+}
+
+// outputObjectName names a result after the upload and its audio hash, so repeated runs
+// over the same file overwrite one object and different files with the same name don't
+func outputObjectName(response TranscriptionResponse, opts TranscribeOptions, contentType string) string {
+	base := strings.TrimSuffix(filepath.Base(opts.Filename), filepath.Ext(opts.Filename))
+	if base == "" || base == "." || base == string(filepath.Separator) {
+		base = "transcript"
+	}
+	if len(response.AudioSHA256) >= 12 {
+		base += "-" + response.AudioSHA256[:12]
+	}
+	if strings.HasPrefix(contentType, "text/plain") {
+		return base + ".txt"
+	}
+	return base + outputExtension(opts.Format)
+}
+
+// storeOutput renders the result in the requested format and writes it under the output
+// prefix, returning where it went and its size
+func (s *Service) storeOutput(ctx context.Context, response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) (storage.Location, int, error) {
+	if s.Storage == nil {
+		return storage.Location{}, 0, errObjectStorageDisabled
+	}
+
+	contentType, data, ok := fileOutput(response, opts)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(buildResult(response, duration, opts)); err != nil {
+			return storage.Location{}, 0, err
+		}
+		contentType = "application/json"
+	}
+
+	location := opts.Output.Join(outputObjectName(response, opts, contentType))
+	if err := s.Storage.Upload(ctx, location, data, contentType); err != nil {
+		log.Printf("Error writing result to %s: %v", location, err)
+		return storage.Location{}, 0, objectStorageError(err, "upload")
+	}
+	log.Printf("Wrote result to %s (%d bytes)", location, len(data))
+	s.Jobs.trackOutput(location)
+	return location, len(data), nil
+}
+
+// storedOutputBody tells the client where its result was written
+func storedOutputBody(location storage.Location, size int, response TranscriptionResponse, duration time.Duration) gin.H {
+	body := gin.H{
+		"output_url":              location.String(),
+		"bucket":                  location.Bucket,
+		"key":                     location.Key,
+		"size_bytes":              size,
+		"segment_count":           len(response.Segments),
+		"processing_time_seconds": duration.Seconds(),
+	}
+	if response.AudioSHA256 != "" {
+		body["audio_sha256"] = response.AudioSHA256
+	}
+	if servedBy != "" {
+		body["served_by"] = servedBy
+	}
+	return body
+}
+
+// deliver sends the result to the client, or writes it to S3 and sends its location
+// when output_bucket was given
+func (s *Service) deliver(c *gin.Context, response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) {
+	if opts.Output == nil {
+		respondTranscription(c, response, duration, opts)
+		return
+	}
+	location, size, err := s.storeOutput(c.Request.Context(), response, duration, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, withDeletedAfter(storedOutputBody(location, size, response, duration), time.Now()))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// recoverPartialOutput salvages what it can from bridge output that was cut off mid-write:
+// every complete segment before the truncation point, plus the language if it came first.
+// It reports false when not a single segment could be read.
+func recoverPartialOutput(data []byte) (TranscriptionResponse, bool) {
+	var response TranscriptionResponse
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return response, false
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		key, _ := token.(string)
+
+		if key != "segments" {
+			// Keep simple fields that arrive intact, skip the rest
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				break
+			}
+			if key == "language" {
+				_ = json.Unmarshal(value, &response.Language)
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			break
+		}
+		for decoder.More() {
+			var segment TranscriptionSegment
+			if err := decoder.Decode(&segment); err != nil {
+				break
+			}
+			response.Segments = append(response.Segments, segment)
+		}
+		break
+	}
+
+	return response, len(response.Segments) > 0
+}
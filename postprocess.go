@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"transription-service/internal/transcriber"
+)
+
+// postProcessSteps lists the segment post-processing steps in their default order. Redaction
+// is not one of them: when asked for it always runs first, so PII can't be split across
+// segments.
+var postProcessSteps = []string{"trim", "sentences", "split", "search"}
+
+// parsePostProcess reads the comma-separated postprocess option, the order in which the
+// listed steps run. split and search still take their settings from max_segment_duration
+// and search.
+func parsePostProcess(value string, maxSegmentDuration float64, search *transcriber.SegmentFilter) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var steps []string
+	for _, step := range strings.Split(value, ",") {
+		step = strings.TrimSpace(step)
+		switch {
+		case !slices.Contains(postProcessSteps, step):
+			return nil, fmt.Errorf("unknown postprocess step %q (expected %s)", step, strings.Join(postProcessSteps, ", "))
+		case slices.Contains(steps, step):
+			return nil, fmt.Errorf("postprocess step %q is listed twice", step)
+		case step == "split" && maxSegmentDuration <= 0:
+			return nil, fmt.Errorf("postprocess step split needs max_segment_duration")
+		case step == "search" && search == nil:
+			return nil, fmt.Errorf("postprocess step search needs a search query")
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// segmentChain builds the post-processing chain for a request. Redaction comes first, then
+// the steps listed in postprocess in that order, then steps switched on by their own options
+// but not listed, in the default order.
+func segmentChain(opts TranscribeOptions) transcriber.Chain {
+	steps := slices.Clone(opts.PostProcess)
+	for _, step := range postProcessSteps {
+		enabled := (step == "sentences" && opts.Format == "sentences") ||
+			(step == "split" && opts.MaxSegmentDuration > 0) ||
+			(step == "search" && opts.Search != nil)
+		if enabled && !slices.Contains(steps, step) {
+			steps = append(steps, step)
+		}
+	}
+
+	chain := make(transcriber.Chain, 0, len(steps)+1)
+	if opts.Redact {
+		chain = append(chain, transcriber.ProcessorFunc{ProcessorName: "redact", Fn: redactSegments})
+	}
+	for _, step := range steps {
+		switch step {
+		case "trim":
+			chain = append(chain, transcriber.Trim())
+		case "sentences":
+			chain = append(chain, transcriber.Sentences())
+		case "split":
+			chain = append(chain, transcriber.SplitLong(opts.MaxSegmentDuration))
+		case "search":
+			chain = append(chain, opts.Search)
+		}
+	}
+	return chain
+}
+
+// processResponse runs a transcription through the request's chain for output. The redact
+// step covers the whole response, text and translation included, since the full text,
+// stats and speaker tracks come from every segment; the rest of the chain is returned to
+// run over the segments that are shown.
+func processResponse(response TranscriptionResponse, opts TranscribeOptions) (TranscriptionResponse, transcriber.Chain) {
+	chain := segmentChain(opts)
+	if !opts.Redact {
+		return response, chain
+	}
+
+	redact := chain[:1]
+	response.Segments = redact.Process(response.Segments)
+	response.Text = redactText(response.Text)
+	if response.Translation != nil {
+		response.Translation = &Translation{
+			Language: response.Translation.Language,
+			Segments: redact.Process(response.Translation.Segments),
+		}
+	}
+	return response, chain[1:]
+}
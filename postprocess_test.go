@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"transription-service/internal/transcriber"
+)
+
+func TestOutputFormatsRunTheChain(t *testing.T) {
+	cfg := defaultConfig()
+	rules, err := compileRedactionRules(defaultRedactionRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.redactionRules = rules
+	useConfig(t, cfg)
+
+	search, err := transcriber.NewSegmentFilter("mail", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := TranscriptionResponse{Segments: []TranscriptionSegment{
+		{Text: "  Hello there  ", StartTime: 0, EndTime: 1},
+		{Text: " Mail me at ann@example.com ", StartTime: 1, EndTime: 2},
+		{Text: "   ", StartTime: 2, EndTime: 3},
+	}}
+	opts := TranscribeOptions{Redact: true, PostProcess: []string{"trim"}, Search: search, Precision: -1}
+
+	for _, format := range []string{"srt", "vtt", "html", "markdown", "dialogue"} {
+		t.Run(format, func(t *testing.T) {
+			opts := opts
+			opts.Format = format
+			_, data, ok := fileOutput(response, opts)
+			if !ok {
+				t.Fatal("no file output")
+			}
+			out := string(data)
+			if strings.Contains(out, "ann@example.com") || !strings.Contains(out, "EMAIL") {
+				t.Fatalf("output isn't redacted:\n%s", out)
+			}
+			if strings.Contains(out, "Hello") {
+				t.Fatalf("output isn't filtered by search:\n%s", out)
+			}
+		})
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		message := toProtoResponse(response, time.Second, opts)
+		if len(message.Segments) != 1 || message.Segments[0].Text != "Mail me at [EMAIL]" {
+			t.Fatalf("segments = %v, want the one trimmed, redacted match", message.Segments)
+		}
+		if !strings.Contains(message.Text, "Hello there") || strings.Contains(message.Text, "ann@example.com") {
+			t.Fatalf("text = %q, want every segment, redacted", message.Text)
+		}
+	})
+}
+
+func TestSegmentChainRedactsFirst(t *testing.T) {
+	search, _ := transcriber.NewSegmentFilter("x", false)
+	chain := segmentChain(TranscribeOptions{Redact: true, PostProcess: []string{"search", "trim"}, Search: search})
+	var names []string
+	for _, processor := range chain {
+		names = append(names, processor.Name())
+	}
+	if got := strings.Join(names, ","); got != "redact,search,trim" {
+		t.Fatalf("chain = %s, want redact,search,trim", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/audio"
+)
+
+// creditsFor returns the credits for transcribing seconds of audio with a model, rounded up
+// to a hundredth, and false when the model has no price
+func creditsFor(model string, seconds float64) (float64, bool) {
+	rate, ok := currentConfig().Pricing[model]
+	if !ok {
+		return 0, false
+	}
+	return math.Ceil(rate*seconds/60*100) / 100, true
+}
+
+// billedDuration returns how many seconds of a recording a request transcribes
+func billedDuration(duration float64, opts TranscribeOptions) float64 {
+	if opts.PreviewSeconds > 0 {
+		duration = min(duration, opts.PreviewSeconds)
+	}
+	if opts.Tail > 0 {
+		duration = min(duration, opts.Tail)
+	}
+	return duration
+}
+
+// audioDuration probes the length of an upload for billing, falling back to the end of the
+// last segment when ffprobe can't read it
+func audioDuration(ctx context.Context, audioPath string, segments []TranscriptionSegment) float64 {
+	duration, err := audio.Duration(ctx, audioPath)
+	if err == nil {
+		return duration
+	}
+	log.Printf("Could not probe audio duration for billing: %v", err)
+	for _, segment := range segments {
+		duration = max(duration, segment.EndTime)
+	}
+	return duration
+}
+
+// responseCredits returns the credits charged for a finished transcription, and false when
+// pricing is off or its duration is unknown
+func responseCredits(response TranscriptionResponse, opts TranscribeOptions) (float64, bool) {
+	if response.DurationSeconds <= 0 {
+		return 0, false
+	}
+	return creditsFor(opts.Model, billedDuration(response.DurationSeconds, opts))
+}
+
+// handleEstimateCost prices a transcription of the given length before it is run
+func (s *Service) handleEstimateCost(c *gin.Context) {
+	var request struct {
+		DurationSeconds float64 `json:"duration_seconds" binding:"required"`
+		Model           string  `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.DurationSeconds <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "duration_seconds must be a positive number"))
+		return
+	}
+	if request.Model == "" {
+		request.Model = getModelName()
+	}
+
+	if len(currentConfig().Pricing) == 0 {
+		writeError(c, &APIError{Status: http.StatusNotFound, Code: "pricing_not_configured", Message: "Pricing is not configured on this server"})
+		return
+	}
+	credits, ok := creditsFor(request.Model, request.DurationSeconds)
+	if !ok {
+		writeError(c, &APIError{Status: http.StatusUnprocessableEntity, Code: "no_price_for_model", Message: "No price is configured for model " + request.Model})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":            request.Model,
+		"duration_seconds": request.DurationSeconds,
+		"rate_per_minute":  currentConfig().Pricing[request.Model],
+		"credits":          credits,
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+
+	"transription-service/internal/transcriber"
+)
+
+// stageProgress is the rough share of the work done once a transcription reaches each stage.
+// Decoding moves on from its share towards finishing as the bridge reports segments.
+var stageProgress = map[string]float64{
+	"upload_saved":  0.05,
+	"preprocessing": 0.1,
+	"vad":           0.15,
+	"waiting":       0.2,
+	"transcribing":  0.3,
+	"model_loaded":  0.35,
+	"decoding":      0.4,
+	"finishing":     0.9,
+}
+
+// ProgressEvent is a step of a running transcription: a new stage, or a segment the engine
+// decoded with the share of the audio decoded so far
+type ProgressEvent = transcriber.ProgressEvent
+
+// progressKey carries a progress callback through the transcription context
+type progressKey struct{}
+
+// withProgress returns a context whose transcription progress is reported to fn
+func withProgress(ctx context.Context, fn func(ProgressEvent)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressListener returns the progress callback of ctx, or nil when nobody listens
+func progressListener(ctx context.Context) func(ProgressEvent) {
+	fn, _ := ctx.Value(progressKey{}).(func(ProgressEvent))
+	return fn
+}
+
+// reportStage tells whoever listens on ctx which stage the transcription reached
+func reportStage(ctx context.Context, stage string) {
+	if fn := progressListener(ctx); fn != nil {
+		fn(ProgressEvent{Stage: stage})
+	}
+}
+
+// withShiftedProgress moves reported segments by shift seconds, for audio that was cut
+// before transcription
+func withShiftedProgress(ctx context.Context, shift float64) context.Context {
+	fn := progressListener(ctx)
+	if fn == nil || shift == 0 {
+		return ctx
+	}
+	return withProgress(ctx, func(event ProgressEvent) {
+		if event.Segment != nil {
+			shifted := []TranscriptionSegment{*event.Segment}
+			shiftSegments(shifted, shift)
+			event.Segment = &shifted[0]
+		}
+		fn(event)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RedactionRule replaces every match of a pattern with a placeholder
+type RedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Placeholder string `json:"placeholder"`
+}
+
+// defaultRedactionRules catch the common PII in spoken transcripts. Names are only caught
+// after a title, since anything more would need a named-entity model.
+var defaultRedactionRules = []RedactionRule{
+	{Name: "email", Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, Placeholder: "[EMAIL]"},
+	{Name: "card_number", Pattern: `\b(?:\d[ -]?){12,15}\d\b`, Placeholder: "[CARD_NUMBER]"},
+	{Name: "phone", Pattern: `(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`, Placeholder: "[PHONE]"},
+	{Name: "name", Pattern: `\b(?:Mr|Mrs|Ms|Miss|Dr|Prof)\.?\s+[A-Z][a-z]+(?:\s+[A-Z][a-z]+)?`, Placeholder: "[NAME]"},
+}
+
+// compiledRule is a redaction rule ready to apply
+type compiledRule struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// compileRedactionRules checks and compiles the configured rules, in order
+func compileRedactionRules(rules []RedactionRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", rule.Name, err)
+		}
+		placeholder := rule.Placeholder
+		if placeholder == "" {
+			placeholder = "[REDACTED]"
+		}
+		compiled = append(compiled, compiledRule{pattern: pattern, placeholder: placeholder})
+	}
+	return compiled, nil
+}
+
+// redactText applies the configured redaction rules to a piece of text
+func redactText(text string) string {
+	for _, rule := range currentConfig().redactionRules {
+		text = rule.pattern.ReplaceAllLiteralString(text, rule.placeholder)
+	}
+	return text
+}
+
+// redactSegments returns a copy of the segments with their text redacted, timestamps untouched
+func redactSegments(segments []TranscriptionSegment) []TranscriptionSegment {
+	redacted := make([]TranscriptionSegment, len(segments))
+	for i, segment := range segments {
+		segment.Text = redactText(segment.Text)
+		if segment.Alternatives != nil {
+			alternatives := make([]string, len(segment.Alternatives))
+			for j, alternative := range segment.Alternatives {
+				alternatives[j] = redactText(alternative)
+			}
+			segment.Alternatives = alternatives
+		}
+		redacted[i] = segment
+	}
+	return redacted
+}
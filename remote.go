@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/fetch"
+	"transription-service/internal/storage"
+)
+
+// downloadError turns a failed download into an error that clients can tell apart from a
+// failed transcription
+func downloadError(err error) *APIError {
+	var statusErr *fetch.StatusError
+	var sourceErr *fetch.SourceError
+	switch {
+	case errors.Is(err, fetch.ErrUnsupportedURL):
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_url", Message: err.Error()}
+	case errors.Is(err, fetch.ErrBlockedAddress):
+		return &APIError{Status: http.StatusBadRequest, Code: "url_not_allowed", Message: "URL points to a private or local address"}
+	case errors.Is(err, fetch.ErrTooLarge):
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Code: "file_too_large", Message: fmt.Sprintf("Remote file too large (max %dMB)", currentConfig().MaxVideoUploadMB)}
+	case errors.As(err, &statusErr):
+		return &APIError{
+			Status:  http.StatusBadGateway,
+			Code:    "download_failed",
+			Message: "Download failed: " + err.Error(),
+			Fields:  gin.H{"upstream_status": statusErr.Status},
+		}
+	case errors.Is(err, fetch.ErrTooLong):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: "media_too_long", Message: "Video too long: " + strings.TrimPrefix(err.Error(), fetch.ErrTooLong.Error()+": ")}
+	case errors.Is(err, fetch.ErrLiveStream):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: "live_stream", Message: "Live streams can't be transcribed from a URL"}
+	case errors.Is(err, fetch.ErrYTDLPMissing):
+		return &APIError{Status: http.StatusServiceUnavailable, Code: "downloader_unavailable", Message: "Video site downloads are not available on this server"}
+	case errors.As(err, &sourceErr):
+		return &APIError{Status: http.StatusBadGateway, Code: "source_unavailable", Message: "Video could not be downloaded", Details: sourceErr.Message}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusGatewayTimeout, Code: "download_timeout", Message: "Download timed out"}
+	case isStorageError(err):
+		return storageError(err)
+	}
+	return &APIError{Status: http.StatusBadGateway, Code: "download_failed", Message: "Download failed", Details: err.Error()}
+}
+
+// mediaDownloader saves remote media into a directory: plain files over HTTP or videos via yt-dlp
+type mediaDownloader interface {
+	Download(ctx context.Context, rawURL, dir string) (string, error)
+}
+
+// downloadMedia saves a URL, s3:// address or video page into dir with the downloader it
+// needs and checks the file against the upload limits
+func (s *Service) downloadMedia(ctx context.Context, rawURL, dir string) (string, error) {
+	// Videos have the larger limit; the type-specific one is checked once the name is known
+	cfg := currentConfig()
+	maxBytes := int64(cfg.MaxVideoUploadMB) * 1024 * 1024
+	var downloader mediaDownloader = cfg.NewDownloader(maxBytes)
+	fromStorage := storage.IsStorageURL(rawURL)
+	switch {
+	case fromStorage && s.Storage == nil:
+		return "", errObjectStorageDisabled
+	case fromStorage:
+		downloader = objectDownloader{store: s.Storage, maxBytes: maxBytes}
+	case fetch.IsVideoSite(rawURL):
+		downloader = cfg.NewYTDLP(maxBytes)
+	}
+	audioPath, err := downloader.Download(ctx, rawURL, dir)
+	if err != nil {
+		// A client that went away isn't a failed download
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			log.Printf("Download of %s failed: %v", rawURL, err)
+		}
+		if fromStorage {
+			return "", objectStorageError(err, "download")
+		}
+		return "", downloadError(err)
+	}
+
+	filename := filepath.Base(audioPath)
+	if info, err := os.Stat(audioPath); err == nil && exceedsUploadLimit(filename, info.Size()) {
+		return "", &APIError{Status: http.StatusRequestEntityTooLarge, Code: "file_too_large", Message: fmt.Sprintf("Remote file too large (max %dMB)", uploadLimitMB(filename))}
+	}
+	return audioPath, nil
+}
+
+// handleTranscribeURL downloads media from {"url": "..."} and transcribes it like an upload.
+// Transcription options go in the query string. YouTube and Vimeo links are fetched with
+// yt-dlp, audio only and up to MAX_MEDIA_DURATION_SECONDS long, and s3://bucket/key URLs
+// are read with the server's AWS credentials.
+func (s *Service) handleTranscribeURL(c *gin.Context) {
+	startTime := time.Now()
+
+	var request struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "url is required"))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	tmpDir, err := makeTempDir("audio-url")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	audioPath, err := s.downloadMedia(c.Request.Context(), request.URL, tmpDir)
+	if err != nil {
+		if clientGone(c) {
+			return
+		}
+		writeError(c, requestError(c, err))
+		return
+	}
+	log.Printf("Downloaded %s in %v", request.URL, time.Since(startTime))
+
+	opts.Filename = filepath.Base(audioPath)
+	response, err := s.transcribe(c.Request.Context(), audioPath, opts)
+	if err != nil {
+		if clientGone(c) {
+			return
+		}
+		writeError(c, err)
+		return
+	}
+
+	duration := time.Since(startTime)
+	log.Printf("Transcription of %s completed in %v with %d segments", request.URL, duration, len(response.Segments))
+	s.deliver(c, response, duration, opts)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/formats"
+	"transription-service/internal/pb"
+)
+
+// buildResult shapes a transcription into the JSON body returned to clients
+func buildResult(response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) gin.H {
+	response, chain := processResponse(response, opts)
+
+	segments := chain.Process(response.Segments)
+	segments = roundSegments(segments, opts.Precision)
+	if opts.SampleRate > 0 || opts.FormattedTimes {
+		segments = decorateSegments(segments, opts)
+	}
+
+	result := gin.H{
+		"text":                    responseText(response),
+		"segments":                segments,
+		"processing_time_seconds": duration.Seconds(),
+		"stats":                   computeStats(response.Segments, currentConfig().ReadingWPM),
+		"cached":                  response.Cached,
+	}
+	if opts.Search != nil {
+		result["matches"] = len(segments)
+	}
+	if response.AudioSHA256 != "" {
+		result["audio_sha256"] = response.AudioSHA256
+	}
+	if servedBy != "" {
+		result["served_by"] = servedBy
+	}
+	result["timeout_seconds"] = effectiveTimeout(opts).Seconds()
+	if response.Partial {
+		result["partial"] = true
+	}
+	if response.TimestampsUnavailable {
+		result["timestamps_unavailable"] = true
+	}
+	if opts.PreviewSeconds > 0 {
+		result["preview"] = true
+		result["preview_seconds"] = opts.PreviewSeconds
+	}
+	if response.Language != "" {
+		result["language"] = response.Language
+	}
+	if response.Engine != "" {
+		result["engine"] = response.Engine
+	}
+	// The segments are English while language stays the spoken one
+	if opts.Task != "" {
+		result["task"] = opts.Task
+	}
+	if response.EstimatedSpeakers != nil {
+		result["estimated_speakers"] = *response.EstimatedSpeakers
+	}
+	if len(response.Warnings) > 0 {
+		result["warnings"] = response.Warnings
+	}
+	if opts.BucketSeconds > 0 {
+		result["buckets"] = groupByBucket(segments, opts.BucketSeconds)
+	}
+	if opts.SplitSpeakers {
+		tracks := groupBySpeaker(roundSegments(response.Segments, opts.Precision))
+		result["speakers"] = tracks
+		if opts.SpeakerSRT {
+			result["speaker_srt"] = speakerSRT(tracks, opts.Wrap)
+		}
+	}
+	if metadata := filenameMetadata(opts.Filename); metadata != nil {
+		result["metadata"] = metadata
+	}
+	if response.Waveform != nil {
+		result["waveform"] = response.Waveform
+	}
+	if credits, ok := responseCredits(response, opts); ok {
+		result["credits"] = credits
+	}
+	if response.Translation != nil {
+		result["translation"] = gin.H{
+			"language": response.Translation.Language,
+			"text":     joinSegmentText(response.Translation.Segments),
+			"segments": roundSegments(response.Translation.Segments, opts.Precision),
+		}
+	}
+	return result
+}
+
+// respondTranscription writes a finished transcription in the format the client asked for
+func respondTranscription(c *gin.Context, response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) {
+	// Non-JSON formats report cache hits in a header instead
+	c.Header("X-Transcription-Cached", strconv.FormatBool(response.Cached))
+	if response.AudioSHA256 != "" {
+		c.Header("X-Audio-SHA256", response.AudioSHA256)
+	}
+	if credits, ok := responseCredits(response, opts); ok {
+		c.Header("X-Transcription-Credits", strconv.FormatFloat(credits, 'f', -1, 64))
+	}
+	if response.TimestampsUnavailable {
+		c.Header("X-Timestamps-Unavailable", "true")
+	}
+	c.Header("X-Transcription-Timeout", strconv.FormatFloat(effectiveTimeout(opts).Seconds(), 'f', -1, 64))
+	if opts.PreviewSeconds > 0 {
+		c.Header("X-Transcription-Preview", strconv.FormatFloat(opts.PreviewSeconds, 'f', -1, 64))
+	}
+
+	// Complete results may be cached downstream; salvaged partial and text-only ones never are
+	if response.Partial || response.TimestampsUnavailable {
+		c.Header("Cache-Control", "no-store")
+	} else if cacheControl := currentConfig().CacheControl; cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+
+	// Subtitle and HTML formats are rendered as plain files
+	if renderFileOutput(c, response, opts) {
+		return
+	}
+
+	// Binary clients can ask for protobuf; JSON stays the default
+	if wantsProtobuf(c) {
+		c.ProtoBuf(http.StatusOK, toProtoResponse(response, duration, opts))
+		return
+	}
+
+	c.JSON(http.StatusOK, buildResult(response, duration, opts))
+}
+
+// isJSONFormat reports whether the output format is rendered as JSON
+func isJSONFormat(format string) bool {
+	return format == "" || format == "json" || format == "sentences"
+}
+
+// isSupportedFormat reports whether the output format can be rendered
+func isSupportedFormat(format string) bool {
+	switch format {
+	case "", "json", "sentences", "srt", "vtt", "html", "markdown", "dialogue":
+		return true
+	}
+	return false
+}
+
+// renderFileOutput writes the transcription as a subtitle, HTML, Markdown or dialogue file when one was requested
+func renderFileOutput(c *gin.Context, response TranscriptionResponse, opts TranscribeOptions) bool {
+	contentType, data, ok := fileOutput(response, opts)
+	if !ok {
+		return false
+	}
+	c.Data(http.StatusOK, contentType, data)
+	return true
+}
+
+// fileOutput renders the transcription in the requested subtitle, HTML, Markdown or dialogue format, reporting
+// false when the format is a JSON one
+func fileOutput(response TranscriptionResponse, opts TranscribeOptions) (string, []byte, bool) {
+	response, chain := processResponse(response, opts)
+
+	// Timed formats can't be built without timestamps, so the text is returned as it is
+	if response.TimestampsUnavailable && !isJSONFormat(opts.Format) {
+		return "text/plain; charset=utf-8", []byte(response.Text + "\n"), true
+	}
+
+	// Translated requests get subtitles in the target language
+	segments := response.Segments
+	if response.Translation != nil {
+		segments = response.Translation.Segments
+	}
+	cues := toCues(roundSegments(chain.Process(segments), opts.Precision))
+
+	switch opts.Format {
+	case "srt":
+		return "application/x-subrip; charset=utf-8", []byte(formats.SRT(cues, opts.Wrap)), true
+	case "vtt":
+		return "text/vtt; charset=utf-8", []byte(formats.VTT(cues, opts.Wrap, opts.VTTSettings)), true
+	case "html":
+		return "text/html; charset=utf-8", []byte(formats.HTML(cues)), true
+	case "markdown":
+		return "text/markdown; charset=utf-8", []byte(formats.Markdown(cues, currentConfig().MarkdownLinkTemplate)), true
+	case "dialogue":
+		return "text/plain; charset=utf-8", []byte(formats.Dialogue(cues, opts.DialogueTimestamps)), true
+	}
+	return "", nil, false
+}
+
+// toCues turns segments into the cues the file formats are rendered from
+func toCues(segments []TranscriptionSegment) []formats.Cue {
+	cues := make([]formats.Cue, 0, len(segments))
+	for _, segment := range segments {
+		cues = append(cues, formats.Cue{Text: segment.Text, Start: segment.StartTime, End: segment.EndTime, Speaker: segment.Speaker, Confidence: segment.Confidence})
+	}
+	return cues
+}
+
+// truncateOutput trims subprocess output for error responses unless debug mode is on.
+// The tail is kept since that is where Python tracebacks end up.
+func truncateOutput(output string) string {
+	cfg := currentConfig()
+	limit := cfg.ErrorOutputLimit
+	if cfg.Debug || len(output) <= limit {
+		return output
+	}
+	if limit <= 0 {
+		return "[output omitted]"
+	}
+	return "[truncated]..." + output[len(output)-limit:]
+}
+
+// responseText is the full transcript, taken from the segments unless only text was available
+func responseText(response TranscriptionResponse) string {
+	if response.TimestampsUnavailable {
+		return response.Text
+	}
+	return joinSegmentText(response.Segments)
+}
+
+// joinSegmentText concatenates segment texts into a single space-separated string
+func joinSegmentText(segments []TranscriptionSegment) string {
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if text := strings.TrimSpace(segment.Text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// decorateSegments returns a copy of the segments with the optional fields the request asked for
+func decorateSegments(segments []TranscriptionSegment, opts TranscribeOptions) []TranscriptionSegment {
+	decorated := make([]TranscriptionSegment, len(segments))
+	for i, segment := range segments {
+		if opts.SampleRate > 0 {
+			start := int64(math.Round(segment.StartTime * float64(opts.SampleRate)))
+			end := int64(math.Round(segment.EndTime * float64(opts.SampleRate)))
+			segment.StartSample = &start
+			segment.EndSample = &end
+		}
+		if opts.FormattedTimes {
+			segment.StartFormatted = formats.FormatClock(segment.StartTime)
+			segment.EndFormatted = formats.FormatClock(segment.EndTime)
+		}
+		decorated[i] = segment
+	}
+	return decorated
+}
+
+// maxTimestampPrecision is the finest timestamp_precision accepted, in decimal places
+const maxTimestampPrecision = 6
+
+// roundSegments returns a copy of the segments with timestamps rounded to the given number of
+// decimal places, or the segments unchanged when precision is negative
+func roundSegments(segments []TranscriptionSegment, precision int) []TranscriptionSegment {
+	if precision < 0 {
+		return segments
+	}
+
+	scale := math.Pow10(precision)
+	rounded := make([]TranscriptionSegment, len(segments))
+	for i, segment := range segments {
+		segment.StartTime = math.Round(segment.StartTime*scale) / scale
+		segment.EndTime = math.Round(segment.EndTime*scale) / scale
+		rounded[i] = segment
+	}
+	return rounded
+}
+
+// groupBySpeaker splits segments into per-speaker tracks, keeping their order
+func groupBySpeaker(segments []TranscriptionSegment) map[string][]TranscriptionSegment {
+	tracks := make(map[string][]TranscriptionSegment)
+	for _, segment := range segments {
+		speaker := segment.Speaker
+		if speaker == "" {
+			speaker = "unknown"
+		}
+		tracks[speaker] = append(tracks[speaker], segment)
+	}
+	return tracks
+}
+
+// speakerSRT renders each speaker's track as its own SubRip file, for one caption track per participant
+func speakerSRT(tracks map[string][]TranscriptionSegment, wrap formats.WrapOptions) map[string]string {
+	files := make(map[string]string, len(tracks))
+	for speaker, segments := range tracks {
+		files[speaker] = formats.SRT(toCues(segments), wrap)
+	}
+	return files
+}
+
+// TimeBucket is a fixed-size slice of the timeline with the segments starting in it
+type TimeBucket struct {
+	Start    float64                `json:"start"`
+	End      float64                `json:"end"`
+	Segments []TranscriptionSegment `json:"segments"`
+}
+
+// groupByBucket sorts segments into consecutive buckets of the given size by their start
+// time. Buckets run from zero to the last segment, empty ones included, so a bucket's index
+// is its position on the timeline.
+func groupByBucket(segments []TranscriptionSegment, size float64) []TimeBucket {
+	buckets := make([]TimeBucket, 0)
+	for _, segment := range segments {
+		index := int(segment.StartTime / size)
+		for len(buckets) <= index {
+			start := float64(len(buckets)) * size
+			buckets = append(buckets, TimeBucket{Start: start, End: start + size, Segments: []TranscriptionSegment{}})
+		}
+		buckets[index].Segments = append(buckets[index].Segments, segment)
+	}
+	return buckets
+}
+
+// acceptedFormats maps Accept media types to the output formats they select
+var acceptedFormats = map[string]string{
+	"application/x-subrip": "srt",
+	"text/vtt":             "vtt",
+}
+
+// acceptedFormat picks an output format from the Accept header for requests that don't name
+// one, so clients can ask for subtitles with content negotiation alone
+func acceptedFormat(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if format, ok := acceptedFormats[strings.ToLower(strings.TrimSpace(mediaType))]; ok {
+			return format
+		}
+	}
+	return ""
+}
+
+// wantsProtobuf reports whether the client asked for a protobuf-encoded response
+func wantsProtobuf(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "application/x-protobuf")
+}
+
+// toProtoResponse converts a transcription response into its protobuf message, with the
+// segments run through the request's post-processing chain like the JSON ones
+func toProtoResponse(response TranscriptionResponse, duration time.Duration, opts TranscribeOptions) *pb.TranscriptionResponse {
+	response, chain := processResponse(response, opts)
+	shown := roundSegments(chain.Process(response.Segments), opts.Precision)
+	segments := make([]*pb.TranscriptionSegment, 0, len(shown))
+	for _, segment := range shown {
+		segments = append(segments, &pb.TranscriptionSegment{
+			Text:      segment.Text,
+			StartTime: segment.StartTime,
+			EndTime:   segment.EndTime,
+			Speaker:   segment.Speaker,
+			Language:  segment.Language,
+		})
+	}
+
+	return &pb.TranscriptionResponse{
+		Error:                 response.Error,
+		Segments:              segments,
+		ProcessingTimeSeconds: duration.Seconds(),
+		Warnings:              response.Warnings,
+		Text:                  responseText(response),
+		Language:              response.Language,
+	}
+}
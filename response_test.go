@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondTranscriptionCacheControl(t *testing.T) {
+	complete := TranscriptionResponse{Segments: []TranscriptionSegment{{Text: "hi", EndTime: 1}}}
+	tests := []struct {
+		name         string
+		configured   string
+		response     TranscriptionResponse
+		cacheControl string
+	}{
+		{"complete", "public, max-age=60", complete, "public, max-age=60"},
+		{"complete without setting", "", complete, ""},
+		{"partial", "public, max-age=60", TranscriptionResponse{Segments: complete.Segments, Partial: true}, "no-store"},
+		{"text only", "public, max-age=60", TranscriptionResponse{Text: "hi", TimestampsUnavailable: true}, "no-store"},
+		{"partial without setting", "", TranscriptionResponse{Segments: complete.Segments, Partial: true}, "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.CacheControl = tt.configured
+			useConfig(t, cfg)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/transcribe", nil)
+			respondTranscription(c, tt.response, time.Second, TranscribeOptions{Format: "json"})
+
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Fatalf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+		})
+	}
+}
+
+func TestFailedTranscriptionIsNeverCacheable(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.CacheControl = "public, max-age=60"
+	useConfig(t, cfg)
+
+	// A bridge error reaches the client as an APIError, never as a response to cache
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/transcribe", nil)
+	writeError(c, &APIError{Status: http.StatusInternalServerError, Message: "Transcription failed: bridge exited"})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "" {
+		t.Fatalf("Cache-Control = %q, want none on an error", got)
+	}
+}
+
+func TestBuildResultSpeakerSRT(t *testing.T) {
+	useConfig(t, defaultConfig())
+	response := TranscriptionResponse{Segments: []TranscriptionSegment{
+		{Text: "Hi", StartTime: 0, EndTime: 1, Speaker: "SPEAKER_00"},
+		{Text: "Hello", StartTime: 1, EndTime: 2, Speaker: "SPEAKER_01"},
+		{Text: "Bye", StartTime: 2, EndTime: 3, Speaker: "SPEAKER_00"},
+	}}
+
+	result := buildResult(response, time.Second, TranscribeOptions{SplitSpeakers: true, Precision: -1})
+	if _, ok := result["speaker_srt"]; ok {
+		t.Fatal("speaker_srt returned without being asked for")
+	}
+
+	result = buildResult(response, time.Second, TranscribeOptions{SplitSpeakers: true, SpeakerSRT: true, Precision: -1})
+	files, _ := result["speaker_srt"].(map[string]string)
+	want := map[string]string{
+		"SPEAKER_00": "1\n00:00:00,000 --> 00:00:01,000\nHi\n\n2\n00:00:02,000 --> 00:00:03,000\nBye\n\n",
+		"SPEAKER_01": "1\n00:00:01,000 --> 00:00:02,000\nHello\n\n",
+	}
+	if len(files) != len(want) {
+		t.Fatalf("speaker_srt = %q, want %q", files, want)
+	}
+	for speaker, srt := range want {
+		if files[speaker] != srt {
+			t.Fatalf("speaker_srt[%s] = %q, want %q", speaker, files[speaker], srt)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"transription-service/internal/storage"
+	"transription-service/internal/store"
+)
+
+// retentionBatch bounds how many expired jobs or result objects one cleaner pass deletes
+const retentionBatch = 500
+
+// deletedAfter returns when a result finished at finishedAt is deleted under
+// RESULT_RETENTION_SECONDS, zero when results are kept
+func deletedAfter(finishedAt time.Time) time.Time {
+	retention := currentConfig().ResultRetention()
+	if retention <= 0 || finishedAt.IsZero() {
+		return time.Time{}
+	}
+	return finishedAt.Add(retention)
+}
+
+// withDeletedAfter adds deleted_after to a response body for a result finished at finishedAt,
+// when results are deleted
+func withDeletedAfter(body gin.H, finishedAt time.Time) gin.H {
+	if expiresAt := deletedAfter(finishedAt); !expiresAt.IsZero() {
+		body["deleted_after"] = expiresAt
+	}
+	return body
+}
+
+// storedOutputLocation reads where a restored job's result was written from its stored
+// result body, for jobs that wrote to S3
+func storedOutputLocation(stored json.RawMessage) storage.Location {
+	var body struct {
+		Bucket string `json:"bucket"`
+		Key    string `json:"key"`
+	}
+	json.Unmarshal(stored, &body)
+	return storage.Location{Bucket: body.Bucket, Key: body.Key}
+}
+
+// trackOutput schedules a result object written to S3 for deletion once RESULT_RETENTION_SECONDS
+// pass. Without a database the schedule lives in memory and a restart loses it.
+func (s *JobStore) trackOutput(location storage.Location) {
+	expiresAt := deletedAfter(time.Now())
+	if expiresAt.IsZero() {
+		return
+	}
+	if s.db == nil {
+		s.mu.Lock()
+		s.outputs[location] = expiresAt
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+	if err := s.db.TrackOutput(ctx, store.Output{Bucket: location.Bucket, Key: location.Key, ExpiresAt: expiresAt}); err != nil {
+		log.Printf("Error scheduling %s for deletion: %v", location, err)
+	}
+}
+
+// expiredOutputs returns tracked result objects due for deletion
+func (s *JobStore) expiredOutputs(ctx context.Context, now time.Time) ([]storage.Location, error) {
+	if s.db == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var locations []storage.Location
+		for location, expiresAt := range s.outputs {
+			if !expiresAt.After(now) {
+				locations = append(locations, location)
+			}
+		}
+		return locations, nil
+	}
+
+	outputs, err := s.db.ExpiredOutputs(ctx, now, retentionBatch)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]storage.Location, len(outputs))
+	for i, output := range outputs {
+		locations[i] = storage.Location{Bucket: output.Bucket, Key: output.Key}
+	}
+	return locations, nil
+}
+
+// forgetOutput stops tracking a result object once it has been deleted
+func (s *JobStore) forgetOutput(ctx context.Context, location storage.Location) error {
+	if s.db == nil {
+		s.mu.Lock()
+		delete(s.outputs, location)
+		s.mu.Unlock()
+		return nil
+	}
+	return s.db.ForgetOutput(ctx, location.Bucket, location.Key)
+}
+
+// delete removes a job, its result and its search index entries from memory and the database
+func (s *JobStore) delete(ctx context.Context, id string) error {
+	s.forget(id)
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Delete(ctx, id)
+}
+
+// expireResults deletes jobs that finished before the cutoff and returns how many went
+func (s *JobStore) expireResults(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	removed := 0
+	for id, job := range s.jobs {
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && job.finishedAt.Before(before)
+		job.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+			removed++
+		}
+	}
+	s.mu.Unlock()
+	if s.db == nil {
+		return removed, nil
+	}
+
+	ids, err := s.db.FinishedBefore(ctx, before, retentionBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := s.db.Delete(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// deleteExpiredResults deletes the jobs and result objects whose RESULT_RETENTION_SECONDS ran out
+func (s *Service) deleteExpiredResults() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if retention := currentConfig().ResultRetention(); retention > 0 {
+		count, err := s.Jobs.expireResults(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Error deleting expired jobs: %v", err)
+		} else if count > 0 {
+			log.Printf("Deleted %d jobs past their retention", count)
+		}
+	}
+
+	// Objects already scheduled are deleted even if retention was turned off since
+	locations, err := s.Jobs.expiredOutputs(ctx, time.Now())
+	if err != nil {
+		log.Printf("Error listing expired results: %v", err)
+		return
+	}
+	for _, location := range locations {
+		if s.Storage == nil {
+			log.Printf("Can't delete expired result %s without object storage", location)
+			return
+		}
+		if err := s.Storage.Delete(ctx, location); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Error deleting expired result %s: %v", location, err)
+			continue
+		}
+		if err := s.Jobs.forgetOutput(ctx, location); err != nil {
+			log.Printf("Error forgetting deleted result %s: %v", location, err)
+			continue
+		}
+		log.Printf("Deleted expired result %s", location)
+	}
+}
+
+// deleteDownloadedJob removes a job once its result was downloaded, with DELETE_AFTER_DOWNLOAD
+func (s *Service) deleteDownloadedJob(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+	if err := s.Jobs.delete(ctx, id); err != nil {
+		log.Printf("Error deleting downloaded job %s: %v", id, err)
+		return
+	}
+	log.Printf("Deleted job %s after its result was downloaded", id)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority controls the order in which queued transcriptions are admitted
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ParsePriority converts a request value into a Priority, defaulting to normal
+func ParsePriority(value string) (Priority, error) {
+	switch value {
+	case "":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return PriorityNormal, fmt.Errorf("invalid priority %q (expected high, normal or low)", value)
+	}
+}
+
+// waiter is a request queued for a transcription slot
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+	index    int
+}
+
+// waitQueue orders waiters by priority, then by arrival
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	w.index = -1
+	return w
+}
+
+// Scheduler limits concurrent transcriptions and admits queued requests by priority
+type Scheduler struct {
+	mu      sync.Mutex
+	slots   int
+	running int
+	seq     uint64
+	queue   waitQueue
+}
+
+// NewScheduler creates a scheduler allowing the given number of concurrent transcriptions
+func NewScheduler(slots int) *Scheduler {
+	if slots < 1 {
+		slots = 1
+	}
+	return &Scheduler{slots: slots}
+}
+
+// queueFullRetryAfter is the wait suggested to clients turned away by a full queue
+const queueFullRetryAfter = 10 * time.Second
+
+// errQueueFull is returned instead of queueing beyond MAX_QUEUED_JOBS
+var errQueueFull = &APIError{
+	Status:     http.StatusServiceUnavailable,
+	Code:       "queue_full",
+	Message:    "Too many transcriptions are waiting, try again later",
+	RetryAfter: queueFullRetryAfter,
+}
+
+// Acquire blocks until a slot is available or the context is done. It fails right away
+// with errQueueFull when MAX_QUEUED_JOBS requests are already waiting.
+func (s *Scheduler) Acquire(ctx context.Context, priority Priority) error {
+	s.mu.Lock()
+	if s.running < s.slots && len(s.queue) == 0 {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	if limit := currentConfig().MaxQueuedJobs; limit > 0 && len(s.queue) >= limit {
+		s.mu.Unlock()
+		return errQueueFull
+	}
+
+	s.seq++
+	w := &waiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	heap.Push(&s.queue, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.granted {
+			// The slot was handed over just as we gave up, pass it on
+			s.releaseLocked()
+		} else {
+			heap.Remove(&s.queue, w.index)
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes a slot only when one is free and nobody is waiting, without blocking
+func (s *Scheduler) TryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running < s.slots && len(s.queue) == 0 {
+		s.running++
+		return true
+	}
+	return false
+}
+
+// SetSlots changes the number of concurrent transcriptions, admitting waiters if it grew
+func (s *Scheduler) SetSlots(slots int) {
+	if slots < 1 {
+		slots = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots = slots
+	for s.running < s.slots && len(s.queue) > 0 {
+		s.running++
+		w := heap.Pop(&s.queue).(*waiter)
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// Stats returns the number of running and queued transcriptions
+func (s *Scheduler) Stats() (running, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running, len(s.queue)
+}
+
+// Release frees a slot, handing it to the highest-priority waiter if any
+func (s *Scheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *Scheduler) releaseLocked() {
+	// Shrinking the pool takes effect as running transcriptions finish
+	if len(s.queue) == 0 || s.running > s.slots {
+		s.running--
+		return
+	}
+	w := heap.Pop(&s.queue).(*waiter)
+	w.granted = true
+	close(w.ready)
+}
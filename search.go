@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes for search results
+const (
+	defaultSearchPageSize = 50
+	maxSearchPageSize     = 200
+)
+
+// handleSearch finds segments of stored transcripts containing the words of q, best matches
+// first, with the job and times of each so clients can jump to the audio
+func (s *Service) handleSearch(c *gin.Context) {
+	if s.Jobs.db == nil {
+		writeError(c, &APIError{Status: http.StatusServiceUnavailable, Code: "search_unavailable", Message: "Search needs a database (set DATABASE_URL)"})
+		return
+	}
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		writeError(c, newAPIError(http.StatusBadRequest, "q is required"))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchPageSize)))
+	if err != nil || limit < 1 || limit > maxSearchPageSize {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid limit %q (expected 1 to %d)", c.Query("limit"), maxSearchPageSize)))
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("invalid offset %q", c.Query("offset"))))
+		return
+	}
+
+	hits, err := s.Jobs.db.Search(c.Request.Context(), query, limit, offset)
+	if err != nil {
+		log.Printf("Error searching transcripts for %q: %v", query, err)
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to search transcripts"))
+		return
+	}
+
+	results := make([]gin.H, 0, len(hits))
+	for _, hit := range hits {
+		result := gin.H{
+			"job_id":     hit.JobID,
+			"filename":   hit.Filename,
+			"created_at": hit.CreatedAt,
+			"segment":    hit.Index,
+			"start_time": hit.Start,
+			"end_time":   hit.End,
+			"text":       hit.Text,
+			"result_url": jobURL(hit.JobID) + "/result",
+		}
+		if hit.Speaker != "" {
+			result["speaker"] = hit.Speaker
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{"query": query, "results": results, "limit": limit, "offset": offset})
+}
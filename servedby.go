@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// servedBy names this instance in responses and logs, empty unless INCLUDE_SERVED_BY is on
+var servedBy string
+
+// initServedBy reads INCLUDE_SERVED_BY and NODE_ID, falling back to the hostname for the
+// node name, and prefixes log lines with it
+func initServedBy() {
+	if enabled, _ := strconv.ParseBool(os.Getenv("INCLUDE_SERVED_BY")); !enabled {
+		return
+	}
+
+	servedBy = strings.TrimSpace(os.Getenv("NODE_ID"))
+	if servedBy == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Printf("Could not read hostname for served_by: %v", err)
+			hostname = "unknown"
+		}
+		servedBy = hostname
+	}
+	log.SetPrefix("[" + servedBy + "] ")
+}
+
+// servedByHeader tags every response with the X-Served-By header when enabled
+func servedByHeader() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if servedBy != "" {
+			c.Header("X-Served-By", servedBy)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// TranscriptStats summarizes the length of a transcript
+type TranscriptStats struct {
+	WordCount          int     `json:"word_count"`
+	ReadingTimeSeconds float64 `json:"reading_time_seconds"`
+	WordsPerMinute     int     `json:"words_per_minute"`
+}
+
+// computeStats counts words across segments and estimates reading time at the given pace
+func computeStats(segments []TranscriptionSegment, wordsPerMinute int) TranscriptStats {
+	words := 0
+	for _, segment := range segments {
+		words += len(strings.Fields(segment.Text))
+	}
+
+	stats := TranscriptStats{WordCount: words, WordsPerMinute: wordsPerMinute}
+	if wordsPerMinute > 0 {
+		seconds := float64(words) / float64(wordsPerMinute) * 60
+		stats.ReadingTimeSeconds = math.Round(seconds*10) / 10
+	}
+	return stats
+}
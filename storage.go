@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// tempDirAttempts is how often creating a work directory is tried before giving up,
+// since a full disk is often freed up moments later by a finishing request
+const tempDirAttempts = 3
+
+// checkWorkDir verifies at startup that the temp directory can hold uploads, so a
+// read-only or full disk fails loudly instead of on every request
+func checkWorkDir() error {
+	dir, err := os.MkdirTemp("", "startup-check")
+	if err != nil {
+		return fmt.Errorf("temp directory %s is not writable (set TMPDIR to a writable location): %w", os.TempDir(), err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "probe"), make([]byte, 4096), 0o600); err != nil {
+		return fmt.Errorf("cannot write to temp directory %s (check free space and permissions): %w", os.TempDir(), err)
+	}
+	return nil
+}
+
+// makeTempDir creates a per-request work directory, retrying briefly before reporting
+// the storage as unavailable
+func makeTempDir(pattern string) (string, error) {
+	var err error
+	for attempt := range tempDirAttempts {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		var dir string
+		if dir, err = os.MkdirTemp("", pattern); err == nil {
+			return dir, nil
+		}
+	}
+	return "", storageError(err)
+}
+
+// isStorageError reports whether an error comes from a full disk or missing permissions
+func isStorageError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, syscall.EROFS) || errors.Is(err, fs.ErrPermission)
+}
+
+// storageError logs a storage failure with what to check and returns the 503 sent to clients
+func storageError(err error) *APIError {
+	log.Printf("Server storage unavailable in %s: %v (check free space, permissions and TMPDIR)", os.TempDir(), err)
+	return &APIError{Status: http.StatusServiceUnavailable, Code: "storage_unavailable", Message: "Server storage unavailable, try again later"}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkResult is the outcome of transcribing one chunk of a streamed upload
+type chunkResult struct {
+	Chunk    int                    `json:"chunk"`
+	Offset   float64                `json:"offset_seconds"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// handleStreamTranscribe transcribes raw PCM audio while it is still being uploaded.
+// The request body is 16-bit little-endian PCM, typically sent with chunked transfer
+// encoding; every `chunk_seconds` of audio is transcribed as soon as it arrives and
+// results are streamed back as newline-delimited JSON in chunk order.
+func (s *Service) handleStreamTranscribe(c *gin.Context) {
+	startTime := time.Now()
+
+	sampleRate, err := strconv.Atoi(c.DefaultQuery("sample_rate", "16000"))
+	if err != nil || sampleRate <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid sample_rate"))
+		return
+	}
+	channels, err := strconv.Atoi(c.DefaultQuery("channels", "1"))
+	if err != nil || channels <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid channels"))
+		return
+	}
+	chunkSeconds, err := strconv.Atoi(c.DefaultQuery("chunk_seconds", strconv.Itoa(currentConfig().StreamChunkSeconds)))
+	if err != nil || chunkSeconds <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid chunk_seconds"))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	// Respond while the body is still being read, and let long recordings outlive the server timeouts
+	controller := http.NewResponseController(c.Writer)
+	if err := controller.EnableFullDuplex(); err != nil {
+		log.Printf("Full duplex unavailable, results will be sent after upload: %v", err)
+	}
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	tmpDir, err := makeTempDir("audio-stream")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	bytesPerSecond := sampleRate * channels * 2
+	chunkBytes := chunkSeconds * bytesPerSecond
+
+	// Chunks are transcribed concurrently; their result channels are queued in order
+	ordered := make(chan chan chunkResult, 64)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(ordered)
+		buffer := make([]byte, chunkBytes)
+		for chunk := 0; ; chunk++ {
+			n, err := io.ReadFull(c.Request.Body, buffer)
+			if n > 0 {
+				// Keep whole sample frames so the WAV stays aligned
+				n -= n % (channels * 2)
+				offset := float64(chunk*chunkBytes) / float64(bytesPerSecond)
+				select {
+				case ordered <- s.transcribeChunk(c, tmpDir, chunk, offset, buffer[:n], sampleRate, channels, opts):
+				case <-c.Request.Context().Done():
+					readErr <- c.Request.Context().Err()
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	var segments []TranscriptionSegment
+	encoder := json.NewEncoder(c.Writer)
+	for result := range ordered {
+		chunk := <-result
+		segments = append(segments, chunk.Segments...)
+		if err := encoder.Encode(chunk); err != nil {
+			log.Printf("Error writing stream result: %v", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+
+	summary := gin.H{
+		"done":                    true,
+		"text":                    joinSegmentText(segments),
+		"processing_time_seconds": time.Since(startTime).Seconds(),
+	}
+	if err := <-readErr; err != nil {
+		log.Printf("Error reading streamed upload: %v", err)
+		summary["error"] = fmt.Sprintf("Upload interrupted: %v", err)
+	}
+	_ = encoder.Encode(summary)
+	c.Writer.Flush()
+}
+
+// transcribeChunk writes one chunk of PCM to disk and transcribes it in the background
+func (s *Service) transcribeChunk(c *gin.Context, dir string, chunk int, offset float64, pcm []byte, sampleRate, channels int, opts TranscribeOptions) chan chunkResult {
+	result := make(chan chunkResult, 1)
+
+	chunkPath := filepath.Join(dir, fmt.Sprintf("chunk-%05d.wav", chunk))
+	if err := writeWAV(chunkPath, pcm, sampleRate, channels); err != nil {
+		result <- chunkResult{Chunk: chunk, Offset: offset, Error: "Failed to write audio chunk"}
+		return result
+	}
+
+	go func() {
+		response, err := s.transcribe(c.Request.Context(), chunkPath, opts)
+		if err != nil {
+			result <- chunkResult{Chunk: chunk, Offset: offset, Error: err.Error()}
+			return
+		}
+
+		// Shift chunk-relative timestamps onto the timeline of the whole upload
+		for i := range response.Segments {
+			response.Segments[i].StartTime += offset
+			response.Segments[i].EndTime += offset
+		}
+		result <- chunkResult{Chunk: chunk, Offset: offset, Segments: response.Segments}
+	}()
+
+	return result
+}
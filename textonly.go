@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// textOnlyResponse falls back to a text-only result when TEXT_ONLY_FALLBACK is on
+func textOnlyResponse(data []byte) (TranscriptionResponse, bool) {
+	if !currentConfig().TextOnlyFallback {
+		return TranscriptionResponse{}, false
+	}
+	response, ok := textOnlyOutput(data)
+	if ok {
+		log.Printf("Timestamps unavailable in transcription output, returning %d characters of text only", len(response.Text))
+	}
+	return response, ok
+}
+
+// textOnlyOutput salvages the transcript text from bridge output whose segments can't be read
+// with timestamps: a JSON document with a top-level text field or segment texts in an
+// unexpected shape, or plain text. It reports false when no text could be found.
+func textOnlyOutput(data []byte) (TranscriptionResponse, bool) {
+	var response TranscriptionResponse
+	trimmed := bytes.TrimSpace(data)
+
+	var loose struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+		Segments []struct {
+			Text string `json:"text"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(trimmed, &loose); err == nil {
+		response.Language = loose.Language
+		response.Text = strings.TrimSpace(loose.Text)
+		if response.Text == "" {
+			parts := make([]string, 0, len(loose.Segments))
+			for _, segment := range loose.Segments {
+				if text := strings.TrimSpace(segment.Text); text != "" {
+					parts = append(parts, text)
+				}
+			}
+			response.Text = strings.Join(parts, " ")
+		}
+	} else if len(trimmed) > 0 && trimmed[0] != '{' && trimmed[0] != '[' && utf8.Valid(trimmed) {
+		// Not JSON at all, so the bridge wrote the transcript as it is
+		response.Text = strings.Join(strings.Fields(string(trimmed)), " ")
+	}
+
+	if response.Text == "" {
+		return TranscriptionResponse{}, false
+	}
+	response.Segments = []TranscriptionSegment{}
+	response.TimestampsUnavailable = true
+	response.Warnings = append(response.Warnings, "Timestamps could not be read from the transcription output; only the text is returned")
+	return response, true
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"transription-service/internal/audio"
+	"transription-service/internal/formats"
+	"transription-service/internal/storage"
+	"transription-service/internal/transcriber"
+)
+
+// TranscribeOptions controls how an audio file is transcribed and how the result is shaped
+type TranscribeOptions struct {
+	Model              string
+	Engine             string // remote engine to use instead of the configured one, empty for the configured one
+	Priority           Priority
+	Punctuate          bool
+	Diarize            bool
+	SplitSpeakers      bool
+	SpeakerSRT         bool // also render each speaker's track as SRT when splitting speakers
+	SegmentLanguage    bool
+	Languages          []string // candidate languages to choose between
+	FallbackLanguage   string   // forced on a retry when auto-detection scores below FallbackConfidence
+	FallbackConfidence float64
+	EstimateSpeakers   bool
+	Channel            string // all, left, right or a channel number to transcribe separately
+	AudioStream        int    // audio stream to extract from video files, negative for the default
+	PadStart           float64
+	Tail               float64 // only transcribe the final seconds when set
+	PreviewSeconds     float64 // only transcribe the first seconds when set
+	TrimSilence        bool    // cut leading and trailing silence before transcribing
+	VAD                bool    // cut long silences found by voice activity detection before transcribing
+	SampleRate         int     // set to add per-segment sample offsets
+	KeepAlive          bool
+	FormattedTimes     bool
+	Timeout            time.Duration // per transcription, already clamped; zero uses the server default
+	DialogueTimestamps bool
+	Task               string // translate to have Whisper output English, empty to transcribe
+	TranslateTo        string // target language for the optional translation step
+	Filename           string // original upload name, used for filename metadata
+	Search             *transcriber.SegmentFilter
+	PostProcess        []string
+	BucketSeconds      float64 // also group JSON segments into timeline buckets of this size when set
+	MaxSegmentDuration float64 // split longer segments at word boundaries when set
+	NBest              int     // candidate texts per segment, more than one adds alternatives
+	Script             string  // simplified or traditional to convert Chinese output, empty to keep it
+	WaveformResolution int     // peaks per second to return alongside the transcript, 0 for none
+	Redact             bool    // replace PII in the output text with placeholders
+	Precision          int     // decimal places for output timestamps, negative for full precision
+	Download           bool    // let the bridge download a missing model (warmup only)
+	NoCache            bool    // always run the backend, bypassing the result cache
+	Format             string
+	Wrap               formats.WrapOptions
+	VTTSettings        formats.VTTSettings
+	Output             *storage.Location // prefix results are written under instead of being returned, when set
+	CallbackURL        string            // notified when an asynchronous job finishes
+}
+
+// maxNBest bounds n_best, since every extra candidate is another decoding pass per segment
+const maxNBest = 5
+
+// preprocessAudio applies the requested ffmpeg steps before transcription. It returns
+// the path to transcribe and the offset to add to its timestamps to get back to the
+// original timeline.
+func preprocessAudio(ctx context.Context, audioPath string, opts TranscribeOptions) (string, float64, error) {
+	shift := 0.0
+
+	audioPath, err := transcodeAudio(ctx, audioPath, opts)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Previews stop after the first seconds; the timeline is unchanged
+	if opts.PreviewSeconds > 0 {
+		previewPath := audioPath + ".preview.wav"
+		if err := audio.Clip(ctx, audioPath, previewPath, 0, opts.PreviewSeconds); err != nil {
+			log.Printf("Error clipping audio: %v", err)
+			return "", 0, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to preprocess audio", Details: err.Error()}
+		}
+		audioPath = previewPath
+	}
+
+	// Keep only the end of the recording, remembering where it started
+	if opts.Tail > 0 {
+		duration, err := audio.Duration(ctx, audioPath)
+		if err != nil {
+			log.Printf("Error probing audio duration: %v", err)
+			return "", 0, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to read audio duration", Details: err.Error()}
+		}
+
+		start := max(0, duration-opts.Tail)
+		tailPath := audioPath + ".tail.wav"
+		if err := audio.Clip(ctx, audioPath, tailPath, start, opts.Tail); err != nil {
+			log.Printf("Error clipping audio: %v", err)
+			return "", 0, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to preprocess audio", Details: err.Error()}
+		}
+		audioPath = tailPath
+		shift += start
+	}
+
+	// Long silent stretches at either end are cut so whisper doesn't spend time on them
+	if opts.TrimSilence {
+		cfg := currentConfig()
+		trimmedPath := audioPath + ".trimmed.wav"
+		cut, err := audio.TrimSilence(ctx, audioPath, trimmedPath, cfg.TrimSilenceThresholdDB, cfg.TrimSilenceKeepSeconds)
+		if err != nil {
+			log.Printf("Error trimming silence: %v", err)
+			return "", 0, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to preprocess audio", Details: err.Error()}
+		}
+		audioPath = trimmedPath
+		shift += cut
+	}
+
+	// Leading silence keeps whisper from dropping the first words of short clips
+	if opts.PadStart > 0 {
+		paddedPath := audioPath + ".padded.wav"
+		if err := audio.PadStart(ctx, audioPath, paddedPath, opts.PadStart); err != nil {
+			log.Printf("Error padding audio: %v", err)
+			return "", 0, &APIError{Status: http.StatusUnprocessableEntity, Message: "Failed to preprocess audio", Details: err.Error()}
+		}
+		audioPath = paddedPath
+		shift -= opts.PadStart
+	}
+
+	return audioPath, shift, nil
+}
+
+// transcodeAudio probes an upload with ffprobe and converts it to the 16kHz mono WAV Whisper
+// reads, so formats its own loader handles badly don't fail deep in the engine and corrupt
+// files are refused with a clear error. Remote engines get audio uploads as they are, since
+// their APIs decode them and charge or limit by size; video is always reduced to its audio.
+func transcodeAudio(ctx context.Context, audioPath string, opts TranscribeOptions) (string, error) {
+	info, err := audio.Probe(ctx, audioPath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Printf("Error probing upload: %v", err)
+		return "", &APIError{Status: http.StatusUnprocessableEntity, Code: "unreadable_audio", Message: "Could not read audio; the file is corrupt or not a media format ffmpeg supports", Details: err.Error()}
+	}
+	if info.AudioStreams == 0 {
+		return "", &APIError{Status: http.StatusUnprocessableEntity, Code: "unreadable_audio", Message: "File has no audio stream"}
+	}
+	if opts.AudioStream >= info.AudioStreams {
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("audio_stream %d not found (the file has %d)", opts.AudioStream, info.AudioStreams))
+	}
+
+	video := isVideoFile(audioPath)
+	if !video && (info.IsWhisperWAV() || currentConfig().IsRemoteEngine(opts.Engine)) {
+		return audioPath, nil
+	}
+
+	transcodedPath := audioPath + ".audio.wav"
+	if err := audio.ExtractAudio(ctx, audioPath, transcodedPath, opts.AudioStream); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Printf("Error transcoding %s audio: %v", info.Format, err)
+		return "", &APIError{Status: http.StatusUnprocessableEntity, Code: "unreadable_audio", Message: "Failed to decode audio; the file may be corrupt", Details: err.Error()}
+	}
+	// Extracted audio has to fit the audio size limit
+	if info, err := os.Stat(transcodedPath); video && err == nil && info.Size() > currentConfig().MaxUploadBytes() {
+		return "", &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Extracted audio too large (max %dMB)", currentConfig().MaxUploadMB)}
+	}
+	return transcodedPath, nil
+}
+
+// shiftSegments moves segment timestamps by the given offset, clamping at zero
+func shiftSegments(segments []TranscriptionSegment, shift float64) {
+	if shift == 0 {
+		return
+	}
+	for i := range segments {
+		segments[i].StartTime = max(0, segments[i].StartTime+shift)
+		segments[i].EndTime = max(0, segments[i].EndTime+shift)
+	}
+}
+
+// bridgeScriptPath returns the path to whisper_bridge.py in the working directory
+func bridgeScriptPath() (string, error) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(currentDir, "whisper_bridge.py"), nil
+}
+
+// effectiveTimeout is the time limit for one bridge run of the request
+func effectiveTimeout(opts TranscribeOptions) time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return currentConfig().TranscriptionTimeout()
+}
+
+// engineRequest describes a transcription of the file for the configured engine
+func engineRequest(ctx context.Context, audioPath string, opts TranscribeOptions) transcriber.Request {
+	return transcriber.Request{
+		AudioPath: audioPath,
+		Model:     opts.Model,
+		Task:      opts.Task,
+		Languages: opts.Languages,
+		Punctuate: opts.Punctuate,
+		// Splitting by speaker needs diarization labels
+		Diarize:          opts.Diarize || opts.SplitSpeakers,
+		SegmentLanguage:  opts.SegmentLanguage,
+		EstimateSpeakers: opts.EstimateSpeakers,
+		NBest:            opts.NBest,
+		Script:           opts.Script,
+		Progress:         progressListener(ctx),
+	}
+}
+
+// runTranscription runs the configured engine on an audio file and checks its output
+func runTranscription(ctx context.Context, audioPath string, opts TranscribeOptions) (TranscriptionResponse, error) {
+	startTime := time.Now()
+
+	engine, err := currentConfig().NewEngine(opts.Engine)
+	if err != nil {
+		log.Printf("Error creating transcription engine: %v", err)
+		return TranscriptionResponse{}, &APIError{Status: http.StatusInternalServerError, Message: "Failed to start the transcription engine", Details: err.Error()}
+	}
+
+	// Set a timeout context for processing
+	timeout := effectiveTimeout(opts)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Running transcription with model: %s", opts.Model)
+	result, err := engine.Transcribe(ctx, engineRequest(ctx, audioPath, opts))
+
+	// Handle different error cases
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("Transcription timed out after %v", time.Since(startTime))
+		return TranscriptionResponse{}, &APIError{
+			Status:  http.StatusRequestTimeout,
+			Code:    "transcription_timeout",
+			Message: fmt.Sprintf("Transcription timed out (%v limit)", timeout),
+		}
+	}
+	// A canceled run, like the other chunks of a long file after one failed, says nothing about the backend
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return TranscriptionResponse{}, ctx.Err()
+	}
+
+	var processErr *transcriber.ProcessError
+	var outputErr *transcriber.OutputError
+	switch {
+	case errors.As(err, &processErr):
+		log.Printf("Transcription error after %v: %v", time.Since(startTime), err)
+		log.Printf("Command output: %s", processErr.Output)
+		return TranscriptionResponse{}, &APIError{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("Transcription failed: %v", err),
+			Output:  processErr.Output,
+		}
+
+	case errors.As(err, &outputErr):
+		log.Printf("Error parsing JSON: %v", outputErr.Err)
+
+		// An engine that crashed mid-write may still have left complete segments behind
+		if currentConfig().RecoverPartialOutput {
+			if partial, ok := recoverPartialOutput(outputErr.Data); ok {
+				log.Printf("Recovered %d segments from partial transcription output", len(partial.Segments))
+				partial.Partial = true
+				partial.Warnings = append(partial.Warnings, "Transcription output was incomplete; only the segments before the cut-off are returned")
+				return partial, nil
+			}
+		}
+		if textOnly, ok := textOnlyResponse(outputErr.Data); ok {
+			return textOnly, nil
+		}
+
+		return TranscriptionResponse{}, &APIError{
+			Status:  http.StatusInternalServerError,
+			Message: "Failed to parse transcription output",
+			Details: outputErr.Err.Error(),
+		}
+
+	case err != nil:
+		log.Printf("Transcription error after %v: %v", time.Since(startTime), err)
+		return TranscriptionResponse{}, &APIError{
+			Status:  http.StatusInternalServerError,
+			Message: "Transcription failed",
+			Details: err.Error(),
+		}
+	}
+
+	response := TranscriptionResponse{
+		Error:             result.Error,
+		Segments:          result.Segments,
+		Warnings:          result.Warnings,
+		Language:          languageCode(result.Language),
+		EstimatedSpeakers: result.EstimatedSpeakers,
+		Engine:            opts.Engine,
+	}
+	// Output with a changed shape may parse yet carry no timestamped segments
+	if len(response.Segments) == 0 && response.Error == "" {
+		if textOnly, ok := textOnlyResponse(result.Output); ok {
+			return textOnly, nil
+		}
+	}
+
+	// An engine that reports an error failed, whatever placeholder segments it wrote
+	if response.Error != "" {
+		log.Printf("Error from transcription service: %s", response.Error)
+		return TranscriptionResponse{}, &APIError{
+			Status:  http.StatusInternalServerError,
+			Message: "Transcription failed: " + response.Error,
+			Output:  string(result.Output),
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// Translation is a transcription carried over into another language, segment by segment
+type Translation struct {
+	Language string
+	Segments []TranscriptionSegment
+}
+
+// translateResponse runs the transcribed segments through the configured translation backend,
+// keeping their timestamps and speakers
+func translateResponse(ctx context.Context, response TranscriptionResponse, target string) (*Translation, error) {
+	translator, err := currentConfig().NewTranslator()
+	if err != nil || translator == nil {
+		return nil, &APIError{Status: http.StatusServiceUnavailable, Code: "translation_unavailable", Message: "Translation is not enabled on this server"}
+	}
+
+	texts := make([]string, len(response.Segments))
+	for i, segment := range response.Segments {
+		texts[i] = segment.Text
+	}
+
+	translated := texts
+	if len(texts) > 0 && response.Language != target {
+		if translated, err = translator.Translate(ctx, texts, response.Language, target); err != nil {
+			log.Printf("Translation to %s failed: %v", target, err)
+			return nil, &APIError{Status: http.StatusBadGateway, Code: "translation_failed", Message: "Translation failed", Details: err.Error()}
+		}
+	}
+
+	segments := make([]TranscriptionSegment, len(response.Segments))
+	for i, segment := range response.Segments {
+		segment.Text = translated[i]
+		segment.Language = target
+		segments[i] = segment
+	}
+	return &Translation{Language: target, Segments: segments}, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentRangePattern matches "bytes start-end/total" Content-Range headers
+var contentRangePattern = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// upload is a file being assembled from chunks
+type upload struct {
+	mu        sync.Mutex
+	id        string
+	dir       string
+	path      string
+	filename  string
+	size      int64
+	offset    int64
+	updatedAt time.Time
+}
+
+// UploadStore keeps track of resumable uploads in a working directory
+type UploadStore struct {
+	mu      sync.Mutex
+	dir     string
+	uploads map[string]*upload
+}
+
+// NewUploadStore creates a store that assembles uploads under dir
+func NewUploadStore(dir string) (*UploadStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &UploadStore{dir: dir, uploads: make(map[string]*upload)}, nil
+}
+
+// Create starts a new upload of the given size
+func (s *UploadStore) Create(filename string, size int64) (*upload, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	dir := filepath.Join(s.dir, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	u := &upload{
+		id:        id,
+		dir:       dir,
+		path:      filepath.Join(dir, filepath.Base(filename)),
+		filename:  filename,
+		size:      size,
+		updatedAt: time.Now(),
+	}
+	if err := os.WriteFile(u.path, nil, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.uploads[id] = u
+	s.mu.Unlock()
+	return u, nil
+}
+
+// Get returns an upload by ID
+func (s *UploadStore) Get(id string) (*upload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[id]
+	return u, ok
+}
+
+// Remove deletes an upload and its data
+func (s *UploadStore) Remove(id string) {
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	if ok {
+		os.RemoveAll(u.dir)
+	}
+}
+
+// RemoveStale deletes uploads that have not received data within the TTL
+func (s *UploadStore) RemoveStale(ttl time.Duration) {
+	s.mu.Lock()
+	var stale []string
+	for id, u := range s.uploads {
+		u.mu.Lock()
+		if time.Since(u.updatedAt) > ttl {
+			stale = append(stale, id)
+		}
+		u.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	for _, id := range stale {
+		log.Printf("Removing stale upload %s", id)
+		s.Remove(id)
+	}
+}
+
+// errRangeMismatch is returned when a chunk does not continue where the upload left off
+var errRangeMismatch = errors.New("chunk does not start at the current upload offset")
+
+// writeChunk appends a chunk that must start at the current offset
+func (u *upload) writeChunk(start int64, body io.Reader, length int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if start != u.offset {
+		return errRangeMismatch
+	}
+
+	file, err := os.OpenFile(u.path, os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	written, err := io.CopyN(file, body, length)
+	if err != nil {
+		// Keep whatever arrived so the client can resume from there
+		u.offset += written
+		u.updatedAt = time.Now()
+		file.Truncate(u.offset)
+		return err
+	}
+
+	u.offset += written
+	u.updatedAt = time.Now()
+	return file.Close()
+}
+
+// status describes the upload for clients deciding where to resume
+func (u *upload) status() gin.H {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return gin.H{
+		"upload_id": u.id,
+		"filename":  u.filename,
+		"size":      u.size,
+		"offset":    u.offset,
+		"complete":  u.offset == u.size,
+	}
+}
+
+// handleCreateUpload starts a resumable upload
+func (s *Service) handleCreateUpload(c *gin.Context) {
+	var request struct {
+		Filename string `json:"filename" binding:"required"`
+		Size     int64  `json:"size" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "filename and size are required"))
+		return
+	}
+
+	if request.Size <= 0 || exceedsUploadLimit(request.Filename, request.Size) {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", uploadLimitMB(request.Filename))))
+		return
+	}
+	if !isSupportedAudioFile(request.Filename) {
+		writeError(c, newAPIError(http.StatusBadRequest, fmt.Sprintf("unsupported file type %q", filepath.Ext(request.Filename))))
+		return
+	}
+
+	u, err := s.Uploads.Create(request.Filename, request.Size)
+	if err != nil {
+		log.Printf("Error creating upload: %v", err)
+		writeError(c, newAPIError(http.StatusInternalServerError, "Failed to create upload"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, u.status())
+}
+
+// handleUploadStatus reports how much of an upload has been received
+func (s *Service) handleUploadStatus(c *gin.Context) {
+	u, ok := s.Uploads.Get(c.Param("id"))
+	if !ok {
+		writeError(c, newAPIError(http.StatusNotFound, "Upload not found"))
+		return
+	}
+
+	status := u.status()
+	c.Header("Upload-Offset", strconv.FormatInt(status["offset"].(int64), 10))
+	c.JSON(http.StatusOK, status)
+}
+
+// handleUploadChunk stores a chunk sent with a Content-Range header
+func (s *Service) handleUploadChunk(c *gin.Context) {
+	u, ok := s.Uploads.Get(c.Param("id"))
+	if !ok {
+		writeError(c, newAPIError(http.StatusNotFound, "Upload not found"))
+		return
+	}
+
+	matches := contentRangePattern.FindStringSubmatch(c.GetHeader("Content-Range"))
+	if matches == nil {
+		writeError(c, newAPIError(http.StatusBadRequest, "Content-Range header must be 'bytes start-end/total'"))
+		return
+	}
+	start, _ := strconv.ParseInt(matches[1], 10, 64)
+	end, _ := strconv.ParseInt(matches[2], 10, 64)
+	total, _ := strconv.ParseInt(matches[3], 10, 64)
+	if total != u.size || end < start || end >= total {
+		writeError(c, newAPIError(http.StatusRequestedRangeNotSatisfiable, "Content-Range does not fit the upload"))
+		return
+	}
+
+	if err := u.writeChunk(start, c.Request.Body, end-start+1); err != nil {
+		status := u.status()
+		if errors.Is(err, errRangeMismatch) {
+			writeError(c, &APIError{Status: http.StatusConflict, Message: err.Error(), Fields: gin.H{"offset": status["offset"]}})
+			return
+		}
+		log.Printf("Error writing upload chunk: %v", err)
+		writeError(c, &APIError{Status: http.StatusBadRequest, Message: "Chunk incomplete, resume from offset", Fields: gin.H{"offset": status["offset"]}})
+		return
+	}
+
+	c.JSON(http.StatusOK, u.status())
+}
+
+// handleTranscribeUpload transcribes a fully assembled upload and discards it
+func (s *Service) handleTranscribeUpload(c *gin.Context) {
+	startTime := time.Now()
+
+	u, ok := s.Uploads.Get(c.Param("id"))
+	if !ok {
+		writeError(c, newAPIError(http.StatusNotFound, "Upload not found"))
+		return
+	}
+	if status := u.status(); !status["complete"].(bool) {
+		writeError(c, &APIError{Status: http.StatusConflict, Message: "Upload is not complete", Fields: gin.H{"offset": status["offset"]}})
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	opts.Filename = u.filename
+
+	response, err := s.transcribe(c.Request.Context(), u.path, opts)
+	if err != nil {
+		if clientGone(c) {
+			return
+		}
+		writeError(c, err)
+		return
+	}
+	s.Uploads.Remove(u.id)
+
+	duration := time.Since(startTime)
+	log.Printf("Transcription of upload %s completed in %v with %d segments", u.id, duration, len(response.Segments))
+	s.deliver(c, response, duration, opts)
+}
+
+// runUploadJanitor periodically removes abandoned uploads
+func (s *Service) runUploadJanitor(ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 4)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.Uploads.RemoveStale(ttl)
+	}
+}
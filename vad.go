@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"transription-service/internal/audio"
+)
+
+// speechDetection is the bridge's voice activity detection result
+type speechDetection struct {
+	Speech   [][2]float64 `json:"speech"` // start and end of each stretch of speech, in seconds
+	Duration float64      `json:"duration"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// speechSpan is a stretch of the original audio kept in the audio with silences cut out
+type speechSpan struct {
+	cut, orig float64 // where the span starts in the cut audio and in the original, in seconds
+	length    float64
+}
+
+// speechMap places timestamps of audio with silences cut out back on the original timeline.
+// A nil map leaves them as they are.
+type speechMap []speechSpan
+
+// original returns where t in the cut audio was in the original. A time on a join between
+// two spans is placed at the end of the earlier span when end is set, so segments don't
+// stretch over the silence that was cut.
+func (m speechMap) original(t float64, end bool) float64 {
+	if len(m) == 0 {
+		return t
+	}
+	i := sort.Search(len(m), func(i int) bool {
+		if end {
+			return m[i].cut >= t
+		}
+		return m[i].cut > t
+	})
+	span := m[max(i-1, 0)]
+	return math.Round((span.orig+min(max(t-span.cut, 0), span.length))*1000) / 1000
+}
+
+// remap moves segment timestamps back onto the original timeline
+func (m speechMap) remap(segments []TranscriptionSegment) {
+	if len(m) == 0 {
+		return
+	}
+	for i := range segments {
+		segments[i].StartTime = m.original(segments[i].StartTime, false)
+		segments[i].EndTime = m.original(segments[i].EndTime, true)
+	}
+}
+
+// progressContext moves reported segments back onto the original timeline
+func (m speechMap) progressContext(ctx context.Context) context.Context {
+	fn := progressListener(ctx)
+	if fn == nil || len(m) == 0 {
+		return ctx
+	}
+	return withProgress(ctx, func(event ProgressEvent) {
+		if event.Segment != nil {
+			remapped := []TranscriptionSegment{*event.Segment}
+			m.remap(remapped)
+			event.Segment = &remapped[0]
+		}
+		fn(event)
+	})
+}
+
+// runSpeechDetection runs only the bridge's voice activity detection pass on the audio
+func runSpeechDetection(ctx context.Context, audioPath string, aggressiveness int) (speechDetection, error) {
+	outputPath := audioPath + ".vad.json"
+	defer os.Remove(outputPath)
+
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().TranscriptionTimeout())
+	defer cancel()
+
+	output, err := runBridge(ctx, "--input", audioPath, "--output", outputPath, "--vad", "--vad-aggressiveness", strconv.Itoa(aggressiveness))
+	if ctx.Err() != nil {
+		return speechDetection{}, ctx.Err()
+	}
+	data, readErr := os.ReadFile(outputPath)
+	if readErr != nil {
+		if err != nil {
+			return speechDetection{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return speechDetection{}, readErr
+	}
+	var detection speechDetection
+	if err := json.Unmarshal(data, &detection); err != nil {
+		return speechDetection{}, fmt.Errorf("parsing voice activity detection output: %w", err)
+	}
+	if detection.Error != "" {
+		return speechDetection{}, fmt.Errorf("%s", detection.Error)
+	}
+	return detection, nil
+}
+
+// keptSpans turns detected speech into the spans of audio to keep: each stretch is padded,
+// and silences shorter than minSilence, including at either end, are kept. Spans are rounded
+// out to the 10ms frames audio.KeepSpans works in.
+func keptSpans(speech [][2]float64, duration, padding, minSilence float64) [][2]float64 {
+	var spans [][2]float64
+	for _, stretch := range speech {
+		start := math.Floor(max(0, stretch[0]-padding)*100) / 100
+		end := math.Ceil(min(duration, stretch[1]+padding)*100) / 100
+		if start < minSilence {
+			start = 0
+		}
+		if n := len(spans); n > 0 && start-spans[n-1][1] < minSilence {
+			spans[n-1][1] = max(spans[n-1][1], end)
+			continue
+		}
+		spans = append(spans, [2]float64{start, end})
+	}
+	if n := len(spans); n > 0 && duration-spans[n-1][1] < minSilence {
+		spans[n-1][1] = math.Ceil(duration*100) / 100
+	}
+	return spans
+}
+
+// removeSilence cuts the long silences voice activity detection finds out of the audio,
+// returning the cut audio and the map back to the original timeline. When detection fails
+// or finds nothing to cut, the audio is transcribed whole and a warning may say why.
+func removeSilence(ctx context.Context, audioPath string) (string, speechMap, string, error) {
+	cfg := currentConfig()
+	reportStage(ctx, "vad")
+	detection, err := runSpeechDetection(ctx, audioPath, cfg.VADAggressiveness)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", nil, "", ctx.Err()
+		}
+		log.Printf("Voice activity detection failed: %v", err)
+		return audioPath, nil, "Voice activity detection skipped: " + err.Error(), nil
+	}
+
+	spans := keptSpans(detection.Speech, detection.Duration, cfg.VADPaddingSeconds, cfg.VADMinSilenceSeconds)
+	if len(spans) == 0 {
+		return audioPath, nil, "Voice activity detection found no speech; the whole audio was transcribed", nil
+	}
+	var kept float64
+	speech := make(speechMap, len(spans))
+	for i, span := range spans {
+		speech[i] = speechSpan{cut: kept, orig: span[0], length: span[1] - span[0]}
+		kept += span[1] - span[0]
+	}
+	if len(spans) == 1 && spans[0][0] == 0 && kept >= detection.Duration {
+		return audioPath, nil, "", nil
+	}
+
+	cutPath := audioPath + ".speech.wav"
+	if err := audio.KeepSpans(ctx, audioPath, cutPath, spans); err != nil {
+		if ctx.Err() != nil {
+			return "", nil, "", ctx.Err()
+		}
+		log.Printf("Error cutting silences: %v", err)
+		return audioPath, nil, "Voice activity detection skipped: " + err.Error(), nil
+	}
+	log.Printf("Voice activity detection kept %.0f of %.0f seconds of audio in %d stretches", kept, detection.Duration, len(spans))
+	return cutPath, speech, "", nil
+}
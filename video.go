@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// videoExtensions lists the containers whose audio track is extracted before transcription
+var videoExtensions = []string{".mp4", ".m4v", ".mkv", ".mov", ".avi", ".webm"}
+
+// isVideoFile reports whether the filename has a video container extension
+func isVideoFile(filename string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(filename)))
+}
+
+// uploadLimitMB returns the size limit for an upload. Video files get MAX_VIDEO_UPLOAD_MB,
+// since it is their extracted audio that has to fit in MAX_UPLOAD_MB.
+func uploadLimitMB(filename string) int {
+	cfg := currentConfig()
+	if isVideoFile(filename) {
+		return cfg.MaxVideoUploadMB
+	}
+	return cfg.MaxUploadMB
+}
+
+// exceedsUploadLimit reports whether an upload is larger than its limit
+func exceedsUploadLimit(filename string, size int64) bool {
+	return size > int64(uploadLimitMB(filename))*1024*1024
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// warmupSampleRate is the sample rate of the generated warmup clip
+const warmupSampleRate = 16000
+
+// Warmer tracks which models have already been loaded once
+type Warmer struct {
+	mu   sync.Mutex
+	warm map[string]bool
+}
+
+// NewWarmer creates a warmer with no warm models
+func NewWarmer() *Warmer {
+	return &Warmer{warm: make(map[string]bool)}
+}
+
+// IsWarm reports whether the model has completed a warmup run
+func (w *Warmer) IsWarm(model string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.warm[model]
+}
+
+// markWarm records that the model has been loaded
+func (w *Warmer) markWarm(model string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warm[model] = true
+}
+
+// forget marks a model cold again, after its checkpoint was removed
+func (w *Warmer) forget(model string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.warm, model)
+}
+
+// warmup transcribes a short silent clip so the model is downloaded and loaded
+func (s *Service) warmup(ctx context.Context, model string, priority Priority) error {
+	tmpDir, err := makeTempDir("warmup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	clipPath := filepath.Join(tmpDir, "silence.wav")
+	if err := writeSilentWAV(clipPath, time.Second); err != nil {
+		return err
+	}
+
+	if _, err := s.transcribe(ctx, clipPath, TranscribeOptions{Model: model, Priority: priority, Download: true, NoCache: true}); err != nil {
+		return err
+	}
+
+	s.Warmer.markWarm(model)
+	return nil
+}
+
+// runWarmupSchedule periodically re-warms the configured model until the context ends.
+// The interval is re-read from the config on each cycle; zero disables warming.
+func (s *Service) runWarmupSchedule(ctx context.Context) {
+	for {
+		interval := currentConfig().WarmupInterval()
+		wait := interval
+		if wait <= 0 {
+			// Disabled for now, check again later in case the config is reloaded
+			wait = time.Minute
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if interval <= 0 {
+			continue
+		}
+
+		model := getModelName()
+		startTime := time.Now()
+		if err := s.warmup(ctx, model, PriorityLow); err != nil {
+			log.Printf("Scheduled warmup failed for model %s: %v", model, err)
+			continue
+		}
+		log.Printf("Scheduled warmup of model %s took %v", model, time.Since(startTime))
+	}
+}
+
+// handleWarmup preloads the configured model unless it is already warm
+func (s *Service) handleWarmup(c *gin.Context) {
+	model := getModelName()
+	if s.Warmer.IsWarm(model) {
+		c.JSON(http.StatusOK, gin.H{"status": "warm", "model": model, "already_warm": true})
+		return
+	}
+
+	startTime := time.Now()
+	if err := s.warmup(c.Request.Context(), model, PriorityNormal); err != nil {
+		log.Printf("Warmup failed for model %s: %v", model, err)
+		status, body := errorBody(err)
+		c.JSON(status, body)
+		return
+	}
+
+	log.Printf("Warmed up model %s in %v", model, time.Since(startTime))
+	c.JSON(http.StatusOK, gin.H{
+		"status":           "warm",
+		"model":            model,
+		"already_warm":     false,
+		"duration_seconds": time.Since(startTime).Seconds(),
+	})
+}
+
+// writeSilentWAV writes a 16-bit mono PCM WAV file containing only silence
+func writeSilentWAV(path string, duration time.Duration) error {
+	samples := int(duration.Seconds() * warmupSampleRate)
+	return writeWAV(path, make([]byte, samples*2), warmupSampleRate, 1)
+}
+
+// writeWAV wraps raw 16-bit PCM data in a WAV container
+func writeWAV(path string, pcm []byte, sampleRate, channels int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := struct {
+		ChunkID       [4]byte
+		ChunkSize     uint32
+		Format        [4]byte
+		Subchunk1ID   [4]byte
+		Subchunk1Size uint32
+		AudioFormat   uint16
+		NumChannels   uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Subchunk2ID   [4]byte
+		Subchunk2Size uint32
+	}{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     uint32(36 + len(pcm)),
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   1,
+		NumChannels:   uint16(channels),
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate * channels * 2),
+		BlockAlign:    uint16(channels * 2),
+		BitsPerSample: 16,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: uint32(len(pcm)),
+	}
+
+	if err := binary.Write(file, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := file.Write(pcm); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"math"
+
+	"transription-service/internal/audio"
+)
+
+// maxWaveformResolution bounds waveform_resolution, in peaks per second
+const maxWaveformResolution = 100
+
+// Waveform holds amplitude peaks for drawing the audio next to its transcript
+type Waveform struct {
+	PeaksPerSecond int       `json:"peaks_per_second"`
+	Peaks          []float64 `json:"peaks"`
+}
+
+// computeWaveform reads the peaks of an audio file at the given resolution, rounded to
+// three decimals to keep responses small
+func computeWaveform(ctx context.Context, audioPath string, perSecond int) (*Waveform, error) {
+	peaks, err := audio.Peaks(ctx, audioPath, perSecond)
+	if err != nil {
+		return nil, err
+	}
+	for i, peak := range peaks {
+		peaks[i] = math.Round(peak*1000) / 1000
+	}
+	return &Waveform{PeaksPerSecond: perSecond, Peaks: peaks}, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallbackStatus is where the delivery of a job's callback stands
+type CallbackStatus string
+
+// Callback statuses; a callback is pending until the job finishes
+const (
+	CallbackPending    CallbackStatus = "pending"
+	CallbackDelivering CallbackStatus = "delivering"
+	CallbackDelivered  CallbackStatus = "delivered"
+	CallbackFailed     CallbackStatus = "failed"
+)
+
+// callbackDelivery records how delivering a job's callback went
+type callbackDelivery struct {
+	status      CallbackStatus
+	attempts    int
+	lastError   string
+	deliveredAt time.Time
+}
+
+// body describes the delivery in job status responses
+func (d callbackDelivery) body(url string) gin.H {
+	body := gin.H{"url": url, "status": d.status, "attempts": d.attempts}
+	if d.lastError != "" {
+		body["last_error"] = d.lastError
+	}
+	if !d.deliveredAt.IsZero() {
+		body["delivered_at"] = d.deliveredAt
+	}
+	return body
+}
+
+// callbackPayload is what a finished job's callback receives: its status with the result
+// in JSON form, or with the error when it failed
+func (j *Job) callbackPayload() ([]byte, error) {
+	body := j.statusBody()
+	delete(body, "callback")
+
+	j.mu.Lock()
+	status, response, duration, opts, output, outputSize := j.status, j.response, j.duration, j.opts, j.output, j.outputSize
+	j.mu.Unlock()
+
+	body["event"] = "job." + string(status)
+	switch {
+	case status == JobCompleted && output.Bucket != "":
+		body["result"] = storedOutputBody(output, outputSize, response, duration)
+	case status == JobCompleted:
+		body["result"] = buildResult(response, duration, opts)
+	}
+	return json.Marshal(body)
+}
+
+// deliverCallback POSTs a finished job to its callback URL, retrying with backoff, and
+// records how the delivery went on the job
+func (s *Service) deliverCallback(job *Job) {
+	payload, err := job.callbackPayload()
+	if err != nil {
+		log.Printf("Error building callback for job %s: %v", job.id, err)
+		return
+	}
+
+	job.mu.Lock()
+	job.callback.status = CallbackDelivering
+	callbackURL := job.opts.CallbackURL
+	job.mu.Unlock()
+
+	attempts, err := currentConfig().NewNotifier().Notify(context.Background(), callbackURL, payload, func(attempt int, err error) {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.callback.attempts = attempt
+		job.callback.lastError = ""
+		if err != nil {
+			job.callback.lastError = err.Error()
+			log.Printf("Callback for job %s failed (attempt %d): %v", job.id, attempt, err)
+		}
+	})
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if err != nil {
+		job.callback.status = CallbackFailed
+		log.Printf("Giving up on callback for job %s after %d attempts", job.id, attempts)
+		return
+	}
+	job.callback.status = CallbackDelivered
+	job.callback.deliveredAt = time.Now()
+	log.Printf("Callback for job %s delivered after %d attempts", job.id, attempts)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"transription-service/internal/transcriber"
+)
+
+// maxStreamMessageBytes bounds a single WebSocket message of audio
+const maxStreamMessageBytes = 1 << 20
+
+// streamUpgrader accepts WebSocket connections from the same origin only
+var streamUpgrader = websocket.Upgrader{ReadBufferSize: 64 << 10, WriteBufferSize: 16 << 10}
+
+// streamMessage is what the server sends over a live streaming connection
+type streamMessage struct {
+	Type     string                 `json:"type"` // interim, final, error or done
+	Chunk    int                    `json:"chunk"`
+	Offset   float64                `json:"offset_seconds"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Text     string                 `json:"text,omitempty"`
+}
+
+// handleLiveStream transcribes live audio over a WebSocket. Clients send binary messages of
+// 16-bit little-endian PCM and a text message {"type":"stop"} (or a close) when done. Every
+// `chunk_seconds` of audio is transcribed into final segments; in between, the chunk so far is
+// transcribed every `interim_seconds` and sent as interim segments that later ones replace.
+func (s *Service) handleLiveStream(c *gin.Context) {
+	startTime := time.Now()
+
+	sampleRate, err := strconv.Atoi(c.DefaultQuery("sample_rate", "16000"))
+	if err != nil || sampleRate <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid sample_rate"))
+		return
+	}
+	channels, err := strconv.Atoi(c.DefaultQuery("channels", "1"))
+	if err != nil || channels <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid channels"))
+		return
+	}
+	chunkSeconds, err := strconv.ParseFloat(c.DefaultQuery("chunk_seconds", strconv.Itoa(currentConfig().StreamChunkSeconds)), 64)
+	if err != nil || chunkSeconds <= 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid chunk_seconds"))
+		return
+	}
+	interimSeconds, err := strconv.ParseFloat(c.DefaultQuery("interim_seconds", "2"), 64)
+	if err != nil || interimSeconds < 0 {
+		writeError(c, newAPIError(http.StatusBadRequest, "Invalid interim_seconds"))
+		return
+	}
+
+	opts, err := parseTranscribeOptions(c)
+	if err != nil {
+		writeError(c, newAPIError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	tmpDir, err := makeTempDir("audio-live")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already answered the client
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxStreamMessageBytes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Windows are transcribed one at a time; the reader never blocks on transcription
+	windows := make(chan transcriber.StreamWindow, 64)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.transcribeWindows(ctx, conn, tmpDir, windows, sampleRate, channels, opts, startTime)
+	}()
+
+	stream := transcriber.NewStream(sampleRate, channels, chunkSeconds, interimSeconds)
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				// Without a clean close there is nobody left to send results to
+				log.Printf("Live stream ended: %v", err)
+				cancel()
+				close(windows)
+				<-done
+				return
+			}
+			break
+		}
+
+		if messageType == websocket.TextMessage {
+			var control struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(data, &control) == nil && control.Type == "stop" {
+				break
+			}
+			continue
+		}
+		for _, window := range stream.Write(data) {
+			windows <- window
+		}
+	}
+
+	for _, window := range stream.Flush() {
+		windows <- window
+	}
+	close(windows)
+	<-done
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
+
+// transcribeWindows transcribes stream windows in order and sends the results. Interim
+// windows are skipped when newer audio is already waiting, so a slow backend falls back to
+// final results instead of falling behind.
+func (s *Service) transcribeWindows(ctx context.Context, conn *websocket.Conn, dir string, windows <-chan transcriber.StreamWindow, sampleRate, channels int, opts TranscribeOptions, startTime time.Time) {
+	var settled []TranscriptionSegment
+	for window := range windows {
+		if ctx.Err() != nil {
+			continue
+		}
+		if !window.Final && len(windows) > 0 {
+			continue
+		}
+
+		message := s.transcribeWindow(ctx, dir, window, sampleRate, channels, opts)
+		if window.Final {
+			settled = append(settled, message.Segments...)
+		}
+		if err := conn.WriteJSON(message); err != nil {
+			log.Printf("Error writing live stream result: %v", err)
+			return
+		}
+	}
+
+	if ctx.Err() == nil {
+		_ = conn.WriteJSON(gin.H{
+			"type":                    "done",
+			"text":                    joinSegmentText(settled),
+			"processing_time_seconds": time.Since(startTime).Seconds(),
+		})
+	}
+}
+
+// transcribeWindow transcribes one window and shifts its segments onto the stream's timeline
+func (s *Service) transcribeWindow(ctx context.Context, dir string, window transcriber.StreamWindow, sampleRate, channels int, opts TranscribeOptions) streamMessage {
+	message := streamMessage{Type: "interim", Chunk: window.Chunk, Offset: window.Offset}
+	if window.Final {
+		message.Type = "final"
+	} else {
+		// Interim audio is replaced moments later, so it shouldn't land in the cache or history
+		opts.NoCache = true
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("window-%05d-%t.wav", window.Chunk, window.Final))
+	defer os.Remove(path)
+	if err := writeWAV(path, window.PCM, sampleRate, channels); err != nil {
+		return streamMessage{Type: "error", Chunk: window.Chunk, Offset: window.Offset, Error: "Failed to write audio chunk"}
+	}
+
+	response, err := s.transcribe(ctx, path, opts)
+	if err != nil {
+		return streamMessage{Type: "error", Chunk: window.Chunk, Offset: window.Offset, Error: err.Error()}
+	}
+	shiftSegments(response.Segments, window.Offset)
+	message.Segments = response.Segments
+	return message
+}
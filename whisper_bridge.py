@@ -1,11 +1,15 @@
 #!/usr/bin/env python3
 import sys
 import json
+import math
 import os
 import traceback
 import argparse
 import time
 import logging
+import io
+import re
+import contextlib
 
 # Configure logging
 logging.basicConfig(level=logging.INFO,
@@ -13,13 +17,428 @@ logging.basicConfig(level=logging.INFO,
                     stream=sys.stderr)
 logger = logging.getLogger('whisper_bridge')
 
-def main():
+def restore_punctuation(segments):
+    """Add punctuation and capitalization to segment texts using an external model"""
+    from deepmultilingualpunctuation import PunctuationModel
+
+    model_name = os.environ.get("PUNCTUATION_MODEL")
+    model = PunctuationModel(model=model_name) if model_name else PunctuationModel()
+
+    words_per_segment = [len(segment["text"].split()) for segment in segments]
+    full_text = " ".join(segment["text"].strip() for segment in segments)
+    punctuated = model.restore_punctuation(full_text).split()
+
+    # The model only inserts punctuation, so word counts line up with the original segments
+    if len(punctuated) != sum(words_per_segment):
+        raise ValueError("punctuation model changed the word count")
+
+    capitalize_next = True
+    position = 0
+    for segment, count in zip(segments, words_per_segment):
+        words = punctuated[position:position + count]
+        position += count
+        for i, word in enumerate(words):
+            if capitalize_next:
+                words[i] = word[:1].upper() + word[1:]
+            capitalize_next = word.endswith((".", "?", "!"))
+        segment["text"] = " " + " ".join(words) if words else segment["text"]
+
+def assign_speakers(audio_path, segments):
+    """Label each segment with the speaker that overlaps it the most"""
+    from pyannote.audio import Pipeline
+
+    pipeline = Pipeline.from_pretrained(
+        os.environ.get("DIARIZATION_MODEL", "pyannote/speaker-diarization-3.1"),
+        use_auth_token=os.environ.get("HF_TOKEN"))
+    if pipeline is None:
+        raise RuntimeError("diarization pipeline could not be loaded")
+    diarization = pipeline(audio_path)
+    turns = [(turn.start, turn.end, speaker)
+             for turn, _, speaker in diarization.itertracks(yield_label=True)]
+
+    for segment in segments:
+        overlaps = {}
+        for start, end, speaker in turns:
+            overlap = min(end, segment["end_time"]) - max(start, segment["start_time"])
+            if overlap > 0:
+                overlaps[speaker] = overlaps.get(speaker, 0) + overlap
+        if overlaps:
+            segment["speaker"] = max(overlaps, key=overlaps.get)
+
+def estimate_speaker_count(audio_path, segments):
+    """Roughly estimate how many voices speak by clustering per-segment spectral profiles"""
+    import numpy as np
+    import whisper
+
+    labels = {segment["speaker"] for segment in segments if segment.get("speaker")}
+    if labels:
+        return len(labels)
+
+    threshold = float(os.environ.get("SPEAKER_SIMILARITY_THRESHOLD", "0.9"))
+    audio = whisper.load_audio(audio_path)
+    centroids = []
+    for segment in segments:
+        start = int(segment["start_time"] * whisper.audio.SAMPLE_RATE)
+        end = int(segment["end_time"] * whisper.audio.SAMPLE_RATE)
+        clip = audio[start:end]
+        if len(clip) < whisper.audio.SAMPLE_RATE // 2:
+            continue
+
+        # Average log-mel spectrum, normalized, as a cheap voice fingerprint
+        profile = whisper.log_mel_spectrogram(clip).numpy().mean(axis=1)
+        profile = profile - profile.mean()
+        norm = np.linalg.norm(profile)
+        if norm == 0:
+            continue
+        profile = profile / norm
+
+        similarities = [float(c @ profile) / np.linalg.norm(c) for c in centroids]
+        if similarities and max(similarities) >= threshold:
+            best = int(np.argmax(similarities))
+            centroids[best] = centroids[best] + profile
+        else:
+            centroids.append(profile.copy())
+    return len(centroids)
+
+def language_probabilities(model, audio_path):
+    """Run Whisper's language identification on the first 30 seconds"""
+    import whisper
+
+    audio = whisper.pad_or_trim(whisper.load_audio(audio_path))
+    n_mels = getattr(model.dims, "n_mels", 80)
+    if n_mels != 80:
+        mel = whisper.log_mel_spectrogram(audio, n_mels)
+    else:
+        mel = whisper.log_mel_spectrogram(audio)
+    _, probs = model.detect_language(mel.to(model.device))
+    return probs
+
+def choose_language(model, audio_path, candidates):
+    """Detect the language of the first 30 seconds, restricted to the candidate codes"""
+    if len(candidates) == 1:
+        return candidates[0]
+    probs = language_probabilities(model, audio_path)
+    return max(candidates, key=lambda code: probs.get(code, 0))
+
+def detect_language(model, audio_path, top=5):
+    """Report the most likely language of the first 30 seconds and the runners-up"""
+    probs = language_probabilities(model, audio_path)
+    ranked = sorted(probs.items(), key=lambda item: item[1], reverse=True)[:top]
+    return {
+        "language": ranked[0][0],
+        "probability": round(float(ranked[0][1]), 4),
+        "candidates": [{"language": code, "probability": round(float(p), 4)} for code, p in ranked],
+    }
+
+def detect_speech(audio_path, aggressiveness=2):
+    """Find the stretches of speech with WebRTC's voice activity detector"""
+    import numpy as np
+    import webrtcvad
+    import whisper
+
+    vad = webrtcvad.Vad(aggressiveness)
+    rate = whisper.audio.SAMPLE_RATE
+    frame = rate * 30 // 1000  # webrtcvad takes 10, 20 or 30 ms frames
+    audio = whisper.load_audio(audio_path)
+    pcm = (np.clip(audio, -1, 1) * 32767).astype(np.int16)
+
+    speech = []
+    for offset in range(0, len(pcm) - frame + 1, frame):
+        if not vad.is_speech(pcm[offset:offset + frame].tobytes(), rate):
+            continue
+        start, end = offset / rate, (offset + frame) / rate
+        if speech and speech[-1][1] >= start:
+            speech[-1][1] = end
+        else:
+            speech.append([start, end])
+    return {"speech": speech, "duration": len(audio) / rate}
+
+def detect_segment_languages(model, audio_path, segments):
+    """Run language identification on each segment's own audio for code-switched recordings"""
+    import whisper
+
+    audio = whisper.load_audio(audio_path)
+    n_mels = getattr(model.dims, "n_mels", 80)
+    for segment in segments:
+        start = int(segment["start_time"] * whisper.audio.SAMPLE_RATE)
+        end = int(segment["end_time"] * whisper.audio.SAMPLE_RATE)
+        clip = whisper.pad_or_trim(audio[start:end])
+        if n_mels != 80:
+            mel = whisper.log_mel_spectrogram(clip, n_mels)
+        else:
+            mel = whisper.log_mel_spectrogram(clip)
+        _, probs = model.detect_language(mel.to(model.device))
+        segment["language"] = max(probs, key=probs.get)
+
+def add_alternatives(model, audio_path, segments, n_best, language, fp16, task="transcribe"):
+    """Re-decode each segment's audio with sampling to collect up to n_best - 1 other candidate texts"""
+    import whisper
+
+    audio = whisper.load_audio(audio_path)
+    n_mels = getattr(model.dims, "n_mels", 80)
+    for segment in segments:
+        start = int(segment["start_time"] * whisper.audio.SAMPLE_RATE)
+        end = int(segment["end_time"] * whisper.audio.SAMPLE_RATE)
+        clip = whisper.pad_or_trim(audio[start:end])
+        if n_mels != 80:
+            mel = whisper.log_mel_spectrogram(clip, n_mels)
+        else:
+            mel = whisper.log_mel_spectrogram(clip)
+        mel = mel.to(model.device)
+
+        # Rising temperatures give increasingly different readings; duplicates are dropped
+        seen = {segment["text"].strip()}
+        alternatives = []
+        for attempt in range(2 * n_best):
+            if len(alternatives) >= n_best - 1:
+                break
+            options = whisper.DecodingOptions(temperature=0.4 + 0.1 * attempt, language=language, task=task,
+                                              without_timestamps=True, fp16=fp16)
+            text = whisper.decode(model, mel, options).text.strip()
+            if text and text not in seen:
+                seen.add(text)
+                alternatives.append(text)
+        segment["alternatives"] = alternatives
+
+# Whisper language codes written in Chinese characters
+CHINESE_LANGUAGES = ("zh", "yue")
+
+def convert_script(segments, language, script):
+    """Convert Chinese segment texts to simplified or traditional characters with OpenCC"""
+    import opencc
+
+    converter = opencc.OpenCC("t2s" if script == "simplified" else "s2t")
+    for segment in segments:
+        if segment.get("language", language) in CHINESE_LANGUAGES:
+            segment["text"] = converter.convert(segment["text"])
+            if segment.get("alternatives"):
+                segment["alternatives"] = [converter.convert(text) for text in segment["alternatives"]]
+
+def report_progress(event):
+    """Write a progress event for the Go service on a line of its own"""
+    write_message("PROGRESS", event)
+
+class SegmentReporter(io.TextIOBase):
+    """Turns the segment lines whisper prints in verbose mode into progress events"""
+    LINE = re.compile(r"^\[((?:\d+:)?\d+:\d+\.\d+) --> ((?:\d+:)?\d+:\d+\.\d+)\]\s?(.*)$")
+
+    def __init__(self, duration):
+        self.duration = duration
+        self.pending = ""
+
+    def write(self, text):
+        self.pending += text
+        while "\n" in self.pending:
+            line, self.pending = self.pending.split("\n", 1)
+            match = self.LINE.match(line)
+            if match:
+                start, end = parse_clock(match.group(1)), parse_clock(match.group(2))
+                event = {"segment": {"text": match.group(3), "start_time": start, "end_time": end}}
+                if self.duration > 0:
+                    event["fraction"] = round(min(end / self.duration, 1.0), 4)
+                report_progress(event)
+        return len(text)
+
+def parse_clock(clock):
+    """Convert whisper's [HH:]MM:SS.mmm timestamps to seconds"""
+    seconds = 0.0
+    for part in clock.split(":"):
+        seconds = seconds * 60 + float(part)
+    return seconds
+
+def select_device():
+    """Pick the torch device, preferring CUDA unless WHISPER_DEVICE overrides it"""
+    import torch
+
+    device = os.environ.get("WHISPER_DEVICE")
+    if device:
+        return device
+    return "cuda" if torch.cuda.is_available() else "cpu"
+
+def report_device():
+    """Print the device transcription would run on as JSON"""
+    try:
+        import torch
+        info = {
+            "device": select_device(),
+            "cuda_available": torch.cuda.is_available(),
+            "torch_version": torch.__version__,
+        }
+    except Exception as e:
+        info = {"device": "unknown", "cuda_available": False, "error": str(e)}
+    print(json.dumps(info))
+    return 0
+
+def model_path(whisper, name):
+    """Return where whisper keeps the checkpoint of a named model"""
+    if name not in whisper._MODELS:
+        raise ValueError(f"unknown model {name}")
+    download_root = os.path.join(os.getenv("XDG_CACHE_HOME", os.path.join(os.path.expanduser("~"), ".cache")), "whisper")
+    return os.path.join(download_root, os.path.basename(whisper._MODELS[name]))
+
+def model_info(whisper, name):
+    """Describe a model's checkpoint: whether it is downloaded and its size on disk"""
+    path = model_path(whisper, name)
+    info = {"name": name, "installed": os.path.exists(path)}
+    if info["installed"]:
+        info["size_bytes"] = os.path.getsize(path)
+    return info
+
+def list_models():
+    """Print the known whisper models as JSON, marking the ones already downloaded"""
+    try:
+        import whisper
+        report = {"models": [model_info(whisper, name) for name in whisper._MODELS]}
+    except Exception as e:
+        report = {"models": [], "error": str(e)}
+    print(json.dumps(report))
+    return 0
+
+def download_model(name):
+    """Download a model's checkpoint into the cache, verifying its checksum, and print its info"""
+    try:
+        import whisper
+        path = model_path(whisper, name)
+        whisper._download(whisper._MODELS[name], os.path.dirname(path), False)
+        report = model_info(whisper, name)
+    except Exception as e:
+        report = {"name": name, "error": str(e)}
+    print(json.dumps(report))
+    return 0
+
+def delete_model(name):
+    """Remove a model's checkpoint from the cache and print its info"""
+    try:
+        import whisper
+        path = model_path(whisper, name)
+        if os.path.exists(path):
+            os.remove(path)
+        report = model_info(whisper, name)
+    except Exception as e:
+        report = {"name": name, "error": str(e)}
+    print(json.dumps(report))
+    return 0
+
+def load_model(whisper, name, device, models=None):
+    """Load a whisper model, reusing the one a serving worker already holds"""
+    if models is None:
+        return whisper.load_model(name, device=device)
+    key = (name, device)
+    if key not in models:
+        # A worker keeps one model at a time so switching models doesn't pile up memory
+        models.clear()
+        models[key] = whisper.load_model(name, device=device)
+    return models[key]
+
+def write_message(kind, payload):
+    """Write a message for the Go service on a line of its own"""
+    sys.__stdout__.write(kind + " " + json.dumps(payload) + "\n")
+    sys.__stdout__.flush()
+
+def serve_request(parser, argv, models):
+    """Run one request of a serving worker, returning its exit code and the log it wrote"""
+    capture = io.StringIO()
+    handler = logging.StreamHandler(capture)
+    handler.setFormatter(logging.getLogger().handlers[0].formatter)
+    logging.getLogger().addHandler(handler)
+    try:
+        with contextlib.redirect_stderr(capture):
+            args = parser.parse_args(argv)
+        if not args.input or not args.output:
+            raise ValueError("--input and --output are required")
+        code = transcribe(args, models)
+    except SystemExit as e:
+        # argparse exits on invalid arguments; the worker itself keeps going
+        code = e.code if isinstance(e.code, int) else 2
+    except Exception as e:
+        logger.error(f"Request failed: {e}")
+        code = 1
+    finally:
+        logging.getLogger().removeHandler(handler)
+    return {"exit_code": code, "log": capture.getvalue()}
+
+def serve(parser):
+    """Answer requests from the Go service one at a time until stdin closes.
+
+    Each request is a JSON line {"id": n, "args": [...]} with the arguments of a one-off run,
+    or {"id": n, "ping": true} for a health check, and is answered with a DONE line carrying
+    its id, exit code and log."""
+    # Stray prints from libraries must not be mistaken for replies
+    sys.stdout = sys.stderr
+
+    # torch and whisper are imported once, which is most of what a one-off run spends
+    try:
+        import torch
+        import whisper
+        logger.info(f"Worker ready with PyTorch {torch.__version__}")
+    except Exception as e:
+        logger.error(f"Worker failed to import whisper: {e}")
+        return 1
+    write_message("READY", {"pid": os.getpid()})
+
+    models = {}
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+        try:
+            request = json.loads(line)
+        except ValueError as e:
+            logger.error(f"Ignoring malformed request: {e}")
+            continue
+        reply = {"id": request.get("id"), "exit_code": 0}
+        if not request.get("ping"):
+            reply.update(serve_request(parser, request.get("args", []), models))
+        write_message("DONE", reply)
+    return 0
+
+def build_parser():
+    """Describe the command line of a one-off run, which serving workers also accept per request"""
     parser = argparse.ArgumentParser(description="Transcribe audio using whisper")
-    parser.add_argument("--input", "-i", required=True, help="Input audio file")
-    parser.add_argument("--output", "-o", required=True, help="Output JSON file")
+    parser.add_argument("--input", "-i", help="Input audio file")
+    parser.add_argument("--output", "-o", help="Output JSON file")
     parser.add_argument("--model", "-m", default="tiny", help="Whisper model to use")
+    parser.add_argument("--punctuate", action="store_true", help="Restore punctuation and capitalization")
+    parser.add_argument("--diarize", action="store_true", help="Label segments with speakers")
+    parser.add_argument("--segment-language", action="store_true", help="Detect the language of each segment")
+    parser.add_argument("--estimate-speakers", action="store_true", help="Estimate the number of speakers")
+    parser.add_argument("--task", choices=["transcribe", "translate"], default="transcribe",
+                        help="Transcribe in the spoken language or translate to English")
+    parser.add_argument("--languages", help="Comma-separated candidate language codes to choose from")
+    parser.add_argument("--n-best", type=int, default=1, help="Candidate texts to return per segment")
+    parser.add_argument("--script", choices=["simplified", "traditional"], help="Chinese script to convert the output to")
+    parser.add_argument("--detect-language", action="store_true", help="Only identify the language of the first 30 seconds")
+    parser.add_argument("--vad", action="store_true", help="Only find the stretches of speech with voice activity detection")
+    parser.add_argument("--vad-aggressiveness", type=int, choices=range(4), default=2,
+                        help="How readily the voice activity detector rejects non-speech, 0-3")
+    parser.add_argument("--progress", action="store_true", help="Report stages and decoded segments on stdout while transcribing")
+    parser.add_argument("--check-device", action="store_true", help="Report the compute device and exit")
+    parser.add_argument("--list-models", action="store_true", help="Report the known and installed models and exit")
+    parser.add_argument("--download-model", metavar="NAME", help="Download a model into the cache and exit")
+    parser.add_argument("--delete-model", metavar="NAME", help="Remove a downloaded model from the cache and exit")
+    parser.add_argument("--serve", action="store_true", help="Keep running and answer requests on stdin")
+    return parser
+
+def main():
+    parser = build_parser()
     args = parser.parse_args()
 
+    if args.check_device:
+        return report_device()
+    if args.list_models:
+        return list_models()
+    if args.download_model:
+        return download_model(args.download_model)
+    if args.delete_model:
+        return delete_model(args.delete_model)
+    if args.serve:
+        return serve(parser)
+    if not args.input or not args.output:
+        parser.error("--input and --output are required")
+    return transcribe(args)
+
+def transcribe(args, models=None):
+    """Transcribe args.input into args.output, reusing a serving worker's loaded models when given"""
     start_time = time.time()
 
     try:
@@ -37,38 +456,129 @@ def main():
         # Check if the input file exists
         if not os.path.exists(args.input):
             logger.error(f"Input file does not exist: {args.input}")
-            with open(args.output, "w") as f:
+            with open(args.output, "w", encoding="utf-8") as f:
                 json.dump({
                     "error": f"Input file does not exist: {args.input}",
                     "segments": []
                 }, f, indent=2)
             return 1
 
+        # Voice activity detection alone needs no model
+        if args.vad:
+            output = detect_speech(args.input, args.vad_aggressiveness)
+            logger.info(f"Found {len(output['speech'])} stretches of speech in {output['duration']:.1f} seconds")
+            with open(args.output, "w", encoding="utf-8") as f:
+                json.dump(output, f)
+            return 0
+
         # Check file size
         file_size_mb = os.path.getsize(args.input) / (1024 * 1024)
         logger.info(f"Input file size: {file_size_mb:.2f} MB")
 
         # Load model
         logger.info(f"Loading whisper model: {args.model}")
-        model = whisper.load_model(args.model, device="cpu")
+        device = select_device()
+        logger.info(f"Using device: {device}")
+        model = load_model(whisper, args.model, device, models)
         logger.info(f"Model loaded in {time.time() - start_time:.2f} seconds")
+        if args.progress:
+            report_progress({"stage": "model_loaded"})
+
+        # Language identification alone skips decoding entirely
+        if args.detect_language:
+            output = detect_language(model, args.input)
+            logger.info(f"Detected language {output['language']} ({output['probability']:.2f})")
+            with open(args.output, "w", encoding="utf-8") as f:
+                json.dump(output, f, indent=2)
+            return 0
 
         # Transcribe
         logger.info(f"Transcribing: {args.input}")
-        result = model.transcribe(args.input, fp16=False)
+        options = {"task": args.task}
+        if args.languages:
+            candidates = [code.strip() for code in args.languages.split(",") if code.strip()]
+            options["language"] = choose_language(model, args.input, candidates)
+            logger.info(f"Chose language {options['language']} from candidates {candidates}")
+        if args.progress:
+            # Verbose mode prints each segment as it is decoded; the reporter forwards them
+            audio = whisper.load_audio(args.input)
+            report_progress({"stage": "decoding"})
+            with contextlib.redirect_stdout(SegmentReporter(len(audio) / whisper.audio.SAMPLE_RATE)):
+                result = model.transcribe(audio, fp16=device == "cuda", verbose=True, **options)
+        else:
+            result = model.transcribe(args.input, fp16=device == "cuda", **options)
 
         # Process segments
         segments = []
         for segment in result["segments"]:
-            segments.append({
+            entry = {
                 "text": segment["text"],
                 "start_time": segment["start"],
                 "end_time": segment["end"]
-            })
+            }
+            # The average token log probability maps to a rough 0-1 confidence
+            if segment.get("avg_logprob") is not None:
+                entry["confidence"] = round(math.exp(segment["avg_logprob"]), 4)
+            segments.append(entry)
+
+        warnings = []
+
+        # Optional alternative readings, decoded before punctuation rewrites the best text
+        if args.n_best > 1:
+            try:
+                add_alternatives(model, args.input, segments, args.n_best,
+                                 result.get("language"), device == "cuda", args.task)
+            except Exception as e:
+                logger.warning(f"Alternative transcriptions unavailable: {e}")
+                warnings.append(f"Alternative transcriptions skipped: {e}")
+
+        # Optional per-segment language identification
+        if args.segment_language:
+            try:
+                detect_segment_languages(model, args.input, segments)
+            except Exception as e:
+                logger.warning(f"Per-segment language detection unavailable: {e}")
+                warnings.append(f"Per-segment language detection skipped: {e}")
+
+        # Optional Chinese script conversion; other languages pass through unchanged
+        if args.script:
+            try:
+                convert_script(segments, result.get("language"), args.script)
+            except Exception as e:
+                logger.warning(f"Script conversion unavailable: {e}")
+                warnings.append(f"Script conversion skipped: {e}")
+
+        # Optional punctuation pass; failures leave the raw text untouched
+        if args.punctuate:
+            try:
+                restore_punctuation(segments)
+            except Exception as e:
+                logger.warning(f"Punctuation restoration unavailable: {e}")
+                warnings.append(f"Punctuation restoration skipped: {e}")
+
+        # Optional speaker labels; segments stay unlabeled if diarization fails
+        if args.diarize:
+            try:
+                assign_speakers(args.input, segments)
+            except Exception as e:
+                logger.warning(f"Diarization unavailable: {e}")
+                warnings.append(f"Diarization skipped: {e}")
 
         # Write output
-        with open(args.output, "w") as f:
-            json.dump({"segments": segments}, f, indent=2)
+        output = {"segments": segments, "language": result.get("language")}
+
+        # Optional speaker count estimate, reusing diarization labels when present
+        if args.estimate_speakers:
+            try:
+                output["estimated_speakers"] = estimate_speaker_count(args.input, segments)
+            except Exception as e:
+                logger.warning(f"Speaker count estimation unavailable: {e}")
+                warnings.append(f"Speaker count estimation skipped: {e}")
+
+        if warnings:
+            output["warnings"] = warnings
+        with open(args.output, "w", encoding="utf-8") as f:
+            json.dump(output, f, indent=2)
 
         logger.info(f"Transcription completed in {time.time() - start_time:.2f} seconds")
         logger.info(f"Transcribed {len(segments)} segments")
@@ -78,7 +588,7 @@ def main():
         logger.error(traceback.format_exc())
 
         # Create minimal output in case of error
-        with open(args.output, "w") as f:
+        with open(args.output, "w", encoding="utf-8") as f:
             json.dump({
                 "error": str(e),
                 "segments": [{
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"transription-service/internal/bridge"
+	"transription-service/internal/transcriber"
+)
+
+// bridgeHealthInterval is how often idle bridge workers are pinged
+const bridgeHealthInterval = 30 * time.Second
+
+// bridgeWorkers are the persistent bridge processes, nil when they are turned off
+var bridgeWorkers *bridge.Pool
+
+// startBridgeWorkers starts one persistent bridge worker per transcription slot, so torch is
+// imported and the model loaded once instead of for every request
+func startBridgeWorkers(cfg *Config) error {
+	if !cfg.PersistentBridge || cfg.Engine != transcriber.EnginePythonWhisper {
+		return nil
+	}
+	script, err := bridgeScriptPath()
+	if err != nil {
+		return err
+	}
+	bridgeWorkers = bridge.NewPool(script, cfg.MaxConcurrentJobs)
+	go bridgeWorkers.Maintain(context.Background(), bridgeHealthInterval)
+	return nil
+}
+
+// runBridge runs the bridge with args on a worker when there are any and as a process of its
+// own otherwise, returning what it printed
+func runBridge(ctx context.Context, args ...string) ([]byte, error) {
+	if bridgeWorkers != nil {
+		return bridgeWorkers.Output(ctx, args, nil)
+	}
+
+	script, err := bridgeScriptPath()
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, "python3", append([]string{script}, args...)...).CombinedOutput()
+}